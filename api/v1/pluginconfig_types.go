@@ -6,11 +6,24 @@ package v1
 
 import (
 	"fmt"
+	"net"
+	"strconv"
 
+	machineryapi "github.com/cloudnative-pg/machinery/pkg/api"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+const (
+	// DefaultExporterPort is the port the pgBackRest exporter listens on,
+	// unless configured
+	DefaultExporterPort = 9854
+
+	// ExporterWebConfigPath is where the Secret holding the web configuration
+	// of the exporter is mounted
+	ExporterWebConfigPath = "/etc/pgbackrest-exporter/web"
+)
+
 type ExporterConfig struct {
 
 	// Define if pgBackRest exporter should be enabled.
@@ -25,6 +38,137 @@ type ExporterConfig struct {
 	// Defines resource requests and limits for the pgBackRest exporter sidecar containers.
 	// +optional
 	Resources *corev1.ResourceRequirements `json:"resourcesRequirement,omitempty"`
+
+	// Defines the image of the pgBackRest exporter sidecar container,
+	// overriding the SIDECAR_EXPORTER_IMAGE environment variable of the
+	// operator.
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// Defines the address the exporter listens on, all the addresses of the
+	// pod by default.
+	// +optional
+	ListenAddress string `json:"listenAddress,omitempty"`
+
+	// Defines the port the exporter listens on. Defaults to 9854.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Port int32 `json:"port,omitempty"`
+
+	// Defines the stanzas the metrics are collected for, all by default.
+	// +optional
+	StanzaInclude []string `json:"stanzaInclude,omitempty"`
+
+	// Defines the stanzas the metrics aren't collected for.
+	// +optional
+	StanzaExclude []string `json:"stanzaExclude,omitempty"`
+
+	// Defines the type of the backups the metrics are collected for, all by
+	// default.
+	// +kubebuilder:validation:Enum=full;diff;incr
+	// +optional
+	BackupType string `json:"backupType,omitempty"`
+
+	// Defines if the number of databases in the backups is collected.
+	// +optional
+	DatabaseCount bool `json:"databaseCount,omitempty"`
+
+	// Defines if the number of databases is only collected for the latest
+	// backup, when databaseCount is set.
+	// +optional
+	DatabaseCountLatest bool `json:"databaseCountLatest,omitempty"`
+
+	// Defines if the metrics of the WAL archives carry the WAL segment names.
+	// +optional
+	VerboseWAL bool `json:"verboseWAL,omitempty"`
+
+	// Reference to the key of a Secret holding the web configuration file
+	// of the exporter, setting TLS and basic authentication. The Secret is
+	// mounted in /etc/pgbackrest-exporter/web, where the files it references
+	// (e.g. the certificates stored in other keys of the Secret) are found.
+	// +optional
+	WebConfig *machineryapi.SecretKeySelector `json:"webConfig,omitempty"`
+
+	// Defines the PodMonitor (monitoring.coreos.com) created by the operator
+	// to scrape the exporter of the instances of each Cluster.
+	// +optional
+	PodMonitor *ExporterPodMonitor `json:"podMonitor,omitempty"`
+}
+
+// ExporterPodMonitor defines the PodMonitor scraping the pgBackRest exporter
+type ExporterPodMonitor struct {
+	// Defines if the operator creates a PodMonitor for each Cluster, named
+	// after the Cluster with the -pgbackrest suffix and owned by it.
+	// +optional
+	Enabled bool `json:"enabled"`
+
+	// Defines if only the series scraped from the primary are kept: the
+	// exporter of every instance reports the same repositories.
+	// +optional
+	PrimaryOnly bool `json:"primaryOnly,omitempty"`
+
+	// Defines the scheme of the scrapes, `https` when the webConfig of the
+	// exporter enables TLS. Only applied when the webConfig is set.
+	// +kubebuilder:validation:Enum=http;https
+	// +optional
+	Scheme string `json:"scheme,omitempty"`
+
+	// Defines the TLS settings of the scrapes, when the webConfig of the
+	// exporter enables TLS. Only applied when the webConfig is set.
+	// +optional
+	TLSConfig *ExporterPodMonitorTLSConfig `json:"tlsConfig,omitempty"`
+
+	// Defines the basic authentication of the scrapes, when the webConfig of
+	// the exporter requires it. Only applied when the webConfig is set.
+	// +optional
+	BasicAuth *ExporterPodMonitorBasicAuth `json:"basicAuth,omitempty"`
+}
+
+// ExporterPodMonitorTLSConfig defines how Prometheus verifies the
+// certificate of the exporter, and the client certificate it presents. The
+// ConfigMaps and Secrets are read from the namespace of the Cluster.
+// +kubebuilder:validation:XValidation:rule="has(self.certSecretRef) == has(self.keySecretRef)",message="certSecretRef and keySecretRef must be set together"
+type ExporterPodMonitorTLSConfig struct {
+	// Certificate authority verifying the certificate of the exporter.
+	// +optional
+	CA *CABundle `json:"ca,omitempty"`
+
+	// Key of a Secret holding the client certificate presented to the
+	// exporter.
+	// +optional
+	CertSecretRef *machineryapi.SecretKeySelector `json:"certSecretRef,omitempty"`
+
+	// Key of a Secret holding the private key of the client certificate.
+	// +optional
+	KeySecretRef *machineryapi.SecretKeySelector `json:"keySecretRef,omitempty"`
+
+	// Name verified in the certificate of the exporter, the instances are
+	// scraped on their IP address.
+	// +optional
+	ServerName string `json:"serverName,omitempty"`
+
+	// Disables the verification of the certificate of the exporter.
+	// +optional
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+}
+
+// ExporterPodMonitorBasicAuth defines the credentials Prometheus scrapes
+// the exporter with, read from Secrets of the namespace of the Cluster
+type ExporterPodMonitorBasicAuth struct {
+	// Key of a Secret holding the username.
+	Username machineryapi.SecretKeySelector `json:"username"`
+
+	// Key of a Secret holding the password.
+	Password machineryapi.SecretKeySelector `json:"password"`
+}
+
+// GetPort returns the port the exporter listens on
+func (ec *ExporterConfig) GetPort() int32 {
+	if ec.Port == 0 {
+		return DefaultExporterPort
+	}
+	return ec.Port
 }
 
 // ToArgs converts the ExporterConfig into command-line flags for the
@@ -33,13 +177,48 @@ type ExporterConfig struct {
 // It returns a slice of arguments in "--key=value" form, with only
 // fields that are explicitly set (non-zero values).
 func (ec *ExporterConfig) ToArgs() []string {
-	args := make([]string, 0, 1) // TODO: change capacity if we add more setting
+	args := make([]string, 0)
 	if eci := ec.CollectInterval; eci != 0 {
 		args = append(args, fmt.Sprintf("--collect.interval=%d", eci))
 	}
+	if ec.ListenAddress != "" || ec.Port != 0 {
+		addr := net.JoinHostPort(ec.ListenAddress, strconv.Itoa(int(ec.GetPort())))
+		args = append(args, "--web.listen-address="+addr)
+	}
+	if ec.WebConfig != nil {
+		args = append(args, "--web.config.file="+ExporterWebConfigPath+"/"+ec.WebConfig.Key)
+	}
+	for _, stanza := range ec.StanzaInclude {
+		args = append(args, "--backrest.stanza-include="+stanza)
+	}
+	for _, stanza := range ec.StanzaExclude {
+		args = append(args, "--backrest.stanza-exclude="+stanza)
+	}
+	if ec.BackupType != "" {
+		args = append(args, "--backrest.backup-type="+ec.BackupType)
+	}
+	if ec.DatabaseCount {
+		args = append(args, "--backrest.database-count")
+	}
+	if ec.DatabaseCountLatest {
+		args = append(args, "--backrest.database-count-latest")
+	}
+	if ec.VerboseWAL {
+		args = append(args, "--backrest.verbose-wal")
+	}
 	return args
 }
 
+// RestoreConfig defines the settings of the pgBackRest sidecar of the
+// full-recovery Job
+type RestoreConfig struct {
+	// Defines resource requests and limits for the pgBackRest sidecar of the
+	// full-recovery Job. A restore needs far more CPU and memory than the
+	// WAL archiving, it defaults to the resources of the other sidecars.
+	// +optional
+	Resources *corev1.ResourceRequirements `json:"resourcesRequirement,omitempty"`
+}
+
 type StorageConfig struct {
 
 	// Defines the storage class used for PersistentVolumeClaims
@@ -56,8 +235,28 @@ type StorageConfig struct {
 	// +optional
 	// +kubebuilder:validation:Pattern:="^[0-9]+?[M|G]i$"
 	Size string `json:"size,omitempty"`
+
+	// Defines the kind of volume holding the spool directory. With `pvc`
+	// (default), a PersistentVolumeClaim is created for each instance and
+	// deleted once the instance is gone. With `emptyDir` or `ephemeral` (a
+	// generic ephemeral volume), the spool is deleted along with the Pod.
+	// The spool only holds the acknowledgements of the asynchronous
+	// archive-push, the WAL segments waiting to be archived stay in pg_wal.
+	// +kubebuilder:validation:Enum=pvc;emptyDir;ephemeral
+	// +kubebuilder:default=pvc
+	// +optional
+	VolumeType SpoolVolumeType `json:"volumeType,omitempty"`
 }
 
+// SpoolVolumeType is the kind of volume holding the spool directory
+type SpoolVolumeType string
+
+const (
+	SpoolVolumePVC       SpoolVolumeType = "pvc"
+	SpoolVolumeEmptyDir  SpoolVolumeType = "emptyDir"
+	SpoolVolumeEphemeral SpoolVolumeType = "ephemeral"
+)
+
 // PluginConfigSpec defines the desired state of the plugin config
 type PluginConfigSpec struct {
 
@@ -65,6 +264,44 @@ type PluginConfigSpec struct {
 	// +optional
 	Resources *corev1.ResourceRequirements `json:"resourcesRequirement,omitempty"`
 
+	// Defines the image of the pgBackRest sidecar containers, overriding the
+	// SIDECAR_IMAGE environment variable of the operator. This allows to try
+	// a new pgBackRest version on a single cluster.
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// Defines the pull policy of the sidecar images. Defaults to the
+	// imagePullPolicy of the Cluster.
+	// +kubebuilder:validation:Enum=Always;Never;IfNotPresent
+	// +optional
+	ImagePullPolicy corev1.PullPolicy `json:"imagePullPolicy,omitempty"`
+
+	// Defines the secrets added to the pods to pull the sidecar images from
+	// a private registry.
+	// +optional
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+
+	// Defines additional environment variables of the sidecar containers,
+	// they take precedence over the ones set by the plugin.
+	// +optional
+	Env []corev1.EnvVar `json:"env,omitempty"`
+
+	// Defines additional sources of environment variables of the sidecar
+	// containers.
+	// +optional
+	EnvFrom []corev1.EnvFromSource `json:"envFrom,omitempty"`
+
+	// Defines additional volume mounts of the sidecar containers. The volumes
+	// must be part of the pods, e.g. the `projected` volume defined by the
+	// projectedVolumeTemplate of the Cluster.
+	// +optional
+	AdditionalVolumeMounts []corev1.VolumeMount `json:"additionalVolumeMounts,omitempty"`
+
+	// Defines the security context of the sidecar containers, replacing the
+	// restricted one set by the plugin.
+	// +optional
+	SecurityContext *corev1.SecurityContext `json:"securityContext,omitempty"`
+
 	// Defines the configuration for pgBackRest storage used to persist WALs
 	// when running in asynchronous mode. When provided, it allows to customize
 	// the PersistentVolumeClaim settings (storage class and default size) for
@@ -78,8 +315,38 @@ type PluginConfigSpec struct {
 	// and WAL archiving metrics for monitoring purposes.
 	// +optional
 	ExporterConfig *ExporterConfig `json:"exporterConfig"`
+
+	// Defines the settings of the pgBackRest sidecar injected into the
+	// full-recovery Job, when bootstrapping a cluster from a stanza.
+	// +optional
+	RestoreConfig *RestoreConfig `json:"restoreConfig,omitempty"`
+
+	// Defines how the pgBackRest configuration is given to pgBackRest.
+	// With `env` (default), options and credentials are passed through
+	// environment variables. With `file`, they are written to a
+	// configuration file and separate credential files on a memory-backed
+	// volume, so that secrets are not exposed in the processes environment.
+	// +kubebuilder:validation:Enum=env;file
+	// +kubebuilder:default=env
+	// +optional
+	ConfigRendering ConfigRendering `json:"configRendering,omitempty"`
+
+	// Defines how long the sidecar keeps pushing the WAL segments left in
+	// the archive queue when the instance stops, with asynchronous
+	// archiving. It must stay below the stop delay of the Cluster.
+	// Defaults to 30s.
+	// +optional
+	DrainTimeout *metav1.Duration `json:"drainTimeout,omitempty"`
 }
 
+// ConfigRendering is the way the pgBackRest configuration is rendered
+type ConfigRendering string
+
+const (
+	ConfigRenderingEnv  ConfigRendering = "env"
+	ConfigRenderingFile ConfigRendering = "file"
+)
+
 // +kubebuilder:object:root=true
 // PluginConfig the Schema for the PluginConfig API
 type PluginConfig struct {
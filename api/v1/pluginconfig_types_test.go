@@ -7,6 +7,8 @@ package v1
 import (
 	"reflect"
 	"testing"
+
+	machineryapi "github.com/cloudnative-pg/machinery/pkg/api"
 )
 
 func TestExporterConfig_ToArgs(t *testing.T) {
@@ -42,6 +44,45 @@ func TestExporterConfig_ToArgs(t *testing.T) {
 			},
 			want: []string{"--collect.interval=300"},
 		},
+		{
+			name: "port only",
+			conf: ExporterConfig{Port: 9900},
+			want: []string{"--web.listen-address=:9900"},
+		},
+		{
+			name: "listen address with the default port",
+			conf: ExporterConfig{ListenAddress: "::1"},
+			want: []string{"--web.listen-address=[::1]:9854"},
+		},
+		{
+			name: "all options",
+			conf: ExporterConfig{
+				CollectInterval:     60,
+				Port:                9900,
+				StanzaInclude:       []string{"main", "other"},
+				StanzaExclude:       []string{"old"},
+				BackupType:          "full",
+				DatabaseCount:       true,
+				DatabaseCountLatest: true,
+				VerboseWAL:          true,
+				WebConfig: &machineryapi.SecretKeySelector{
+					LocalObjectReference: machineryapi.LocalObjectReference{Name: "web"},
+					Key:                  "web-config.yml",
+				},
+			},
+			want: []string{
+				"--collect.interval=60",
+				"--web.listen-address=:9900",
+				"--web.config.file=/etc/pgbackrest-exporter/web/web-config.yml",
+				"--backrest.stanza-include=main",
+				"--backrest.stanza-include=other",
+				"--backrest.stanza-exclude=old",
+				"--backrest.backup-type=full",
+				"--backrest.database-count",
+				"--backrest.database-count-latest",
+				"--backrest.verbose-wal",
+			},
+		},
 	}
 
 	for _, tt := range testCases {
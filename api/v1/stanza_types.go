@@ -25,18 +25,24 @@ import (
 	machineryapi "github.com/cloudnative-pg/machinery/pkg/api"
 	"github.com/dalibo/cnpg-i-pgbackrest/internal/utils"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 // +kubebuilder:rbac:groups=pgbackrest.dalibo.com,resources=pluginconfigs,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=pgbackrest.dalibo.com,resources=pluginconfigs/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=pgbackrest.dalibo.com,resources=pluginconfigs/finalizers,verbs=update
-// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;create;watch;patch
-// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=create;patch;update;get;list;watch
-// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles,verbs=create;patch;update;get;list;watch
+// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;create;watch;patch;delete
+// +kubebuilder:rbac:groups=storage.k8s.io,resources=storageclasses,verbs=get;list;watch
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=create;patch;update;get;list;watch;delete
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles,verbs=create;patch;update;get;list;watch;delete
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=create;list;get;watch;delete
 // +kubebuilder:rbac:groups=postgresql.cnpg.io,resources=clusters/finalizers,verbs=update
-// +kubebuilder:rbac:groups=postgresql.cnpg.io,resources=clusters,verbs=get
+// +kubebuilder:rbac:groups=postgresql.cnpg.io,resources=clusters,verbs=get;list;watch;patch
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;patch
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=create;delete;get;list;watch
 // +kubebuilder:rbac:groups=postgresql.cnpg.io,resources=backups,verbs=get;list;watch
+// +kubebuilder:rbac:groups=events.k8s.io,resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups=monitoring.coreos.com,resources=podmonitors,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=pgbackrest.dalibo.com,resources=stanzas,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=pgbackrest.dalibo.com,resources=stanzas/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=pgbackrest.dalibo.com,resources=stanzas/finalizers,verbs=update
@@ -148,6 +154,51 @@ type S3SecretRef struct {
 	SecretAccessKeyReference *machineryapi.SecretKeySelector `json:"secretAccessKey,omitempty"`
 }
 
+// S3ServerSideEncryption defines how the objects of the repository are
+// encrypted by the S3 server
+// +kubebuilder:validation:XValidation:rule="!(has(self.kmsKeyId) && has(self.customerKey))",message="kmsKeyId and customerKey are mutually exclusive"
+type S3ServerSideEncryption struct {
+	// KMS key used to encrypt the objects with SSE-KMS.
+	// +optional
+	KMSKeyID string `json:"kmsKeyId,omitempty" env:"KMS_KEY_ID"`
+
+	// Reference to the base64 encoded AES-256 key used to encrypt the objects
+	// with SSE-C.
+	// +optional
+	CustomerKeyReference *machineryapi.SecretKeySelector `json:"customerKey,omitempty"`
+}
+
+// S3WebIdentity defines the role assumed with a projected service account
+// token
+type S3WebIdentity struct {
+	// ARN of the IAM role to assume.
+	// +kubebuilder:validation:MinLength=1
+	RoleARN string `json:"roleArn"`
+
+	// Audience of the projected service account token.
+	// +kubebuilder:default="sts.amazonaws.com"
+	// +optional
+	Audience string `json:"audience,omitempty"`
+}
+
+// S3 key types
+const (
+	S3KeyTypeShared = "shared"
+	S3KeyTypeAuto   = "auto"
+	S3KeyTypeWebID  = "web-id"
+)
+
+// Azure key types
+const (
+	AzureKeyTypeShared = "shared"
+	AzureKeyTypeSAS    = "sas"
+	AzureKeyTypeAuto   = "auto"
+	// AzureKeyTypeWorkloadIdentity authenticates with the federated token
+	// injected by Microsoft Entra Workload ID, pgBackRest is given the auto
+	// key type.
+	AzureKeyTypeWorkloadIdentity = "workload-identity"
+)
+
 type CipherConfig struct {
 	// Reference to the secret containing the encryption key.
 	PassReference *machineryapi.SecretKeySelector `json:"encryptionPass,omitempty"`
@@ -159,6 +210,20 @@ type CipherConfig struct {
 	Type string `json:"type,omitempty,omitzero" env:"TYPE"`
 }
 
+// CABundle references the certificate authorities used to verify the
+// certificate of the repository storage, as a PEM bundle stored in a
+// ConfigMap or a Secret of the stanza namespace.
+// +kubebuilder:validation:XValidation:rule="has(self.configMapKeyRef) != has(self.secretKeyRef)",message="exactly one of configMapKeyRef and secretKeyRef must be set"
+type CABundle struct {
+	// Key of a ConfigMap holding the bundle.
+	// +optional
+	ConfigMapKeyRef *machineryapi.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+
+	// Key of a Secret holding the bundle.
+	// +optional
+	SecretKeyRef *machineryapi.SecretKeySelector `json:"secretKeyRef,omitempty"`
+}
+
 type S3Repository struct {
 	// S3 bucket used to store the repository.
 	// +kubebuilder:validation:MinLength=1
@@ -181,10 +246,53 @@ type S3Repository struct {
 	// +optional
 	VerifyTLS bool `json:"verifyTLS,omitempty,omitzero" env:"_S3_VERIFY_TLS"`
 
+	// Certificate authorities used to verify the repository storage, e.g. a
+	// private object storage with an internal CA.
+	// +optional
+	CABundle *CABundle `json:"caBundle,omitempty"`
+
+	// Host used to connect to the repository storage, overriding the one of
+	// the endpoint.
+	// +optional
+	StorageHost string `json:"storageHost,omitempty" env:"_STORAGE_HOST"`
+
+	// Port used to connect to the repository storage.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	StoragePort int32 `json:"storagePort,omitempty" env:"_STORAGE_PORT"`
+
 	// Reference to a Kubernetes Secret containing S3 credentials.
+	// Only used with the `shared` key type.
 	// +optional
 	SecretRef *S3SecretRef `json:"secretRef,omitempty"`
 
+	// S3 repository key type.
+	// `shared` (default) uses the access keys of secretRef, `auto` retrieves
+	// temporary credentials from the instance metadata, `web-id` exchanges a
+	// projected service account token for temporary credentials (EKS IRSA
+	// and similar).
+	// +kubebuilder:validation:Enum=shared;auto;web-id
+	// +optional
+	KeyType string `json:"keyType,omitempty" env:"_S3_KEY_TYPE"`
+
+	// Web identity used with the `web-id` key type.
+	// +optional
+	WebIdentity *S3WebIdentity `json:"webIdentity,omitempty"`
+
+	// Server-side encryption of the objects written to the bucket.
+	// +optional
+	ServerSideEncryption *S3ServerSideEncryption `json:"serverSideEncryption,omitempty" nestedEnvPrefix:"_S3_"`
+
+	// Accept the charges of the requests on a requester pays bucket.
+	// +optional
+	RequesterPays *bool `json:"requesterPays,omitempty" env:"_S3_REQUESTER_PAYS"`
+
+	// Tags added to the objects written to the bucket.
+	// +kubebuilder:validation:XValidation:rule="self.all(k, !k.contains(':') && !k.contains('=') && !self[k].contains(':'))",message="tag keys can't contain ':' or '=', values can't contain ':'"
+	// +optional
+	Tags map[string]string `json:"tags,omitempty"`
+
 	// Path where backups and archive are stored.
 	// +kubebuilder:validation:MinLength=1
 	RepoPath string `json:"repoPath" env:"_PATH"`
@@ -219,13 +327,16 @@ type AzureRepository struct {
 	Endpoint string `json:"endpoint" env:"_AZURE_ENDPOINT"`
 
 	// Reference to a Kubernetes Secret containing the Azure repository key.
+	// Only used with the `shared` and `sas` key types.
 	SecretRef *AzureSecretRef `json:"secretRef,omitempty"`
 
-	// Azure repository key type.
+	// Azure repository key type. With `workload-identity`, the pods are
+	// labelled to get the federated token injected by Microsoft Entra
+	// Workload ID.
 	// +kubebuilder:validation:MinLength=1
-	// +kubebuilder:validation:Enum=shared;sas;auto
+	// +kubebuilder:validation:Enum=shared;sas;auto;workload-identity
 	// +optional
-	KeyType string `json:"keyType" env:"_AZURE_KEY_TYPE"`
+	KeyType string `json:"keyType"`
 
 	// Azure URI Style.
 	// +kubebuilder:validation:Enum=host;path
@@ -236,22 +347,46 @@ type AzureRepository struct {
 	// +optional
 	VerifyTLS *bool `json:"verifyTLS" env:"_STORAGE_VERIFY_TLS"`
 
+	// Certificate authorities used to verify the repository storage, e.g. a
+	// private object storage with an internal CA.
+	// +optional
+	CABundle *CABundle `json:"caBundle,omitempty"`
+
+	// Host used to connect to the repository storage, overriding the one of
+	// the endpoint.
+	// +optional
+	StorageHost string `json:"storageHost,omitempty" env:"_STORAGE_HOST"`
+
+	// Port used to connect to the repository storage.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	StoragePort int32 `json:"storagePort,omitempty" env:"_STORAGE_PORT"`
+
 	// Path where backups and archives are stored.
 	// +kubebuilder:validation:MinLength=1
 	RepoPath string `json:"repoPath" env:"_PATH"`
 
 	// +optional
 	RetentionPolicy Retention `json:"retentionPolicy" nestedEnvPrefix:"_RETENTION_"`
+
+	// +optional
+	Cipher *CipherConfig `json:"cipherConfig" nestedEnvPrefix:"_CIPHER_"`
 }
 type ArchiveOption struct {
 
+	// Push and get WAL asynchronously.
+	// A spool volume is mounted on the plugin containers when enabled.
 	// +optional
 	Async bool `json:"async,omitempty,omitzero" env:"_ASYNC"`
 
+	// Maximum size of the PostgreSQL archive queue.
 	// +kubebuilder:validation:Pattern:="^(0B|[0-9]+(KiB|MiB|GiB|TiB)|([0-4])PiB)$"
 	// +optional
 	PushQueueMax *string `json:"pushQueueMax" env:"_PUSH_QUEUE_MAX"`
 
+	// Maximum size of the queue of WAL prefetched by the asynchronous
+	// archive-get, for replica clusters and recovery.
 	// +kubebuilder:validation:Pattern:="^[0-9]+ ?(B|KiB|MiB|GiB|TiB|PiB)$"
 	// +optional
 	GetQueueMax *string `json:"getQueueMax" env:"_GET_QUEUE_MAX"`
@@ -347,9 +482,191 @@ func (r *StanzaConfiguration) ToEnv() ([]string, error) {
 	return envConf, nil
 }
 
+// Deletion policies of a stanza
+const (
+	// DeletionPolicyRetain keeps the data of the repositories when the
+	// Stanza is deleted.
+	DeletionPolicyRetain = "retain"
+	// DeletionPolicyDelete deletes the stanza, its backups and its WAL from
+	// every repository when the Stanza is deleted.
+	DeletionPolicyDelete = "delete"
+)
+
 // StanzaSpec defines the desired state of Stanza
 type StanzaSpec struct {
 	Configuration StanzaConfiguration `json:"stanzaConfiguration"`
+
+	// What happens to the data of the repositories when the Stanza is
+	// deleted: kept (retain) or deleted with pgbackrest stanza-delete
+	// (delete). In both cases, the deletion waits until no Cluster
+	// references the Stanza anymore.
+	// +kubebuilder:validation:Enum=retain;delete
+	// +kubebuilder:default=retain
+	// +optional
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
+
+	// Settings of the Job deleting the stanza from the repositories, with
+	// the delete deletion policy.
+	// +optional
+	DeletionJob *StanzaDeletionJob `json:"deletionJob,omitempty"`
+}
+
+// StanzaDeletionJob configures the Job deleting the stanza from the
+// repositories. The Clusters using the Stanza may be gone by then, so it
+// doesn't run with their ServiceAccount nor their PluginConfig.
+type StanzaDeletionJob struct {
+	// Name of the ServiceAccount the Job runs under. It's required when a
+	// repository is accessed with a web identity or a workload identity,
+	// the ServiceAccount must then be allowed to assume it.
+	// +optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// Name of the PluginConfig, in the namespace of the Stanza, whose
+	// image, pull settings, environment, security context and configuration
+	// rendering apply to the Job.
+	// +optional
+	PluginConfigRef string `json:"pluginConfigRef,omitempty"`
+}
+
+// Phases of a cipher passphrase rotation
+const (
+	// CipherRotationProvisioning: the stanza is being created on the
+	// repository encrypted with the new passphrase.
+	CipherRotationProvisioning = "Provisioning"
+	// CipherRotationSeeding: a full backup is being taken on the new
+	// repository.
+	CipherRotationSeeding = "Seeding"
+	// CipherRotationRetiring: both repositories are used until the backups
+	// of the old one expire according to its retention policy.
+	CipherRotationRetiring = "Retiring"
+)
+
+// CipherRotation is the state of the rotation of the cipher passphrase of a
+// repository. As an encrypted repository can't be re-encrypted, the new
+// passphrase is used on a new repository, with the same storage and a
+// different path.
+type CipherRotation struct {
+	// Phase of the rotation.
+	// +kubebuilder:validation:Enum=Provisioning;Seeding;Retiring
+	Phase string `json:"phase"`
+
+	// Index of the repository encrypted with the new passphrase.
+	Index int `json:"index"`
+
+	// Path of the repository encrypted with the new passphrase.
+	Path string `json:"path"`
+
+	// Fingerprint of the new passphrase.
+	CipherFingerprint string `json:"cipherFingerprint"`
+
+	// Time the rotation started.
+	StartedAt metav1.Time `json:"startedAt"`
+
+	// Label of the full backup seeding the new repository.
+	// +optional
+	SeedBackup string `json:"seedBackup,omitempty"`
+
+	// Time the new repository was seeded.
+	// +optional
+	SeededAt *metav1.Time `json:"seededAt,omitempty"`
+}
+
+// RepositoryStatus is the observed state of an encrypted repository
+type RepositoryStatus struct {
+	// Index of the repository (the pgBackRest repo option).
+	Index int `json:"index"`
+
+	// Fingerprint of the cipher passphrase the repository is encrypted with.
+	CipherFingerprint string `json:"cipherFingerprint"`
+
+	// Reference to the passphrase the repository is encrypted with. It is
+	// still used when the passphrase referenced in the spec changes.
+	// +optional
+	CipherPassReference *machineryapi.SecretKeySelector `json:"cipherPassReference,omitempty"`
+
+	// Path of the repository, when a rotation moved it away from the path of
+	// the spec.
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// Rotation of the passphrase in progress.
+	// +optional
+	Rotation *CipherRotation `json:"rotation,omitempty"`
+}
+
+// StanzaCreation records the creation of the stanza on its repositories
+type StanzaCreation struct {
+	// System identifier of the PostgreSQL cluster the stanza was created for.
+	SystemID string `json:"systemID"`
+
+	// Major version of PostgreSQL the stanza was created for.
+	PGVersion string `json:"pgVersion"`
+
+	// Indexes of the repositories the stanza was created on (the pgBackRest
+	// repo option).
+	// +listType=set
+	Repositories []int `json:"repositories"`
+
+	// Last time the stanza was created on a repository.
+	CreatedAt metav1.Time `json:"createdAt"`
+}
+
+// StanzaOwner is the Cluster allowed to archive WAL and take backups on the
+// stanza
+type StanzaOwner struct {
+	// Name of the Cluster.
+	ClusterName string `json:"clusterName"`
+
+	// UID of the Cluster.
+	ClusterUID types.UID `json:"clusterUID"`
+
+	// System identifier of the PostgreSQL cluster.
+	// +optional
+	SystemID string `json:"systemID,omitempty"`
+
+	// Time the Cluster became the owner of the stanza.
+	Since metav1.Time `json:"since"`
+}
+
+// Roles of a stanza for a Cluster
+const (
+	// StanzaRoleArchive: the Cluster archives its WAL and takes its backups
+	// on the stanza (stanzaRef).
+	StanzaRoleArchive = "archive"
+	// StanzaRoleRecovery: the Cluster is bootstrapped from the stanza.
+	StanzaRoleRecovery = "recovery"
+	// StanzaRoleReplica: the Cluster replicates from the stanza.
+	StanzaRoleReplica = "replica"
+)
+
+// StanzaConsumer is a Cluster referencing the stanza
+type StanzaConsumer struct {
+	// Name of the Cluster.
+	Cluster string `json:"cluster"`
+
+	// Roles of the stanza for the Cluster.
+	// +listType=set
+	Roles []string `json:"roles"`
+}
+
+// StanzaDrain is the outcome of the draining of the archive queue of an
+// instance, when it stopped
+type StanzaDrain struct {
+	// Name of the instance.
+	Instance string `json:"instance"`
+
+	// Time the draining ended.
+	Time metav1.Time `json:"time"`
+
+	// Number of WAL segments pushed.
+	// +optional
+	FlushedSegments int `json:"flushedSegments,omitempty"`
+
+	// WAL segments not pushed, because their push failed or the deadline
+	// was reached.
+	// +listType=atomic
+	// +optional
+	UnflushedSegments []string `json:"unflushedSegments,omitempty"`
 }
 
 // StanzaStatus defines the observed state of Stanza.
@@ -379,10 +696,53 @@ type StanzaStatus struct {
 
 	// +optional
 	Backups BackupsCount `json:"backupsCount"`
+
+	// State of the encrypted repositories.
+	// +listType=map
+	// +listMapKey=index
+	// +optional
+	Repositories []RepositoryStatus `json:"repositories,omitempty"`
+
+	// Creation of the stanza on the repositories, the stanza is only created
+	// on the repositories not listed here.
+	// +optional
+	Creation *StanzaCreation `json:"creation,omitempty"`
+
+	// Cluster owning the stanza, the WAL archiving and backups of the other
+	// clusters are refused.
+	// +optional
+	Owner *StanzaOwner `json:"owner,omitempty"`
+
+	// Clusters referencing the stanza, and the roles of the stanza for them.
+	// +listType=map
+	// +listMapKey=cluster
+	// +optional
+	Consumers []StanzaConsumer `json:"consumers,omitempty"`
+
+	// Number of Clusters referencing the stanza.
+	// +optional
+	ConsumerCount int `json:"consumerCount,omitempty"`
+
+	// Version of pgBackRest last running the WAL archiving or a backup of
+	// the stanza.
+	// +optional
+	PGBackRestVersion string `json:"pgbackrestVersion,omitempty"`
+
+	// Last draining of the archive queue of an instance when it stopped,
+	// only recorded when WAL segments were left in the queue.
+	// +optional
+	LastDrain *StanzaDrain `json:"lastDrain,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Stanza",type=string,JSONPath=`.spec.stanzaConfiguration.name`
+// +kubebuilder:printcolumn:name="Owner",type=string,JSONPath=`.status.owner.clusterName`
+// +kubebuilder:printcolumn:name="Clusters",type=integer,JSONPath=`.status.consumerCount`
+// +kubebuilder:printcolumn:name="Last Backup",type=string,JSONPath=`.status.recoveryWindow.lastBackup.label`
+// +kubebuilder:printcolumn:name="pgBackRest",type=string,JSONPath=`.status.pgbackrestVersion`,priority=1
+// +kubebuilder:printcolumn:name="Deletion Policy",type=string,JSONPath=`.spec.deletionPolicy`,priority=1
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // Stanza is the Schema for the stanzas API
 type Stanza struct {
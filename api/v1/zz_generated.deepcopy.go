@@ -83,7 +83,17 @@ func (in *AzureRepository) DeepCopyInto(out *AzureRepository) {
 		*out = new(bool)
 		**out = **in
 	}
+	if in.CABundle != nil {
+		in, out := &in.CABundle, &out.CABundle
+		*out = new(CABundle)
+		(*in).DeepCopyInto(*out)
+	}
 	out.RetentionPolicy = in.RetentionPolicy
+	if in.Cipher != nil {
+		in, out := &in.Cipher, &out.Cipher
+		*out = new(CipherConfig)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AzureRepository.
@@ -169,6 +179,31 @@ func (in *BackupsCount) DeepCopy() *BackupsCount {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CABundle) DeepCopyInto(out *CABundle) {
+	*out = *in
+	if in.ConfigMapKeyRef != nil {
+		in, out := &in.ConfigMapKeyRef, &out.ConfigMapKeyRef
+		*out = new(api.ConfigMapKeySelector)
+		**out = **in
+	}
+	if in.SecretKeyRef != nil {
+		in, out := &in.SecretKeyRef, &out.SecretKeyRef
+		*out = new(api.SecretKeySelector)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CABundle.
+func (in *CABundle) DeepCopy() *CABundle {
+	if in == nil {
+		return nil
+	}
+	out := new(CABundle)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CipherConfig) DeepCopyInto(out *CipherConfig) {
 	*out = *in
@@ -189,6 +224,26 @@ func (in *CipherConfig) DeepCopy() *CipherConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CipherRotation) DeepCopyInto(out *CipherRotation) {
+	*out = *in
+	in.StartedAt.DeepCopyInto(&out.StartedAt)
+	if in.SeededAt != nil {
+		in, out := &in.SeededAt, &out.SeededAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CipherRotation.
+func (in *CipherRotation) DeepCopy() *CipherRotation {
+	if in == nil {
+		return nil
+	}
+	out := new(CipherRotation)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CompressConfig) DeepCopyInto(out *CompressConfig) {
 	*out = *in
@@ -217,6 +272,26 @@ func (in *ExporterConfig) DeepCopyInto(out *ExporterConfig) {
 		*out = new(corev1.ResourceRequirements)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.StanzaInclude != nil {
+		in, out := &in.StanzaInclude, &out.StanzaInclude
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.StanzaExclude != nil {
+		in, out := &in.StanzaExclude, &out.StanzaExclude
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.WebConfig != nil {
+		in, out := &in.WebConfig, &out.WebConfig
+		*out = new(api.SecretKeySelector)
+		**out = **in
+	}
+	if in.PodMonitor != nil {
+		in, out := &in.PodMonitor, &out.PodMonitor
+		*out = new(ExporterPodMonitor)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExporterConfig.
@@ -229,6 +304,78 @@ func (in *ExporterConfig) DeepCopy() *ExporterConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExporterPodMonitor) DeepCopyInto(out *ExporterPodMonitor) {
+	*out = *in
+	if in.TLSConfig != nil {
+		in, out := &in.TLSConfig, &out.TLSConfig
+		*out = new(ExporterPodMonitorTLSConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BasicAuth != nil {
+		in, out := &in.BasicAuth, &out.BasicAuth
+		*out = new(ExporterPodMonitorBasicAuth)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExporterPodMonitor.
+func (in *ExporterPodMonitor) DeepCopy() *ExporterPodMonitor {
+	if in == nil {
+		return nil
+	}
+	out := new(ExporterPodMonitor)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExporterPodMonitorBasicAuth) DeepCopyInto(out *ExporterPodMonitorBasicAuth) {
+	*out = *in
+	out.Username = in.Username
+	out.Password = in.Password
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExporterPodMonitorBasicAuth.
+func (in *ExporterPodMonitorBasicAuth) DeepCopy() *ExporterPodMonitorBasicAuth {
+	if in == nil {
+		return nil
+	}
+	out := new(ExporterPodMonitorBasicAuth)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExporterPodMonitorTLSConfig) DeepCopyInto(out *ExporterPodMonitorTLSConfig) {
+	*out = *in
+	if in.CA != nil {
+		in, out := &in.CA, &out.CA
+		*out = new(CABundle)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CertSecretRef != nil {
+		in, out := &in.CertSecretRef, &out.CertSecretRef
+		*out = new(api.SecretKeySelector)
+		**out = **in
+	}
+	if in.KeySecretRef != nil {
+		in, out := &in.KeySecretRef, &out.KeySecretRef
+		*out = new(api.SecretKeySelector)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExporterPodMonitorTLSConfig.
+func (in *ExporterPodMonitorTLSConfig) DeepCopy() *ExporterPodMonitorTLSConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ExporterPodMonitorTLSConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Lsn) DeepCopyInto(out *Lsn) {
 	*out = *in
@@ -310,6 +457,37 @@ func (in *PluginConfigSpec) DeepCopyInto(out *PluginConfigSpec) {
 		*out = new(corev1.ResourceRequirements)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.ImagePullSecrets != nil {
+		in, out := &in.ImagePullSecrets, &out.ImagePullSecrets
+		*out = make([]corev1.LocalObjectReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make([]corev1.EnvVar, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.EnvFrom != nil {
+		in, out := &in.EnvFrom, &out.EnvFrom
+		*out = make([]corev1.EnvFromSource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.AdditionalVolumeMounts != nil {
+		in, out := &in.AdditionalVolumeMounts, &out.AdditionalVolumeMounts
+		*out = make([]corev1.VolumeMount, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.SecurityContext != nil {
+		in, out := &in.SecurityContext, &out.SecurityContext
+		*out = new(corev1.SecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.StorageConfig != nil {
 		in, out := &in.StorageConfig, &out.StorageConfig
 		*out = new(StorageConfig)
@@ -320,6 +498,16 @@ func (in *PluginConfigSpec) DeepCopyInto(out *PluginConfigSpec) {
 		*out = new(ExporterConfig)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.RestoreConfig != nil {
+		in, out := &in.RestoreConfig, &out.RestoreConfig
+		*out = new(RestoreConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DrainTimeout != nil {
+		in, out := &in.DrainTimeout, &out.DrainTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PluginConfigSpec.
@@ -349,6 +537,51 @@ func (in *RecoveryWindow) DeepCopy() *RecoveryWindow {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RepositoryStatus) DeepCopyInto(out *RepositoryStatus) {
+	*out = *in
+	if in.CipherPassReference != nil {
+		in, out := &in.CipherPassReference, &out.CipherPassReference
+		*out = new(api.SecretKeySelector)
+		**out = **in
+	}
+	if in.Rotation != nil {
+		in, out := &in.Rotation, &out.Rotation
+		*out = new(CipherRotation)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RepositoryStatus.
+func (in *RepositoryStatus) DeepCopy() *RepositoryStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RepositoryStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RestoreConfig) DeepCopyInto(out *RestoreConfig) {
+	*out = *in
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(corev1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RestoreConfig.
+func (in *RestoreConfig) DeepCopy() *RestoreConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(RestoreConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Retention) DeepCopyInto(out *Retention) {
 	*out = *in
@@ -367,11 +600,38 @@ func (in *Retention) DeepCopy() *Retention {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *S3Repository) DeepCopyInto(out *S3Repository) {
 	*out = *in
+	if in.CABundle != nil {
+		in, out := &in.CABundle, &out.CABundle
+		*out = new(CABundle)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.SecretRef != nil {
 		in, out := &in.SecretRef, &out.SecretRef
 		*out = new(S3SecretRef)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.WebIdentity != nil {
+		in, out := &in.WebIdentity, &out.WebIdentity
+		*out = new(S3WebIdentity)
+		**out = **in
+	}
+	if in.ServerSideEncryption != nil {
+		in, out := &in.ServerSideEncryption, &out.ServerSideEncryption
+		*out = new(S3ServerSideEncryption)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RequesterPays != nil {
+		in, out := &in.RequesterPays, &out.RequesterPays
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	out.RetentionPolicy = in.RetentionPolicy
 	if in.Cipher != nil {
 		in, out := &in.Cipher, &out.Cipher
@@ -415,6 +675,41 @@ func (in *S3SecretRef) DeepCopy() *S3SecretRef {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *S3ServerSideEncryption) DeepCopyInto(out *S3ServerSideEncryption) {
+	*out = *in
+	if in.CustomerKeyReference != nil {
+		in, out := &in.CustomerKeyReference, &out.CustomerKeyReference
+		*out = new(api.SecretKeySelector)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new S3ServerSideEncryption.
+func (in *S3ServerSideEncryption) DeepCopy() *S3ServerSideEncryption {
+	if in == nil {
+		return nil
+	}
+	out := new(S3ServerSideEncryption)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *S3WebIdentity) DeepCopyInto(out *S3WebIdentity) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new S3WebIdentity.
+func (in *S3WebIdentity) DeepCopy() *S3WebIdentity {
+	if in == nil {
+		return nil
+	}
+	out := new(S3WebIdentity)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Stanza) DeepCopyInto(out *Stanza) {
 	*out = *in
@@ -484,6 +779,42 @@ func (in *StanzaConfiguration) DeepCopy() *StanzaConfiguration {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StanzaDeletionJob) DeepCopyInto(out *StanzaDeletionJob) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StanzaDeletionJob.
+func (in *StanzaDeletionJob) DeepCopy() *StanzaDeletionJob {
+	if in == nil {
+		return nil
+	}
+	out := new(StanzaDeletionJob)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StanzaDrain) DeepCopyInto(out *StanzaDrain) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+	if in.UnflushedSegments != nil {
+		in, out := &in.UnflushedSegments, &out.UnflushedSegments
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StanzaDrain.
+func (in *StanzaDrain) DeepCopy() *StanzaDrain {
+	if in == nil {
+		return nil
+	}
+	out := new(StanzaDrain)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *StanzaList) DeepCopyInto(out *StanzaList) {
 	*out = *in
@@ -516,10 +847,31 @@ func (in *StanzaList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StanzaOwner) DeepCopyInto(out *StanzaOwner) {
+	*out = *in
+	in.Since.DeepCopyInto(&out.Since)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StanzaOwner.
+func (in *StanzaOwner) DeepCopy() *StanzaOwner {
+	if in == nil {
+		return nil
+	}
+	out := new(StanzaOwner)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *StanzaSpec) DeepCopyInto(out *StanzaSpec) {
 	*out = *in
 	in.Configuration.DeepCopyInto(&out.Configuration)
+	if in.DeletionJob != nil {
+		in, out := &in.DeletionJob, &out.DeletionJob
+		*out = new(StanzaDeletionJob)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StanzaSpec.
@@ -532,6 +884,47 @@ func (in *StanzaSpec) DeepCopy() *StanzaSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StanzaConsumer) DeepCopyInto(out *StanzaConsumer) {
+	*out = *in
+	if in.Roles != nil {
+		in, out := &in.Roles, &out.Roles
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StanzaConsumer.
+func (in *StanzaConsumer) DeepCopy() *StanzaConsumer {
+	if in == nil {
+		return nil
+	}
+	out := new(StanzaConsumer)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StanzaCreation) DeepCopyInto(out *StanzaCreation) {
+	*out = *in
+	if in.Repositories != nil {
+		in, out := &in.Repositories, &out.Repositories
+		*out = make([]int, len(*in))
+		copy(*out, *in)
+	}
+	in.CreatedAt.DeepCopyInto(&out.CreatedAt)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StanzaCreation.
+func (in *StanzaCreation) DeepCopy() *StanzaCreation {
+	if in == nil {
+		return nil
+	}
+	out := new(StanzaCreation)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *StanzaStatus) DeepCopyInto(out *StanzaStatus) {
 	*out = *in
@@ -544,6 +937,35 @@ func (in *StanzaStatus) DeepCopyInto(out *StanzaStatus) {
 	}
 	out.RecoveryWindow = in.RecoveryWindow
 	out.Backups = in.Backups
+	if in.Repositories != nil {
+		in, out := &in.Repositories, &out.Repositories
+		*out = make([]RepositoryStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Creation != nil {
+		in, out := &in.Creation, &out.Creation
+		*out = new(StanzaCreation)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Owner != nil {
+		in, out := &in.Owner, &out.Owner
+		*out = new(StanzaOwner)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Consumers != nil {
+		in, out := &in.Consumers, &out.Consumers
+		*out = make([]StanzaConsumer, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastDrain != nil {
+		in, out := &in.LastDrain, &out.LastDrain
+		*out = new(StanzaDrain)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StanzaStatus.
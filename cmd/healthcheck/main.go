@@ -30,6 +30,7 @@ func NewCmd() *cobra.Command {
 }
 
 func unixHealthCheck() *cobra.Command {
+	var service string
 	cmd := &cobra.Command{
 		Use:   "unix",
 		Short: "executes the health check command on unix:///plugins/pgbackrest.dalibo.com",
@@ -47,7 +48,7 @@ func unixHealthCheck() *cobra.Command {
 			healthCli := grpc_health_v1.NewHealthClient(cli)
 			res, healthErr := healthCli.Check(
 				cmd.Context(),
-				&grpc_health_v1.HealthCheckRequest{},
+				&grpc_health_v1.HealthCheckRequest{Service: service},
 			)
 			if healthErr != nil {
 				log.Error(healthErr, "while executing the healthcheck call")
@@ -77,5 +78,8 @@ func unixHealthCheck() *cobra.Command {
 		},
 	}
 
+	cmd.Flags().StringVar(&service, "service", "",
+		"the service to check: pgbackrest, stanza or archive, the readiness of the plugin when empty")
+
 	return cmd
 }
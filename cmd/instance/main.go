@@ -8,6 +8,7 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/config"
 	instance_pgbackrest "github.com/dalibo/cnpg-i-pgbackrest/internal/instance"
 )
 
@@ -37,6 +38,7 @@ func NewCmd() *cobra.Command {
 	_ = viper.BindEnv("pod-name", "POD_NAME")
 	_ = viper.BindEnv("pgdata", "PGDATA")
 	_ = viper.BindEnv("spool-directory", "SPOOL_DIRECTORY")
+	_ = viper.BindEnv("drain-timeout", config.DrainTimeoutEnv)
 
 	return cmd
 }
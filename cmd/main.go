@@ -18,7 +18,9 @@ import (
 	"github.com/dalibo/cnpg-i-pgbackrest/cmd/healthcheck"
 	"github.com/dalibo/cnpg-i-pgbackrest/cmd/instance"
 	"github.com/dalibo/cnpg-i-pgbackrest/cmd/operator"
+	"github.com/dalibo/cnpg-i-pgbackrest/cmd/rebuild"
 	"github.com/dalibo/cnpg-i-pgbackrest/cmd/restore"
+	"github.com/dalibo/cnpg-i-pgbackrest/cmd/stanzadelete"
 )
 
 func main() {
@@ -38,8 +40,10 @@ func main() {
 	rootCmd.AddCommand(operator.NewCmd())
 	rootCmd.AddCommand(instance.NewCmd())
 	rootCmd.AddCommand(restore.NewCmd())
+	rootCmd.AddCommand(rebuild.NewCmd())
 	rootCmd.AddCommand(exporter.NewCmd())
 	rootCmd.AddCommand(healthcheck.NewCmd())
+	rootCmd.AddCommand(stanzadelete.NewCmd())
 
 	if err := rootCmd.ExecuteContext(ctrl.SetupSignalHandler()); err != nil {
 		if !errors.Is(err, context.Canceled) {
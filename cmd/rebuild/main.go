@@ -0,0 +1,45 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package rebuild
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	restore_pgbackrest "github.com/dalibo/cnpg-i-pgbackrest/internal/restore"
+)
+
+// NewCmd creates a new rebuild command
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rebuild",
+		Short: "Rebuilds an existing PGDATA with a pgBackRest delta restore",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			requiredSettings := []string{
+				"namespace",
+				"pod-name",
+				"cluster-name",
+				"pgdata",
+			}
+
+			for _, k := range requiredSettings {
+				if len(viper.GetString(k)) == 0 {
+					return fmt.Errorf("missing required %s setting", k)
+				}
+			}
+
+			return restore_pgbackrest.Rebuild(cmd.Context())
+		},
+	}
+
+	_ = viper.BindEnv("namespace", "NAMESPACE")
+	_ = viper.BindEnv("pod-name", "POD_NAME")
+	_ = viper.BindEnv("cluster-name", "CLUSTER_NAME")
+	_ = viper.BindEnv("pgdata", "PGDATA")
+
+	return cmd
+}
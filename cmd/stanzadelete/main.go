@@ -0,0 +1,41 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package stanzadelete
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/cleanup"
+)
+
+// NewCmd creates a new stanza-delete command
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "stanza-delete",
+		Short: "Deletes a stanza and its data from its repositories",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			requiredSettings := []string{
+				"stanza",
+				"repositories",
+			}
+
+			for _, k := range requiredSettings {
+				if len(viper.GetString(k)) == 0 {
+					return fmt.Errorf("missing required %s setting", k)
+				}
+			}
+
+			return cleanup.DeleteStanza(cmd.Context())
+		},
+	}
+
+	_ = viper.BindEnv("stanza", "PGBACKREST_STANZA")
+	_ = viper.BindEnv("repositories", cleanup.RepositoriesEnv)
+
+	return cmd
+}
@@ -0,0 +1,116 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package cleanup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/config"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/pgbackrest"
+	"github.com/spf13/viper"
+)
+
+// RepositoriesEnv lists (comma separated) the repositories the stanza is
+// deleted from
+const RepositoriesEnv = "PLUGIN_DELETE_REPOSITORIES"
+
+// EnvDirectoryEnv points to the directory holding the pgBackRest
+// environment of the Job, one file per variable, when its configuration is
+// rendered to files: the credentials then stay out of the process
+// environment.
+const EnvDirectoryEnv = "PLUGIN_ENV_DIRECTORY"
+
+// readEnvDirectory reads the variables of the environment stored in dir,
+// the hidden entries of a mounted Secret are skipped
+func readEnvDirectory(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var env []string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		value, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		env = append(env, entry.Name()+"="+string(value))
+	}
+	return env, nil
+}
+
+// newRunner builds the pgBackRest runner of the Job. Its environment is
+// inherited, unless the configuration is rendered to files.
+func newRunner() (*pgbackrest.PgBackrestRunner, error) {
+	dir := os.Getenv(config.ConfigDirectoryEnv)
+	if dir == "" {
+		return pgbackrest.NewPgBackrest(nil), nil
+	}
+	env, err := readEnvDirectory(os.Getenv(EnvDirectoryEnv))
+	if err != nil {
+		return nil, fmt.Errorf("can't read the pgBackRest environment: %w", err)
+	}
+	pgb := pgbackrest.NewPgBackrest(env)
+	if err := pgb.RenderConfig(dir); err != nil {
+		return nil, err
+	}
+	return pgb, nil
+}
+
+// parseRepositories parses the comma separated list of repository indexes
+func parseRepositories(value string) ([]int, error) {
+	var repos []int
+	for field := range strings.SplitSeq(value, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		repo, err := strconv.Atoi(field)
+		if err != nil || repo < 1 {
+			return nil, fmt.Errorf("invalid repository %q", field)
+		}
+		repos = append(repos, repo)
+	}
+	if len(repos) == 0 {
+		return nil, fmt.Errorf("no repository to delete the stanza from")
+	}
+	return repos, nil
+}
+
+// DeleteStanza stops the stanza, then deletes it from each repository. It
+// runs in the Job started by the operator when a Stanza with the delete
+// policy is deleted, the pgBackRest options are given by its environment.
+func DeleteStanza(ctx context.Context) error {
+	contextLogger := log.FromContext(ctx).WithName("stanza-delete")
+	stanza := viper.GetString("stanza")
+	repos, err := parseRepositories(viper.GetString("repositories"))
+	if err != nil {
+		return err
+	}
+
+	pgb, err := newRunner()
+	if err != nil {
+		return err
+	}
+	contextLogger.Info("stopping stanza", "stanza", stanza)
+	if err := pgb.StopStanza(ctx, stanza); err != nil {
+		return err
+	}
+	for _, repo := range repos {
+		contextLogger.Info("deleting stanza", "stanza", stanza, "repository", repo)
+		if err := pgb.DeleteStanza(ctx, stanza, repo); err != nil {
+			return err
+		}
+	}
+	contextLogger.Info("stanza deleted", "stanza", stanza)
+	return nil
+}
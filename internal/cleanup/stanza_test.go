@@ -0,0 +1,56 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+package cleanup
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestParseRepositories(t *testing.T) {
+	testCases := []struct {
+		value   string
+		want    []int
+		wantErr bool
+	}{
+		{"1", []int{1}, false},
+		{"1, 2,4", []int{1, 2, 4}, false},
+		{"", nil, true},
+		{"1,x", nil, true},
+		{"0", nil, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			got, err := parseRepositories(tc.value)
+			if (err != nil) != tc.wantErr || !slices.Equal(got, tc.want) {
+				t.Errorf("want %v (error %v), got %v (%v)", tc.want, tc.wantErr, got, err)
+			}
+		})
+	}
+}
+
+func TestReadEnvDirectory(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"PGBACKREST_STANZA":       "main",
+		"PGBACKREST_REPO1_S3_KEY": "key",
+		// the hidden entries of a mounted Secret
+		"..data": "",
+	}
+	for name, value := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(value), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	env, err := readEnvDirectory(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"PGBACKREST_REPO1_S3_KEY=key", "PGBACKREST_STANZA=main"}
+	if !slices.Equal(env, want) {
+		t.Errorf("want %v, got %v", want, env)
+	}
+}
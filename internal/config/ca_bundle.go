@@ -0,0 +1,43 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path"
+
+	pgbackrestapi "github.com/dalibo/cnpg-i-pgbackrest/api/v1"
+)
+
+// CABundleDirectory is where the operator mounts the ConfigMaps and Secrets
+// holding the CA bundles of the repositories.
+const CABundleDirectory = "/etc/pgbackrest/ca"
+
+// CABundleSource returns the kind ("configmap" or "secret"), the name and
+// the key of the object holding the bundle.
+func CABundleSource(b *pgbackrestapi.CABundle) (kind string, name string, key string) {
+	if b.ConfigMapKeyRef != nil {
+		return "configmap", b.ConfigMapKeyRef.Name, b.ConfigMapKeyRef.Key
+	}
+	if b.SecretKeyRef != nil {
+		return "secret", b.SecretKeyRef.Name, b.SecretKeyRef.Key
+	}
+	return "", "", ""
+}
+
+// CABundleVolumeName returns the name of the volume of the object holding
+// the bundle. Repositories sharing the object share the volume.
+func CABundleVolumeName(b *pgbackrestapi.CABundle) string {
+	kind, name, _ := CABundleSource(b)
+	sum := sha256.Sum256([]byte(kind + "/" + name))
+	return "pgbackrest-ca-" + hex.EncodeToString(sum[:8])
+}
+
+// CABundleFile returns the path of the bundle in the plugin containers.
+func CABundleFile(b *pgbackrestapi.CABundle) string {
+	_, _, key := CABundleSource(b)
+	return path.Join(CABundleDirectory, CABundleVolumeName(b), key)
+}
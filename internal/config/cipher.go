@@ -0,0 +1,215 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	machineryapi "github.com/cloudnative-pg/machinery/pkg/api"
+	pgbackrestapi "github.com/dalibo/cnpg-i-pgbackrest/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ErrCipherPassphraseChanged is returned when the passphrase referenced by
+// an encrypted repository isn't the one it was encrypted with, and that one
+// can't be read anymore.
+var ErrCipherPassphraseChanged = errors.New("cipher passphrase changed")
+
+// RepositoryCipher is the encryption of a repository as defined in the spec
+type RepositoryCipher struct {
+	Index         int
+	Path          string
+	Fingerprint   string
+	PassReference *machineryapi.SecretKeySelector
+	Retention     pgbackrestapi.Retention
+}
+
+// CipherFingerprint identifies a passphrase of the stanza without disclosing
+// it, the stanza UID is used as salt.
+func CipherFingerprint(stanza *pgbackrestapi.Stanza, pass string) string {
+	sum := sha256.Sum256([]byte(string(stanza.UID) + "\x00" + pass))
+	return hex.EncodeToString(sum[:8])
+}
+
+// RepositoryCiphers returns the encryption of the repositories of the
+// stanza, indexed as in the pgBackRest configuration.
+func RepositoryCiphers(
+	ctx context.Context,
+	c client.Client,
+	stanza *pgbackrestapi.Stanza,
+) ([]RepositoryCipher, error) {
+	type repo struct {
+		path      string
+		cipher    *pgbackrestapi.CipherConfig
+		retention pgbackrestapi.Retention
+	}
+	conf := &stanza.Spec.Configuration
+	repos := make([]repo, 0, len(conf.S3Repositories)+len(conf.AzureRepositories))
+	for _, r := range conf.S3Repositories {
+		repos = append(repos, repo{r.RepoPath, r.Cipher, r.RetentionPolicy})
+	}
+	for _, r := range conf.AzureRepositories {
+		repos = append(repos, repo{r.RepoPath, r.Cipher, r.RetentionPolicy})
+	}
+
+	var ciphers []RepositoryCipher
+	for i, r := range repos {
+		if r.cipher == nil || r.cipher.PassReference == nil {
+			continue
+		}
+		pass, err := decodeSecretVal(ctx, c, stanza.Namespace, r.cipher.PassReference)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode cipher secret: %w", err)
+		}
+		ciphers = append(ciphers, RepositoryCipher{
+			Index:         i + 1,
+			Path:          r.path,
+			Fingerprint:   CipherFingerprint(stanza, pass),
+			PassReference: r.cipher.PassReference,
+			Retention:     r.retention,
+		})
+	}
+	return ciphers, nil
+}
+
+// recordedPassphrase returns the passphrase the repository was encrypted
+// with, read from the reference recorded in the status.
+func recordedPassphrase(
+	ctx context.Context,
+	c client.Client,
+	stanza *pgbackrestapi.Stanza,
+	status *pgbackrestapi.RepositoryStatus,
+) (string, error) {
+	if status.CipherPassReference != nil {
+		pass, err := decodeSecretVal(ctx, c, stanza.Namespace, status.CipherPassReference)
+		if err == nil && CipherFingerprint(stanza, pass) == status.CipherFingerprint {
+			return pass, nil
+		}
+	}
+	return "", fmt.Errorf(
+		"%w: repository %d is encrypted with another passphrase, restore it or reference it again",
+		ErrCipherPassphraseChanged,
+		status.Index,
+	)
+}
+
+// RecordedPassphraseAvailable reports whether the passphrase the repository
+// was encrypted with can still be read.
+func RecordedPassphraseAvailable(
+	ctx context.Context,
+	c client.Client,
+	stanza *pgbackrestapi.Stanza,
+	status *pgbackrestapi.RepositoryStatus,
+) bool {
+	_, err := recordedPassphrase(ctx, c, stanza, status)
+	return err == nil
+}
+
+// BackupRepository returns the repository where backups requested on the
+// given one are taken: the new repository once a rotation seeded it.
+func BackupRepository(stanza *pgbackrestapi.Stanza, index int) int {
+	for _, st := range stanza.Status.Repositories {
+		if st.Index == index && st.Rotation != nil &&
+			st.Rotation.Phase == pgbackrestapi.CipherRotationRetiring {
+			return st.Rotation.Index
+		}
+	}
+	return index
+}
+
+// RepositoryIndexes returns the indexes of the repositories of the stanza,
+// including the new repositories of the passphrase rotations.
+func RepositoryIndexes(stanza *pgbackrestapi.Stanza) []int {
+	conf := &stanza.Spec.Configuration
+	count := len(conf.S3Repositories) + len(conf.AzureRepositories)
+	indexes := make([]int, 0, count)
+	for i := range count {
+		indexes = append(indexes, i+1)
+	}
+	for _, st := range stanza.Status.Repositories {
+		if st.Rotation != nil {
+			indexes = append(indexes, st.Rotation.Index)
+		}
+	}
+	return indexes
+}
+
+func repoPrefix(index int) string {
+	return fmt.Sprintf("PGBACKREST_REPO%d_", index)
+}
+
+func lookupEnv(env []string, name string) (string, bool) {
+	for _, e := range env {
+		if k, v, _ := strings.Cut(e, "="); k == name {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+func setEnv(env []string, name string, value string) []string {
+	for i, e := range env {
+		if k, _, _ := strings.Cut(e, "="); k == name {
+			env[i] = name + "=" + value
+			return env
+		}
+	}
+	return append(env, name+"="+value)
+}
+
+// applyRepositoryStatus adapts the environment of the encrypted repositories
+// to their status. A repository keeps the passphrase it was encrypted with
+// when the one of the spec changed, the passphrase of the spec is never used
+// on it. During a rotation, a new repository encrypted with the passphrase
+// of the spec is added with the same options and another path.
+func applyRepositoryStatus(
+	ctx context.Context,
+	c client.Client,
+	stanza *pgbackrestapi.Stanza,
+	env []string,
+) ([]string, error) {
+	for i := range stanza.Status.Repositories {
+		st := &stanza.Status.Repositories[i]
+		prefix := repoPrefix(st.Index)
+		specPass, ok := lookupEnv(env, prefix+"CIPHER_PASS")
+		if !ok {
+			// the repository isn't encrypted anymore, or was removed
+			continue
+		}
+		specFingerprint := CipherFingerprint(stanza, specPass)
+
+		var repoEnv []string
+		for _, e := range env {
+			if strings.HasPrefix(e, prefix) {
+				repoEnv = append(repoEnv, e)
+			}
+		}
+
+		if specFingerprint != st.CipherFingerprint {
+			pass, err := recordedPassphrase(ctx, c, stanza, st)
+			if err != nil {
+				return nil, err
+			}
+			env = setEnv(env, prefix+"CIPHER_PASS", pass)
+		}
+		if st.Path != "" {
+			env = setEnv(env, prefix+"PATH", st.Path)
+		}
+
+		if r := st.Rotation; r != nil && r.CipherFingerprint == specFingerprint {
+			newPrefix := repoPrefix(r.Index)
+			for _, e := range repoEnv {
+				env = append(env, newPrefix+strings.TrimPrefix(e, prefix))
+			}
+			env = setEnv(env, newPrefix+"PATH", r.Path)
+		}
+	}
+	return env, nil
+}
@@ -7,6 +7,10 @@ package config
 import (
 	"context"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
 
 	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
 	"github.com/cloudnative-pg/cnpg-i-machinery/pkg/pluginhelper/decoder"
@@ -211,10 +215,57 @@ func decodeSecretVal(
 	return string(raw), nil
 }
 
+// generationClient is implemented by the clients keeping a snapshot of the
+// objects they read, whose generation changes with any of them.
+type generationClient interface {
+	Generation() uint64
+}
+
+type cachedEnv struct {
+	generation      uint64
+	resourceVersion string
+	env             []string
+}
+
+// envCache holds the environment of the stanzas, by UID, built from the
+// snapshots of a generationClient.
+var envCache sync.Map
+
+// GetEnvVarConfig returns the pgBackRest environment of the stanza. With a
+// client keeping snapshots of the objects, the environment is only rebuilt
+// when the stanza or one of the watched objects changed.
 func GetEnvVarConfig(
 	ctx context.Context,
 	stanza *pgbackrestapi.Stanza,
 	c client.Client,
+) ([]string, error) {
+	gc, ok := c.(generationClient)
+	if !ok || stanza.UID == "" {
+		return buildEnvVarConfig(ctx, stanza, c)
+	}
+	generation := gc.Generation()
+	if v, ok := envCache.Load(stanza.UID); ok {
+		cached := v.(cachedEnv)
+		if cached.generation == generation && cached.resourceVersion == stanza.ResourceVersion {
+			return slices.Clone(cached.env), nil
+		}
+	}
+	env, err := buildEnvVarConfig(ctx, stanza, c)
+	if err != nil {
+		return nil, err
+	}
+	envCache.Store(stanza.UID, cachedEnv{
+		generation:      generation,
+		resourceVersion: stanza.ResourceVersion,
+		env:             slices.Clone(env),
+	})
+	return env, nil
+}
+
+func buildEnvVarConfig(
+	ctx context.Context,
+	stanza *pgbackrestapi.Stanza,
+	c client.Client,
 ) ([]string, error) {
 	conf := stanza.Spec.Configuration
 	ns := stanza.Namespace
@@ -238,7 +289,7 @@ func GetEnvVarConfig(
 		return nil, err
 	}
 	env = append(env, azureEnv...)
-	return env, nil
+	return applyRepositoryStatus(ctx, c, stanza, env)
 }
 
 func getEnvVarForS3(
@@ -251,7 +302,9 @@ func getEnvVarForS3(
 	s3env := make([]string, 0, len(repositories))
 	for i, r := range repositories {
 		prefix := fmt.Sprintf("PGBACKREST_REPO%d_", startId+i)
-		if sRef := r.SecretRef; sRef != nil {
+		// temporary credentials are retrieved by pgBackRest with the other key types
+		sharedKey := r.KeyType == "" || r.KeyType == pgbackrestapi.S3KeyTypeShared
+		if sRef := r.SecretRef; sRef != nil && sharedKey {
 			aKey, err := decodeSecretVal(ctx, c, ns, sRef.AccessKeyIDReference)
 			if err != nil {
 				return nil, fmt.Errorf("cannot decode S3 secret: %w (missing or invalid)", err)
@@ -273,12 +326,43 @@ func getEnvVarForS3(
 			}
 			s3env = append(s3env, fmt.Sprintf("%sCIPHER_PASS=%s", prefix, encKey))
 		}
+		if sse := r.ServerSideEncryption; sse != nil && sse.CustomerKeyReference != nil {
+			sseKey, err := decodeSecretVal(ctx, c, ns, sse.CustomerKeyReference)
+			if err != nil {
+				return nil, fmt.Errorf("cannot decode SSE-C key: %w (missing or invalid)", err)
+			}
+			s3env = append(s3env, fmt.Sprintf("%sS3_SSE_CUSTOMER_KEY=%s", prefix, sseKey))
+		}
+		if len(r.Tags) > 0 {
+			s3env = append(s3env, fmt.Sprintf("%sSTORAGE_TAG=%s", prefix, storageTags(r.Tags)))
+		}
+		if r.CABundle != nil {
+			s3env = append(s3env, fmt.Sprintf("%sSTORAGE_CA_FILE=%s", prefix, CABundleFile(r.CABundle)))
+		}
 		// build env var names
 		s3env = append(s3env, fmt.Sprintf("%sTYPE=s3", prefix))
 	}
 	return s3env, nil
 }
 
+// storageTags formats the tags as the value of the repo-storage-tag option
+// given by the environment, key=value pairs separated by colons.
+func storageTags(tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+	for _, k := range slices.Sorted(maps.Keys(tags)) {
+		pairs = append(pairs, k+"="+tags[k])
+	}
+	return strings.Join(pairs, ":")
+}
+
+// AzureKeyFromSecret reports whether the Azure key type uses the key of the
+// repository secret, the other key types retrieve a token by themselves.
+func AzureKeyFromSecret(keyType string) bool {
+	return keyType == "" ||
+		keyType == pgbackrestapi.AzureKeyTypeShared ||
+		keyType == pgbackrestapi.AzureKeyTypeSAS
+}
+
 func getEnvVarForAzure(
 	ctx context.Context,
 	c client.Client,
@@ -289,7 +373,7 @@ func getEnvVarForAzure(
 	azureEnv := make([]string, 0, len(repositories))
 	for i, r := range repositories {
 		prefix := fmt.Sprintf("PGBACKREST_REPO%d_", (startId + i))
-		if sRef := r.SecretRef; sRef != nil {
+		if sRef := r.SecretRef; sRef != nil && AzureKeyFromSecret(r.KeyType) {
 			sKey, err := decodeSecretVal(ctx, c, ns, sRef.KeyReference)
 			if err != nil {
 				return nil, fmt.Errorf("cannot decode Azure secret: %w (missing or invalid)", err)
@@ -297,6 +381,23 @@ func getEnvVarForAzure(
 
 			azureEnv = append(azureEnv, fmt.Sprintf("%sAZURE_KEY=%s", prefix, sKey))
 		}
+		switch r.KeyType {
+		case "":
+		case pgbackrestapi.AzureKeyTypeWorkloadIdentity:
+			azureEnv = append(azureEnv, fmt.Sprintf("%sAZURE_KEY_TYPE=%s", prefix, pgbackrestapi.AzureKeyTypeAuto))
+		default:
+			azureEnv = append(azureEnv, fmt.Sprintf("%sAZURE_KEY_TYPE=%s", prefix, r.KeyType))
+		}
+		if r.Cipher != nil {
+			encKey, err := decodeSecretVal(ctx, c, ns, r.Cipher.PassReference)
+			if err != nil {
+				return nil, fmt.Errorf("cannot decode cipher secret: %w", err)
+			}
+			azureEnv = append(azureEnv, fmt.Sprintf("%sCIPHER_PASS=%s", prefix, encKey))
+		}
+		if r.CABundle != nil {
+			azureEnv = append(azureEnv, fmt.Sprintf("%sSTORAGE_CA_FILE=%s", prefix, CABundleFile(r.CABundle)))
+		}
 		azureEnv = append(azureEnv, fmt.Sprintf("%sTYPE=azure", prefix))
 	}
 	return azureEnv, nil
@@ -6,6 +6,7 @@ package config
 
 import (
 	"context"
+	"errors"
 	"slices"
 	"strings"
 	"testing"
@@ -17,6 +18,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
@@ -28,6 +30,13 @@ func init() {
 }
 
 func buildFakeClient() client.Client {
+	return fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(buildFakeClientObjects()...).
+		Build()
+}
+
+func buildFakeClientObjects() []client.Object {
 	aKey := &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "access-key-secret",
@@ -46,6 +55,15 @@ func buildFakeClient() client.Client {
 			"key": []byte("SECRET123"),
 		},
 	}
+	sseKey := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "sse-key-secret",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			"key": []byte("c3NlLWM="),
+		},
+	}
 	azureKey := &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "azure-key-secret",
@@ -55,10 +73,7 @@ func buildFakeClient() client.Client {
 			"key": []byte("MYAZURESECRET123"),
 		},
 	}
-	return fake.NewClientBuilder().
-		WithScheme(scheme).
-		WithObjects(aKey, sKey, azureKey).
-		Build()
+	return []client.Object{aKey, sKey, sseKey, azureKey}
 }
 func buildStanza() *pgbackrestapi.Stanza {
 	return &pgbackrestapi.Stanza{
@@ -167,3 +182,282 @@ func TestGetEnvVarConfig_MissingSecret(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func TestGetEnvVarConfig_S3KeyType(t *testing.T) {
+	tests := []struct {
+		keyType    string
+		expectKeys bool
+	}{
+		{"", true},
+		{pgbackrestapi.S3KeyTypeShared, true},
+		{pgbackrestapi.S3KeyTypeAuto, false},
+		{pgbackrestapi.S3KeyTypeWebID, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.keyType, func(t *testing.T) {
+			s := buildStanza()
+			s.Spec.Configuration.S3Repositories[0].KeyType = tt.keyType
+			env, err := GetEnvVarConfig(context.Background(), s, buildFakeClient())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := slices.Contains(env, "PGBACKREST_REPO1_S3_KEY=AKIA123"); got != tt.expectKeys {
+				t.Errorf("S3 key in env: got %v, expected %v", got, tt.expectKeys)
+			}
+			if tt.keyType != "" && !slices.Contains(env, "PGBACKREST_REPO1_S3_KEY_TYPE="+tt.keyType) {
+				t.Errorf("key type not found in: %v", env)
+			}
+		})
+	}
+}
+
+func TestGetEnvVarConfig_AzureKeyType(t *testing.T) {
+	tests := []struct {
+		keyType         string
+		expectedKeyType string
+		expectKey       bool
+	}{
+		{"", "", true},
+		{pgbackrestapi.AzureKeyTypeSAS, "sas", true},
+		{pgbackrestapi.AzureKeyTypeAuto, "auto", false},
+		{pgbackrestapi.AzureKeyTypeWorkloadIdentity, "auto", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.keyType, func(t *testing.T) {
+			s := buildStanza()
+			s.Spec.Configuration.AzureRepositories[0].KeyType = tt.keyType
+			s.Spec.Configuration.AzureRepositories[0].Cipher = &pgbackrestapi.CipherConfig{
+				PassReference: &machineryapi.SecretKeySelector{
+					LocalObjectReference: machineryapi.LocalObjectReference{Name: "azure-key-secret"},
+					Key:                  "key",
+				},
+				Type: "aes-256-cbc",
+			}
+			env, err := GetEnvVarConfig(context.Background(), s, buildFakeClient())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := slices.Contains(env, "PGBACKREST_REPO2_AZURE_KEY=MYAZURESECRET123"); got != tt.expectKey {
+				t.Errorf("Azure key in env: got %v, expected %v", got, tt.expectKey)
+			}
+			hasKeyType := slices.ContainsFunc(env, func(e string) bool {
+				return strings.HasPrefix(e, "PGBACKREST_REPO2_AZURE_KEY_TYPE=")
+			})
+			if tt.expectedKeyType == "" && hasKeyType {
+				t.Errorf("unexpected key type in: %v", env)
+			}
+			if tt.expectedKeyType != "" &&
+				!slices.Contains(env, "PGBACKREST_REPO2_AZURE_KEY_TYPE="+tt.expectedKeyType) {
+				t.Errorf("key type %s not found in: %v", tt.expectedKeyType, env)
+			}
+			for _, e := range []string{
+				"PGBACKREST_REPO2_CIPHER_PASS=MYAZURESECRET123",
+				"PGBACKREST_REPO2_CIPHER_TYPE=aes-256-cbc",
+			} {
+				if !slices.Contains(env, e) {
+					t.Errorf("expected env var %v not found in: %v", e, env)
+				}
+			}
+		})
+	}
+}
+
+func TestGetEnvVarConfig_CABundle(t *testing.T) {
+	s := buildStanza()
+	s.Spec.Configuration.S3Repositories[0].CABundle = &pgbackrestapi.CABundle{
+		ConfigMapKeyRef: &machineryapi.ConfigMapKeySelector{
+			LocalObjectReference: machineryapi.LocalObjectReference{Name: "minio-ca"},
+			Key:                  "ca.crt",
+		},
+	}
+	s.Spec.Configuration.S3Repositories[0].StorageHost = "minio.internal"
+	s.Spec.Configuration.S3Repositories[0].StoragePort = 9000
+	env, err := GetEnvVarConfig(context.Background(), s, buildFakeClient())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	caFile := CABundleFile(s.Spec.Configuration.S3Repositories[0].CABundle)
+	if !strings.HasPrefix(caFile, CABundleDirectory+"/pgbackrest-ca-") || !strings.HasSuffix(caFile, "/ca.crt") {
+		t.Errorf("unexpected CA file path %s", caFile)
+	}
+	for _, e := range []string{
+		"PGBACKREST_REPO1_STORAGE_CA_FILE=" + caFile,
+		"PGBACKREST_REPO1_STORAGE_HOST=minio.internal",
+		"PGBACKREST_REPO1_STORAGE_PORT=9000",
+	} {
+		if !slices.Contains(env, e) {
+			t.Errorf("expected env var %v not found in: %v", e, env)
+		}
+	}
+	if slices.ContainsFunc(env, func(e string) bool {
+		return strings.HasPrefix(e, "PGBACKREST_REPO2_STORAGE_CA_FILE=")
+	}) {
+		t.Errorf("no CA file expected for the Azure repository: %v", env)
+	}
+}
+
+func TestGetEnvVarConfig_S3Options(t *testing.T) {
+	s := buildStanza()
+	repo := &s.Spec.Configuration.S3Repositories[0]
+	repo.RequesterPays = ptr.To(true)
+	repo.Tags = map[string]string{"team": "dba", "env": "prod"}
+	repo.ServerSideEncryption = &pgbackrestapi.S3ServerSideEncryption{
+		CustomerKeyReference: &machineryapi.SecretKeySelector{
+			LocalObjectReference: machineryapi.LocalObjectReference{Name: "sse-key-secret"},
+			Key:                  "key",
+		},
+	}
+	env, err := GetEnvVarConfig(context.Background(), s, buildFakeClient())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, e := range []string{
+		"PGBACKREST_REPO1_S3_REQUESTER_PAYS=y",
+		"PGBACKREST_REPO1_STORAGE_TAG=env=prod:team=dba",
+		"PGBACKREST_REPO1_S3_SSE_CUSTOMER_KEY=c3NlLWM=",
+	} {
+		if !slices.Contains(env, e) {
+			t.Errorf("expected env var %v not found in: %v", e, env)
+		}
+	}
+
+	repo.ServerSideEncryption = &pgbackrestapi.S3ServerSideEncryption{KMSKeyID: "arn:aws:kms:key"}
+	env, err = GetEnvVarConfig(context.Background(), s, buildFakeClient())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Contains(env, "PGBACKREST_REPO1_S3_KMS_KEY_ID=arn:aws:kms:key") {
+		t.Errorf("KMS key not found in: %v", env)
+	}
+}
+
+func TestGetEnvVarConfig_CipherRotation(t *testing.T) {
+	oldPass := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "old-pass", Namespace: "default"},
+		Data:       map[string][]byte{"pass": []byte("old")},
+	}
+	newPass := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "new-pass", Namespace: "default"},
+		Data:       map[string][]byte{"pass": []byte("new")},
+	}
+	ref := func(name string) *machineryapi.SecretKeySelector {
+		return &machineryapi.SecretKeySelector{
+			LocalObjectReference: machineryapi.LocalObjectReference{Name: name},
+			Key:                  "pass",
+		}
+	}
+	newStanza := func() *pgbackrestapi.Stanza {
+		s := buildStanza()
+		s.UID = "1234"
+		s.Spec.Configuration.AzureRepositories = nil
+		s.Spec.Configuration.S3Repositories[0].Cipher = &pgbackrestapi.CipherConfig{
+			PassReference: ref("new-pass"),
+		}
+		s.Status.Repositories = []pgbackrestapi.RepositoryStatus{{
+			Index:               1,
+			CipherFingerprint:   CipherFingerprint(s, "old"),
+			CipherPassReference: ref("old-pass"),
+		}}
+		return s
+	}
+
+	t.Run("recorded passphrase is used", func(t *testing.T) {
+		c := fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(oldPass, newPass).
+			WithObjects(buildFakeClientObjects()...).
+			Build()
+		env, err := GetEnvVarConfig(context.Background(), newStanza(), c)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !slices.Contains(env, "PGBACKREST_REPO1_CIPHER_PASS=old") ||
+			slices.Contains(env, "PGBACKREST_REPO1_CIPHER_PASS=new") {
+			t.Errorf("expected the recorded passphrase in: %v", env)
+		}
+	})
+
+	t.Run("changed passphrase is refused", func(t *testing.T) {
+		s := newStanza()
+		s.Status.Repositories[0].CipherPassReference = ref("new-pass")
+		c := fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(oldPass, newPass).
+			WithObjects(buildFakeClientObjects()...).
+			Build()
+		_, err := GetEnvVarConfig(context.Background(), s, c)
+		if !errors.Is(err, ErrCipherPassphraseChanged) {
+			t.Errorf("expected ErrCipherPassphraseChanged, got %v", err)
+		}
+	})
+
+	t.Run("rotation adds a repository", func(t *testing.T) {
+		s := newStanza()
+		s.Status.Repositories[0].Rotation = &pgbackrestapi.CipherRotation{
+			Phase:             pgbackrestapi.CipherRotationSeeding,
+			Index:             2,
+			Path:              "/backups-rotated",
+			CipherFingerprint: CipherFingerprint(s, "new"),
+		}
+		c := fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(oldPass, newPass).
+			WithObjects(buildFakeClientObjects()...).
+			Build()
+		env, err := GetEnvVarConfig(context.Background(), s, c)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, e := range []string{
+			"PGBACKREST_REPO1_CIPHER_PASS=old",
+			"PGBACKREST_REPO1_PATH=/backups",
+			"PGBACKREST_REPO2_CIPHER_PASS=new",
+			"PGBACKREST_REPO2_PATH=/backups-rotated",
+			"PGBACKREST_REPO2_S3_BUCKET=mybucket",
+			"PGBACKREST_REPO2_TYPE=s3",
+		} {
+			if !slices.Contains(env, e) {
+				t.Errorf("expected env var %v not found in: %v", e, env)
+			}
+		}
+		if got := BackupRepository(s, 1); got != 1 {
+			t.Errorf("backups must stay on repository 1 while seeding, got %d", got)
+		}
+		s.Status.Repositories[0].Rotation.Phase = pgbackrestapi.CipherRotationRetiring
+		if got := BackupRepository(s, 1); got != 2 {
+			t.Errorf("backups must go to repository 2 while retiring, got %d", got)
+		}
+	})
+}
+
+// snapshotClient is a client whose generation is set by the test
+type snapshotClient struct {
+	client.Client
+	generation uint64
+}
+
+func (c *snapshotClient) Generation() uint64 {
+	return c.generation
+}
+
+func TestGetEnvVarConfig_Cache(t *testing.T) {
+	ctx := context.Background()
+	s := buildStanza()
+	s.UID = "cache-test"
+	c := &snapshotClient{Client: buildFakeClient()}
+	if _, err := GetEnvVarConfig(ctx, s, c); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the secrets are not read again while the generation is unchanged
+	c.Client = fake.NewClientBuilder().WithScheme(scheme).Build()
+	env, err := GetEnvVarConfig(ctx, s, c)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Contains(env, "PGBACKREST_REPO1_S3_KEY=AKIA123") {
+		t.Errorf("expected the cached environment, got %v", env)
+	}
+
+	c.generation++
+	if _, err := GetEnvVarConfig(ctx, s, c); err == nil {
+		t.Errorf("expected the environment to be rebuilt without the secrets")
+	}
+}
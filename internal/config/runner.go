@@ -0,0 +1,62 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"context"
+	"os"
+
+	pgbackrestapi "github.com/dalibo/cnpg-i-pgbackrest/api/v1"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/pgbackrest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ConfigDirectoryEnv is the variable set by the operator on the plugin
+// containers when the pgBackRest configuration has to be rendered to files
+// (in the directory it points to) instead of environment variables.
+const ConfigDirectoryEnv = "PLUGIN_CONFIG_DIRECTORY"
+
+// DrainTimeoutEnv is the variable set by the operator on the instance
+// sidecar, with the drainTimeout of the PluginConfig.
+const DrainTimeoutEnv = "PLUGIN_DRAIN_TIMEOUT"
+
+// NewPgBackrest builds a pgBackRest runner configured for the stanza
+func NewPgBackrest(
+	ctx context.Context,
+	stanza *pgbackrestapi.Stanza,
+	c client.Client,
+) (*pgbackrest.PgBackrestRunner, error) {
+	env, err := GetEnvVarConfig(ctx, stanza, c)
+	if err != nil {
+		return nil, err
+	}
+	pgb := pgbackrest.NewPgBackrest(env)
+	if dir := os.Getenv(ConfigDirectoryEnv); dir != "" {
+		if err := pgb.RenderConfig(dir); err != nil {
+			return nil, err
+		}
+	}
+	return pgb, nil
+}
+
+// NewPgBackrestExporter builds a pgBackRest exporter runner configured for
+// the stanza
+func NewPgBackrestExporter(
+	ctx context.Context,
+	stanza *pgbackrestapi.Stanza,
+	c client.Client,
+) (*pgbackrest.PgBackrestExporterRunner, error) {
+	env, err := GetEnvVarConfig(ctx, stanza, c)
+	if err != nil {
+		return nil, err
+	}
+	runner := pgbackrest.NewPgBackrestExporterRunner(env)
+	if dir := os.Getenv(ConfigDirectoryEnv); dir != "" {
+		if err := runner.RenderConfig(dir); err != nil {
+			return nil, err
+		}
+	}
+	return runner, nil
+}
@@ -6,16 +6,46 @@ package exporter
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
 
 	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
 	apipgbackrest "github.com/dalibo/cnpg-i-pgbackrest/api/v1"
 	"github.com/dalibo/cnpg-i-pgbackrest/internal/config"
-	"github.com/dalibo/cnpg-i-pgbackrest/internal/pgbackrest"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
+const (
+	restartMinBackoff = time.Second
+	restartMaxBackoff = 5 * time.Minute
+	// stableRunDuration is how long the exporter must run before the backoff
+	// of its restarts is reset
+	stableRunDuration = 5 * time.Minute
+
+	// checkInterval is the interval of the checks of the configuration and
+	// of the liveness of the exporter
+	checkInterval = 30 * time.Second
+	// livenessTimeout bounds a request to the metrics endpoint
+	livenessTimeout = 10 * time.Second
+	// livenessFailureThreshold is the number of failed liveness checks in a
+	// row after which the exporter is restarted
+	livenessFailureThreshold = 3
+)
+
+var (
+	errConfigChanged = errors.New("configuration changed")
+	errNotLive       = errors.New("metrics endpoint not responding")
+)
+
 type PgbackrestSidecarServer struct {
 	Client       client.Client
 	InstanceName string
@@ -23,9 +53,54 @@ type PgbackrestSidecarServer struct {
 	ClusterName  string
 }
 
+// exporterSpec is the configuration the exporter is started with, it's
+// restarted when its fingerprint changes
+type exporterSpec struct {
+	stanza      *apipgbackrest.Stanza
+	args        []string
+	fingerprint string
+	// metricsURL is the endpoint watched for the liveness of the exporter
+	metricsURL string
+}
+
+// Start runs the exporter, it's restarted with a backoff when it stops or
+// stops responding, and right away when its configuration changes.
 func (p *PgbackrestSidecarServer) Start(ctx context.Context) error {
 	contextLogger := log.FromContext(ctx).WithName("exporter")
+	ctx = log.IntoContext(ctx, contextLogger)
 	contextLogger.Info("starting exporter")
+
+	backoff := restartMinBackoff
+	for {
+		spec, err := p.load(ctx)
+		if err == nil {
+			started := time.Now()
+			err = p.supervise(ctx, spec)
+			if ctx.Err() != nil {
+				return nil
+			}
+			if errors.Is(err, errConfigChanged) {
+				contextLogger.Info("configuration changed, restarting pgbackrest exporter")
+				backoff = restartMinBackoff
+				continue
+			}
+			if time.Since(started) > stableRunDuration {
+				backoff = restartMinBackoff
+			}
+		}
+		contextLogger.Error(err, "pgbackrest exporter not running, restarting", "backoff", backoff)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, restartMaxBackoff)
+	}
+}
+
+// load reads the configuration of the exporter: the stanza of the Cluster,
+// and the settings of its PluginConfig
+func (p *PgbackrestSidecarServer) load(ctx context.Context) (*exporterSpec, error) {
 	// retrieve cluster
 	clusterName := types.NamespacedName{
 		Name:      p.ClusterName,
@@ -33,13 +108,13 @@ func (p *PgbackrestSidecarServer) Start(ctx context.Context) error {
 	}
 	cluster := &cnpgv1.Cluster{}
 	if err := p.Client.Get(ctx, clusterName, cluster); err != nil {
-		return err
+		return nil, err
 	}
 
 	// retrieve stanza / pgbackrest configuration
 	pluginConfig, err := config.NewFromCluster(cluster)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	stanza, err := config.GetStanzaFromCluster(
@@ -49,29 +124,141 @@ func (p *PgbackrestSidecarServer) Start(ctx context.Context) error {
 		(*config.PluginConfiguration).GetStanzaRef,
 	)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// retrieve shared plugin config
 	sharedPluginConfigName, err := pluginConfig.GetSharedPluginConfig()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	sharedPluginConfig := &apipgbackrest.PluginConfig{}
 	if err := p.Client.Get(ctx, *sharedPluginConfigName, sharedPluginConfig); err != nil {
-		return err
+		return nil, err
 	}
 
-	// then launch the exporter
+	var args []string
+	ec := sharedPluginConfig.Spec.ExporterConfig
+	if ec != nil {
+		args = ec.ToArgs()
+	} else {
+		ec = &apipgbackrest.ExporterConfig{}
+	}
 	env, err := config.GetEnvVarConfig(ctx, stanza, p.Client)
+	if err != nil {
+		return nil, err
+	}
+	return &exporterSpec{
+		stanza:      stanza,
+		args:        args,
+		fingerprint: fingerprint(env, args),
+		metricsURL:  metricsURL(ec),
+	}, nil
+}
+
+// metricsURL returns the metrics endpoint of the exporter, reached on the
+// loopback interface unless it listens on a given address. With a web
+// configuration the exporter may expect TLS, plain HTTP requests are then
+// still answered.
+func metricsURL(ec *apipgbackrest.ExporterConfig) string {
+	host := "127.0.0.1"
+	if ip := net.ParseIP(ec.ListenAddress); ec.ListenAddress != "" && (ip == nil || !ip.IsUnspecified()) {
+		host = ec.ListenAddress
+	}
+	return "http://" + net.JoinHostPort(host, strconv.Itoa(int(ec.GetPort()))) + "/metrics"
+}
+
+// fingerprint identifies the environment and the arguments of the exporter,
+// without keeping the secrets they hold
+func fingerprint(env, args []string) string {
+	h := sha256.New()
+	for _, list := range [][]string{env, args} {
+		for _, s := range list {
+			_, _ = io.WriteString(h, s)
+			_, _ = h.Write([]byte{0})
+		}
+		_, _ = h.Write([]byte{1})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// supervise runs the exporter until it stops, its metrics endpoint stops
+// responding, or its configuration changes
+func (p *PgbackrestSidecarServer) supervise(ctx context.Context, spec *exporterSpec) error {
+	runner, err := config.NewPgBackrestExporter(ctx, spec.stanza, p.Client)
 	if err != nil {
 		return err
 	}
-	pgbaRunner := pgbackrest.NewPgBackrestExporterRunner(env)
-	var args []string
-	if ec := sharedPluginConfig.Spec.ExporterConfig; ec != nil {
-		args = ec.ToArgs()
+	runCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	go p.watch(runCtx, cancel, spec)
+
+	err = runner.RunExporter(runCtx, spec.args)
+	if cause := context.Cause(runCtx); err == nil && cause != nil {
+		return cause
 	}
-	err = pgbaRunner.RunExporter(ctx, args)
 	return err
 }
+
+// watch stops the exporter, through cancel, when its configuration changes
+// or when its metrics endpoint doesn't respond anymore
+func (p *PgbackrestSidecarServer) watch(
+	ctx context.Context,
+	cancel context.CancelCauseFunc,
+	running *exporterSpec,
+) {
+	contextLogger := log.FromContext(ctx)
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	failures := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		spec, err := p.load(ctx)
+		switch {
+		case err != nil:
+			contextLogger.Info("can't check the configuration of the exporter", "error", err.Error())
+		case spec.fingerprint != running.fingerprint:
+			cancel(errConfigChanged)
+			return
+		}
+
+		if err := probeMetrics(ctx, running.metricsURL); err != nil {
+			failures++
+			contextLogger.Info("pgbackrest exporter liveness check failed",
+				"failures", failures, "error", err.Error())
+			if failures >= livenessFailureThreshold {
+				cancel(fmt.Errorf("%w: %w", errNotLive, err))
+				return
+			}
+			continue
+		}
+		failures = 0
+	}
+}
+
+// probeMetrics checks that the metrics endpoint of the exporter responds, a
+// client error (e.g. the authentication required by its web configuration)
+// still tells it's alive
+func probeMetrics(ctx context.Context, url string) error {
+	ctx, cancel := context.WithTimeout(ctx, livenessTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
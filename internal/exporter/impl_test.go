@@ -0,0 +1,86 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+package exporter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	apipgbackrest "github.com/dalibo/cnpg-i-pgbackrest/api/v1"
+)
+
+func TestFingerprint(t *testing.T) {
+	base := fingerprint([]string{"PGBACKREST_STANZA=main", "PGBACKREST_REPO1_S3_KEY=key"}, []string{"--collect.interval=600"})
+	testCases := []struct {
+		desc string
+		env  []string
+		args []string
+		same bool
+	}{
+		{"unchanged", []string{"PGBACKREST_STANZA=main", "PGBACKREST_REPO1_S3_KEY=key"}, []string{"--collect.interval=600"}, true},
+		{"secret rotated", []string{"PGBACKREST_STANZA=main", "PGBACKREST_REPO1_S3_KEY=new"}, []string{"--collect.interval=600"}, false},
+		{"args changed", []string{"PGBACKREST_STANZA=main", "PGBACKREST_REPO1_S3_KEY=key"}, []string{"--collect.interval=60"}, false},
+		{"arg moved to env", []string{"PGBACKREST_STANZA=main", "PGBACKREST_REPO1_S3_KEY=key", "--collect.interval=600"}, nil, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := fingerprint(tc.env, tc.args); (got == base) != tc.same {
+				t.Errorf("want same fingerprint %v, got %s and %s", tc.same, base, got)
+			}
+		})
+	}
+}
+
+func TestProbeMetrics(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		status  int
+		wantErr bool
+	}{
+		{"responding", http.StatusOK, false},
+		{"authentication required", http.StatusUnauthorized, false},
+		{"failing", http.StatusInternalServerError, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/metrics" {
+					http.NotFound(w, r)
+					return
+				}
+				w.WriteHeader(tc.status)
+			}))
+			defer srv.Close()
+			if err := probeMetrics(context.Background(), srv.URL+"/metrics"); (err != nil) != tc.wantErr {
+				t.Errorf("want error %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	if err := probeMetrics(context.Background(), srv.URL+"/metrics"); err == nil {
+		t.Error("the stopped exporter must be reported")
+	}
+}
+
+func TestMetricsURL(t *testing.T) {
+	testCases := []struct {
+		conf apipgbackrest.ExporterConfig
+		want string
+	}{
+		{apipgbackrest.ExporterConfig{}, "http://127.0.0.1:9854/metrics"},
+		{apipgbackrest.ExporterConfig{Port: 9900, ListenAddress: "0.0.0.0"}, "http://127.0.0.1:9900/metrics"},
+		{apipgbackrest.ExporterConfig{ListenAddress: "::"}, "http://127.0.0.1:9854/metrics"},
+		{apipgbackrest.ExporterConfig{ListenAddress: "10.0.0.1"}, "http://10.0.0.1:9854/metrics"},
+		{apipgbackrest.ExporterConfig{ListenAddress: "::1"}, "http://[::1]:9854/metrics"},
+	}
+	for _, tc := range testCases {
+		if got := metricsURL(&tc.conf); got != tc.want {
+			t.Errorf("want %s, got %s", tc.want, got)
+		}
+	}
+}
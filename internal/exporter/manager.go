@@ -48,13 +48,13 @@ func Start(ctx context.Context) error {
 			},
 		},
 	}
-	cl, err := client.New(ctrl.GetConfigOrDie(), clientOpt)
+	cl, err := client.NewWithWatch(ctrl.GetConfigOrDie(), clientOpt)
 	if err != nil {
 		return err
 	}
 
 	pgbaSidecarServer := PgbackrestSidecarServer{
-		Client:       extendedclient.NewExtendedClient(cl),
+		Client:       extendedclient.NewWatchingClient(ctx, cl),
 		InstanceName: podName,
 		Namespace:    ns,
 		ClusterName:  clusterName,
@@ -14,14 +14,18 @@ import (
 	"github.com/dalibo/cnpg-i-pgbackrest/internal/config"
 	"github.com/dalibo/cnpg-i-pgbackrest/internal/metadata"
 	"github.com/dalibo/cnpg-i-pgbackrest/internal/pgbackrest"
+	"k8s.io/client-go/tools/events"
 	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 type BackupServiceImplementation struct {
-	Client       client.Client
-	InstanceName string
+	Client            client.Client
+	InstanceName      string
+	PGDataPath        string
+	Recorder          events.EventRecorder
+	PGBackRestVersion *pgbackrest.Version
 	backup.UnimplementedBackupServer
 }
 
@@ -41,10 +45,13 @@ func (b BackupServiceImplementation) GetCapabilities(
 	}, nil
 }
 
+// getEnvVarBackupRepoDest selects the repository of the backup, a
+// repository whose passphrase is being rotated is backed up on the new one.
 func getEnvVarBackupRepoDest(
-	stanzaConf pgbackrestapi.StanzaConfiguration,
+	stanza *pgbackrestapi.Stanza,
 	selectedRepo string,
 ) (string, error) {
+	stanzaConf := stanza.Spec.Configuration
 	sRepo, err := strconv.ParseUint(selectedRepo, 10, 64)
 	if err != nil {
 		return "", err
@@ -52,7 +59,7 @@ func getEnvVarBackupRepoDest(
 	if sRepo != 1 && sRepo > uint64(len(stanzaConf.S3Repositories)) {
 		return "", fmt.Errorf("can't parse selected repository: %s, %w", selectedRepo, err)
 	}
-	return fmt.Sprintf("PGBACKREST_REPO=%d", sRepo), nil
+	return fmt.Sprintf("PGBACKREST_REPO=%d", config.BackupRepository(stanza, int(sRepo))), nil
 }
 
 func updateBackupInfo(
@@ -91,6 +98,10 @@ func (b BackupServiceImplementation) Backup(
 	request *backup.BackupRequest,
 ) (*backup.BackupResult, error) {
 	contextLogger := log.FromContext(ctx)
+	conf, err := config.NewFromClusterJSON(request.GetClusterDefinition())
+	if err != nil {
+		return nil, err
+	}
 	stanza, err := config.GetStanza(
 		ctx,
 		request,
@@ -100,30 +111,36 @@ func (b BackupServiceImplementation) Backup(
 	if err != nil {
 		return nil, err
 	}
-	env, err := config.GetEnvVarConfig(ctx, stanza, b.Client)
+	if err := guardStanzaOwnership(
+		ctx, b.Client, b.Recorder, stanza, conf.Cluster, b.PGDataPath, "Backup",
+	); err != nil {
+		return nil, toGRPCError(err)
+	}
+	if err := guardStanzaCompatibility(ctx, b.Client, stanza, b.PGBackRestVersion); err != nil {
+		return nil, toGRPCError(err)
+	}
+	pgb, err := config.NewPgBackrest(ctx, stanza, b.Client)
 	if err != nil {
-		contextLogger.Error(err, "can't get envvar")
+		contextLogger.Error(err, "can't configure pgbackrest")
 		return nil, err
 	}
 	selectedRepo, ok := request.Parameters["selectedRepository"]
 	if !ok {
 		selectedRepo = "1" // use first stanza by default
 	}
-	repoDestEnv, err := getEnvVarBackupRepoDest(stanza.Spec.Configuration, selectedRepo)
+	repoDestEnv, err := getEnvVarBackupRepoDest(stanza, selectedRepo)
 	if err != nil {
 		return nil, err
 	}
-	env = append(env, repoDestEnv)
 	contextLogger.Info("using repo", "repo", repoDestEnv)
 	backupType := request.Parameters["backupType"]
 	contextLogger.Info("Starting backup", "type", backupType)
-	pgb := pgbackrest.NewPgBackrest(env)
-	if err := pgb.Backup(backupType); err != nil {
+	if err := pgb.Backup(ctx, backupType, repoDestEnv); err != nil {
 		contextLogger.Error(err, "can't backup")
-		return nil, err
+		return nil, toGRPCError(err)
 	}
 
-	backupsList, err := pgb.GetBackupInfo()
+	backupsList, err := pgb.GetBackupInfo(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -138,6 +155,14 @@ func (b BackupServiceImplementation) Backup(
 	}
 
 	contextLogger.Info("Backup done!")
+	backupMetadata := map[string]string{
+		"version":     metadata.Data.Version,
+		"name":        metadata.Data.Name,
+		"displayName": metadata.Data.DisplayName,
+	}
+	if b.PGBackRestVersion != nil {
+		backupMetadata["pgbackrestVersion"] = b.PGBackRestVersion.String()
+	}
 	return &backup.BackupResult{
 		BackupName: lastBackup.Label,
 		BeginLsn:   lastBackup.Lsn.Start,
@@ -147,10 +172,6 @@ func (b BackupServiceImplementation) Backup(
 		Online:     true,
 		StartedAt:  lastBackup.Timestamp.Start,
 		StoppedAt:  lastBackup.Timestamp.Stop,
-		Metadata: map[string]string{
-			"version":     metadata.Data.Version,
-			"name":        metadata.Data.Name,
-			"displayName": metadata.Data.DisplayName,
-		},
+		Metadata:   backupMetadata,
 	}, nil
 }
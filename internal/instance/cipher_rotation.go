@@ -0,0 +1,333 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package instance
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	pgbackrestapi "github.com/dalibo/cnpg-i-pgbackrest/api/v1"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/config"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/pgbackrest"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	cipherRotationCondition   = "CipherRotation"
+	cipherPassphraseCondition = "CipherPassphrase"
+)
+
+// seedBackup is a full backup seeding the new repository of a rotation
+type seedBackup struct {
+	done chan struct{}
+	err  error
+}
+
+// seedBackups runs the seed backups in the background, so that the
+// maintenance cycles go on while they run, and keeps their outcome until it
+// is polled
+type seedBackups struct {
+	mu      sync.Mutex
+	backups map[string]*seedBackup
+}
+
+// poll starts the seed backup of the key when none is running, and reports
+// whether it's done and its error. A backup is forgotten once its outcome is
+// polled, so that a failed one is started again on the next poll.
+func (s *seedBackups) poll(
+	ctx context.Context,
+	key string,
+	backup func(context.Context) error,
+) (done bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.backups[key]
+	if !ok {
+		if s.backups == nil {
+			s.backups = make(map[string]*seedBackup)
+		}
+		b = &seedBackup{done: make(chan struct{})}
+		s.backups[key] = b
+		go func() {
+			defer close(b.done)
+			b.err = backup(ctx)
+		}()
+	}
+	select {
+	case <-b.done:
+		delete(s.backups, key)
+		return true, b.err
+	default:
+		return false, nil
+	}
+}
+
+// nextRepositoryStatus computes the status of the encrypted repositories from
+// the ciphers of the spec. The passphrase of a new repository is recorded;
+// a rotation starts when the passphrase of a repository changed and the one
+// it was encrypted with is still available, a new repository index after
+// the ones in use is then allocated.
+func nextRepositoryStatus(
+	current []pgbackrestapi.RepositoryStatus,
+	ciphers []config.RepositoryCipher,
+	recordedAvailable func(*pgbackrestapi.RepositoryStatus) bool,
+	now time.Time,
+) (next []pgbackrestapi.RepositoryStatus, refused []int) {
+	byIndex := make(map[int]*pgbackrestapi.RepositoryStatus, len(current))
+	nextIndex := 1
+	for i := range current {
+		byIndex[current[i].Index] = &current[i]
+		nextIndex = max(nextIndex, current[i].Index+1)
+		if r := current[i].Rotation; r != nil {
+			nextIndex = max(nextIndex, r.Index+1)
+		}
+	}
+	for _, c := range ciphers {
+		nextIndex = max(nextIndex, c.Index+1)
+	}
+
+	for _, c := range ciphers {
+		prev, ok := byIndex[c.Index]
+		if !ok {
+			next = append(next, pgbackrestapi.RepositoryStatus{
+				Index:               c.Index,
+				CipherFingerprint:   c.Fingerprint,
+				CipherPassReference: c.PassReference,
+			})
+			continue
+		}
+		st := *prev.DeepCopy()
+		switch {
+		case c.Fingerprint == st.CipherFingerprint:
+			// back to the passphrase of the repository, e.g. the reference
+			// moved to another secret holding the same passphrase
+			st.CipherPassReference = c.PassReference
+			st.Rotation = nil
+		case st.Rotation != nil && st.Rotation.CipherFingerprint == c.Fingerprint:
+			// rotation in progress
+		case !recordedAvailable(&st):
+			refused = append(refused, c.Index)
+		default:
+			basePath := c.Path
+			if st.Path != "" {
+				basePath = st.Path
+			}
+			index := nextIndex
+			if st.Rotation != nil {
+				// the passphrase changed again, start over
+				index = st.Rotation.Index
+			} else {
+				nextIndex++
+			}
+			st.Rotation = &pgbackrestapi.CipherRotation{
+				Phase:             pgbackrestapi.CipherRotationProvisioning,
+				Index:             index,
+				Path:              fmt.Sprintf("%s-%s", basePath, c.Fingerprint[:8]),
+				CipherFingerprint: c.Fingerprint,
+				StartedAt:         metav1.NewTime(now),
+			}
+		}
+		next = append(next, st)
+	}
+	return next, refused
+}
+
+// retirementDue reports whether the backups of the old repository expired
+// according to its retention policy, as the new repository alone satisfies
+// it.
+func retirementDue(
+	retention pgbackrestapi.Retention,
+	rotation *pgbackrestapi.CipherRotation,
+	newRepoBackups []pgbackrestapi.BackupInfo,
+	now time.Time,
+) bool {
+	if rotation.SeededAt == nil {
+		return false
+	}
+	full := max(int(retention.Full), 1)
+	if retention.FullType == "time" {
+		return !now.Before(rotation.SeededAt.AddDate(0, 0, full))
+	}
+	return int(pgbackrest.CountByType(newRepoBackups)["full"]) >= full
+}
+
+// completeRotation makes the new repository the one of the index.
+func completeRotation(st *pgbackrestapi.RepositoryStatus, c config.RepositoryCipher) {
+	st.CipherFingerprint = st.Rotation.CipherFingerprint
+	st.CipherPassReference = c.PassReference
+	st.Path = st.Rotation.Path
+	st.Rotation = nil
+}
+
+func rotationConditions(
+	repositories []pgbackrestapi.RepositoryStatus,
+	refused []int,
+) []metav1.Condition {
+	rotation := metav1.Condition{
+		Type:    cipherRotationCondition,
+		Status:  metav1.ConditionFalse,
+		Reason:  "Idle",
+		Message: "no cipher passphrase rotation in progress",
+	}
+	for _, st := range repositories {
+		if st.Rotation != nil {
+			rotation.Status = metav1.ConditionTrue
+			rotation.Reason = st.Rotation.Phase
+			rotation.Message = fmt.Sprintf(
+				"rotating the passphrase of repository %d to repository %d",
+				st.Index,
+				st.Rotation.Index,
+			)
+			break
+		}
+	}
+	passphrase := metav1.Condition{
+		Type:    cipherPassphraseCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Valid",
+		Message: "the repositories are used with the passphrase they are encrypted with",
+	}
+	if len(refused) > 0 {
+		passphrase.Status = metav1.ConditionFalse
+		passphrase.Reason = "PassphraseChanged"
+		passphrase.Message = fmt.Sprintf(
+			"the passphrase of repositories %v changed and the previous one can't be read, "+
+				"restore it or reference it again",
+			refused,
+		)
+	}
+	return []metav1.Condition{rotation, passphrase}
+}
+
+func updateRepositoriesStatus(
+	ctx context.Context,
+	c client.Client,
+	stanza *pgbackrestapi.Stanza,
+	repositories []pgbackrestapi.RepositoryStatus,
+	refused []int,
+) error {
+	key := client.ObjectKeyFromObject(stanza)
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		if err := c.Get(ctx, key, stanza); err != nil {
+			return err
+		}
+		stanza.Status.Repositories = make([]pgbackrestapi.RepositoryStatus, len(repositories))
+		for i := range repositories {
+			repositories[i].DeepCopyInto(&stanza.Status.Repositories[i])
+		}
+		if stanza.Status.Creation != nil {
+			// a retired repository is not created anymore
+			stanza.Status.Creation = nextStanzaCreation(
+				stanza.Status.Creation, config.RepositoryIndexes(stanza), nil, nil, time.Now())
+		}
+		for _, cond := range rotationConditions(repositories, refused) {
+			cond.ObservedGeneration = stanza.Generation
+			apimeta.SetStatusCondition(&stanza.Status.Conditions, cond)
+		}
+		return c.Status().Update(ctx, stanza)
+	})
+}
+
+// reconcileCipherRotation records the passphrases of the encrypted
+// repositories and drives their rotation, one step per maintenance cycle:
+// the stanza is created on the new repository, a full backup seeds it in the
+// background, then the old repository is retired once its backups expired.
+func (c *StanzaMaintenanceRunnable) reconcileCipherRotation(
+	ctx context.Context,
+	stanza *pgbackrestapi.Stanza,
+) error {
+	contextLogger := log.FromContext(ctx)
+
+	ciphers, err := config.RepositoryCiphers(ctx, c.Client, stanza)
+	if err != nil {
+		return err
+	}
+	if len(ciphers) == 0 && len(stanza.Status.Repositories) == 0 {
+		return nil
+	}
+	repositories, refused := nextRepositoryStatus(
+		stanza.Status.Repositories,
+		ciphers,
+		func(st *pgbackrestapi.RepositoryStatus) bool {
+			return config.RecordedPassphraseAvailable(ctx, c.Client, stanza, st)
+		},
+		time.Now(),
+	)
+	if err := updateRepositoriesStatus(ctx, c.Client, stanza, repositories, refused); err != nil {
+		return err
+	}
+	if len(refused) > 0 {
+		return fmt.Errorf("%w on repositories %v", config.ErrCipherPassphraseChanged, refused)
+	}
+
+	cipherByIndex := make(map[int]config.RepositoryCipher, len(ciphers))
+	for _, ci := range ciphers {
+		cipherByIndex[ci.Index] = ci
+	}
+	for i := range repositories {
+		st := &repositories[i]
+		if st.Rotation == nil {
+			continue
+		}
+		logger := contextLogger.WithValues(
+			"repository", st.Index, "newRepository", st.Rotation.Index, "phase", st.Rotation.Phase)
+		pgb, err := config.NewPgBackrest(ctx, stanza, c.Client)
+		if err != nil {
+			return err
+		}
+		switch st.Rotation.Phase {
+		case pgbackrestapi.CipherRotationProvisioning:
+			logger.Info("creating the stanza on the new repository")
+			if err := ensureStanzaCreated(ctx, c.Client, stanza, pgb); err != nil {
+				return err
+			}
+			st.Rotation.Phase = pgbackrestapi.CipherRotationSeeding
+		case pgbackrestapi.CipherRotationSeeding:
+			repo := fmt.Sprintf("PGBACKREST_REPO=%d", st.Rotation.Index)
+			done, err := c.seeds.poll(ctx, st.Rotation.Path, func(ctx context.Context) error {
+				logger.Info("seeding the new repository with a full backup")
+				return pgb.Backup(ctx, "full", repo)
+			})
+			if err != nil {
+				return fmt.Errorf("seeding repository %d: %w", st.Rotation.Index, err)
+			}
+			if !done {
+				logger.Info("waiting for the full backup seeding the new repository")
+				continue
+			}
+			backups, err := pgb.GetRepositoryBackupInfo(ctx, st.Rotation.Index)
+			if err != nil {
+				return err
+			}
+			if last := pgbackrest.LatestBackup(backups); last != nil {
+				st.Rotation.SeedBackup = last.Label
+			}
+			st.Rotation.SeededAt = ptr.To(metav1.Now())
+			st.Rotation.Phase = pgbackrestapi.CipherRotationRetiring
+		case pgbackrestapi.CipherRotationRetiring:
+			backups, err := pgb.GetRepositoryBackupInfo(ctx, st.Rotation.Index)
+			if err != nil {
+				return err
+			}
+			ci := cipherByIndex[st.Index]
+			if !retirementDue(ci.Retention, st.Rotation, backups, time.Now()) {
+				continue
+			}
+			logger.Info("retiring the old repository", "path", ci.Path)
+			completeRotation(st, ci)
+		}
+		if err := updateRepositoriesStatus(ctx, c.Client, stanza, repositories, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
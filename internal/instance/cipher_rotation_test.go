@@ -0,0 +1,199 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+package instance
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	machineryapi "github.com/cloudnative-pg/machinery/pkg/api"
+	pgbackrestapi "github.com/dalibo/cnpg-i-pgbackrest/api/v1"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func secretRef(name string) *machineryapi.SecretKeySelector {
+	return &machineryapi.SecretKeySelector{
+		LocalObjectReference: machineryapi.LocalObjectReference{Name: name},
+		Key:                  "pass",
+	}
+}
+
+func TestNextRepositoryStatus(t *testing.T) {
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	available := func(*pgbackrestapi.RepositoryStatus) bool { return true }
+	unavailable := func(*pgbackrestapi.RepositoryStatus) bool { return false }
+	recorded := []pgbackrestapi.RepositoryStatus{
+		{Index: 1, CipherFingerprint: "0123456789abcdef", CipherPassReference: secretRef("old")},
+	}
+
+	t.Run("new repository is recorded", func(t *testing.T) {
+		next, refused := nextRepositoryStatus(nil, []config.RepositoryCipher{
+			{Index: 2, Fingerprint: "fedcba9876543210", PassReference: secretRef("pass")},
+		}, available, now)
+		if len(refused) != 0 || len(next) != 1 || next[0].Index != 2 ||
+			next[0].CipherFingerprint != "fedcba9876543210" || next[0].Rotation != nil {
+			t.Errorf("unexpected status %+v, refused %v", next, refused)
+		}
+	})
+
+	t.Run("unchanged passphrase", func(t *testing.T) {
+		next, refused := nextRepositoryStatus(recorded, []config.RepositoryCipher{
+			{Index: 1, Fingerprint: "0123456789abcdef", PassReference: secretRef("moved")},
+		}, available, now)
+		if len(refused) != 0 || next[0].Rotation != nil || next[0].CipherPassReference.Name != "moved" {
+			t.Errorf("unexpected status %+v, refused %v", next, refused)
+		}
+	})
+
+	t.Run("changed passphrase starts a rotation", func(t *testing.T) {
+		next, refused := nextRepositoryStatus(recorded, []config.RepositoryCipher{
+			{Index: 1, Path: "/repo", Fingerprint: "fedcba9876543210", PassReference: secretRef("new")},
+			{Index: 2, Fingerprint: "aaaaaaaaaaaaaaaa", PassReference: secretRef("other")},
+		}, available, now)
+		if len(refused) != 0 {
+			t.Fatalf("unexpected refused repositories %v", refused)
+		}
+		r := next[0].Rotation
+		if r == nil {
+			t.Fatalf("expected a rotation")
+		}
+		if r.Phase != pgbackrestapi.CipherRotationProvisioning || r.Index != 3 ||
+			r.Path != "/repo-fedcba98" || r.CipherFingerprint != "fedcba9876543210" {
+			t.Errorf("unexpected rotation %+v", r)
+		}
+		// the repository keeps the passphrase it is encrypted with
+		if next[0].CipherFingerprint != "0123456789abcdef" || next[0].CipherPassReference.Name != "old" {
+			t.Errorf("unexpected status %+v", next[0])
+		}
+	})
+
+	t.Run("changed passphrase without the previous one is refused", func(t *testing.T) {
+		next, refused := nextRepositoryStatus(recorded, []config.RepositoryCipher{
+			{Index: 1, Fingerprint: "fedcba9876543210", PassReference: secretRef("old")},
+		}, unavailable, now)
+		if len(refused) != 1 || refused[0] != 1 || next[0].Rotation != nil {
+			t.Errorf("unexpected status %+v, refused %v", next, refused)
+		}
+	})
+
+	t.Run("rotation in progress is kept", func(t *testing.T) {
+		rotating := []pgbackrestapi.RepositoryStatus{*recorded[0].DeepCopy()}
+		rotating[0].Rotation = &pgbackrestapi.CipherRotation{
+			Phase:             pgbackrestapi.CipherRotationRetiring,
+			Index:             2,
+			CipherFingerprint: "fedcba9876543210",
+		}
+		next, _ := nextRepositoryStatus(rotating, []config.RepositoryCipher{
+			{Index: 1, Fingerprint: "fedcba9876543210", PassReference: secretRef("new")},
+		}, available, now)
+		if next[0].Rotation == nil || next[0].Rotation.Phase != pgbackrestapi.CipherRotationRetiring {
+			t.Errorf("unexpected rotation %+v", next[0].Rotation)
+		}
+	})
+}
+
+func TestRetirementDue(t *testing.T) {
+	seededAt := metav1.NewTime(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC))
+	rotation := &pgbackrestapi.CipherRotation{SeededAt: &seededAt}
+	fulls := func(n int) []pgbackrestapi.BackupInfo {
+		backups := make([]pgbackrestapi.BackupInfo, n)
+		for i := range backups {
+			backups[i].Type = "full"
+		}
+		return backups
+	}
+
+	testCases := []struct {
+		desc      string
+		retention pgbackrestapi.Retention
+		rotation  *pgbackrestapi.CipherRotation
+		backups   []pgbackrestapi.BackupInfo
+		now       time.Time
+		expected  bool
+	}{
+		{
+			desc:     "not seeded",
+			rotation: &pgbackrestapi.CipherRotation{},
+			backups:  fulls(3),
+			now:      seededAt.Time,
+			expected: false,
+		},
+		{
+			desc:      "count retention not reached",
+			retention: pgbackrestapi.Retention{Full: 2},
+			rotation:  rotation,
+			backups:   fulls(1),
+			now:       seededAt.Time,
+			expected:  false,
+		},
+		{
+			desc:      "count retention reached",
+			retention: pgbackrestapi.Retention{Full: 2},
+			rotation:  rotation,
+			backups:   fulls(2),
+			now:       seededAt.Time,
+			expected:  true,
+		},
+		{
+			desc:      "time retention not reached",
+			retention: pgbackrestapi.Retention{Full: 7, FullType: "time"},
+			rotation:  rotation,
+			now:       seededAt.AddDate(0, 0, 6),
+			expected:  false,
+		},
+		{
+			desc:      "time retention reached",
+			retention: pgbackrestapi.Retention{Full: 7, FullType: "time"},
+			rotation:  rotation,
+			now:       seededAt.AddDate(0, 0, 7),
+			expected:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := retirementDue(tc.retention, tc.rotation, tc.backups, tc.now); got != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestSeedBackupsPoll(t *testing.T) {
+	var seeds seedBackups
+	ctx := context.Background()
+	release := make(chan error)
+	started := 0
+	backup := func(context.Context) error {
+		started++
+		return <-release
+	}
+	wait := func() error {
+		for {
+			if done, err := seeds.poll(ctx, "/repo-1", backup); done {
+				return err
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	if done, err := seeds.poll(ctx, "/repo-1", backup); done || err != nil {
+		t.Fatalf("the backup must run in the background, got done %v, error %v", done, err)
+	}
+	release <- errors.New("failed")
+	if err := wait(); err == nil || started != 1 {
+		t.Fatalf("want the error of the only backup, got %v after %d backups", err, started)
+	}
+
+	// a failed backup is started again on the next poll
+	if done, _ := seeds.poll(ctx, "/repo-1", backup); done {
+		t.Fatal("the backup must be started again")
+	}
+	release <- nil
+	if err := wait(); err != nil || started != 2 {
+		t.Errorf("want the success of the second backup, got %v after %d backups", err, started)
+	}
+}
@@ -17,6 +17,6 @@ limitations under the License.
 SPDX-License-Identifier: Apache-2.0
 */
 
-// Package client provides an extended client that is capable of caching multiple secrets without relying on
-// informers
+// Package client provides a client keeping snapshots of the objects it reads up to date with per-object
+// watches
 package client
@@ -0,0 +1,285 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	pgbackrestapi "github.com/dalibo/cnpg-i-pgbackrest/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type watchKey struct {
+	kind string
+	client.ObjectKey
+}
+
+type watchedObject struct {
+	obj    client.Object
+	cancel context.CancelFunc
+}
+
+// WatchingClient is a client keeping a snapshot of the Stanzas, Secrets and
+// PluginConfigs it read, updated by a watch on each object. The watches are
+// restricted to a single object name, so they are allowed by a Role limited
+// with resourceNames, and the objects are read from the API server only once
+// instead of on every WAL archived.
+type WatchingClient struct {
+	client.Client
+	watcher client.WithWatch
+	// ctx bounds the lifetime of the watches
+	ctx        context.Context
+	mux        sync.Mutex
+	objects    map[watchKey]*watchedObject
+	generation atomic.Uint64
+}
+
+// NewWatchingClient returns a client reading the watched types from
+// snapshots, the watches are stopped when ctx is done.
+func NewWatchingClient(ctx context.Context, watcher client.WithWatch) *WatchingClient {
+	return &WatchingClient{
+		Client:  watcher,
+		watcher: watcher,
+		ctx:     ctx,
+		objects: make(map[watchKey]*watchedObject),
+	}
+}
+
+// Generation is incremented each time a watched object changes, so that
+// values computed from the snapshots can be kept until then.
+func (w *WatchingClient) Generation() uint64 {
+	return w.generation.Load()
+}
+
+// watchedKind returns the kind of the watched types and a list to watch
+// them, or an empty kind for the other types.
+func watchedKind(obj client.Object) (string, client.ObjectList) {
+	switch obj.(type) {
+	case *corev1.Secret:
+		return "Secret", &corev1.SecretList{}
+	case *pgbackrestapi.Stanza:
+		return "Stanza", &pgbackrestapi.StanzaList{}
+	case *pgbackrestapi.PluginConfig:
+		return "PluginConfig", &pgbackrestapi.PluginConfigList{}
+	}
+	return "", nil
+}
+
+// copyInto copies the snapshot into obj, the way the controller-runtime
+// cache reader does.
+func copyInto(snapshot client.Object, obj client.Object) error {
+	outVal := reflect.ValueOf(obj)
+	objVal := reflect.ValueOf(snapshot.DeepCopyObject())
+	if !objVal.Type().AssignableTo(outVal.Type()) {
+		return fmt.Errorf("snapshot had type %s, but %s was asked for", objVal.Type(), outVal.Type())
+	}
+	reflect.Indirect(outVal).Set(reflect.Indirect(objVal))
+	return nil
+}
+
+// Get reads the watched types from their snapshot. The first Get of an
+// object reads it from the API server and starts watching it.
+func (w *WatchingClient) Get(
+	ctx context.Context,
+	key client.ObjectKey,
+	obj client.Object,
+	opts ...client.GetOption,
+) error {
+	kind, list := watchedKind(obj)
+	if kind == "" {
+		return w.Client.Get(ctx, key, obj, opts...)
+	}
+	wk := watchKey{kind: kind, ObjectKey: key}
+
+	w.mux.Lock()
+	if o, ok := w.objects[wk]; ok {
+		defer w.mux.Unlock()
+		return copyInto(o.obj, obj)
+	}
+	w.mux.Unlock()
+
+	if err := w.Client.Get(ctx, key, obj, opts...); err != nil {
+		return err
+	}
+	w.startWatch(ctx, wk, list, obj)
+	return nil
+}
+
+func (w *WatchingClient) startWatch(
+	ctx context.Context,
+	wk watchKey,
+	list client.ObjectList,
+	obj client.Object,
+) {
+	contextLogger := log.FromContext(ctx).WithName("watching_client").
+		WithValues("kind", wk.kind, "name", wk.Name, "namespace", wk.Namespace)
+
+	watchCtx, cancel := context.WithCancel(w.ctx)
+	watcher, err := w.watcher.Watch(
+		watchCtx,
+		list,
+		client.InNamespace(wk.Namespace),
+		client.MatchingFields{"metadata.name": wk.Name},
+		&client.ListOptions{Raw: &metav1.ListOptions{ResourceVersion: obj.GetResourceVersion()}},
+	)
+	if err != nil {
+		// the object is read from the API server until a watch succeeds
+		cancel()
+		contextLogger.Warning("can't watch object", "error", err)
+		return
+	}
+
+	w.mux.Lock()
+	if _, ok := w.objects[wk]; ok {
+		// started concurrently
+		w.mux.Unlock()
+		cancel()
+		watcher.Stop()
+		return
+	}
+	watched := &watchedObject{obj: obj.DeepCopyObject().(client.Object), cancel: cancel}
+	w.objects[wk] = watched
+	w.mux.Unlock()
+
+	contextLogger.Debug("watching object")
+	go w.consume(watchCtx, wk, watched, watcher)
+}
+
+// consume updates the snapshot with the events of the watch, it is dropped
+// when the object is deleted or the watch ends, to be read again from the
+// API server on the next Get.
+func (w *WatchingClient) consume(
+	ctx context.Context,
+	wk watchKey,
+	watched *watchedObject,
+	watcher watch.Interface,
+) {
+	defer watcher.Stop()
+	defer w.forget(wk, watched)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				return
+			}
+			obj, isObject := event.Object.(client.Object)
+			switch event.Type {
+			case watch.Added, watch.Modified:
+				if !isObject || obj.GetName() != wk.Name {
+					continue
+				}
+				w.mux.Lock()
+				watched.obj = obj.DeepCopyObject().(client.Object)
+				w.mux.Unlock()
+				w.generation.Add(1)
+			case watch.Deleted:
+				if isObject && obj.GetName() != wk.Name {
+					continue
+				}
+				return
+			case watch.Error:
+				return
+			}
+		}
+	}
+}
+
+func (w *WatchingClient) forget(wk watchKey, watched *watchedObject) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	if w.objects[wk] == watched {
+		delete(w.objects, wk)
+		w.generation.Add(1)
+	}
+	watched.cancel()
+}
+
+// refresh replaces the snapshot of an object updated through this client,
+// so that it is read back without waiting for the watch event.
+func (w *WatchingClient) refresh(obj client.Object) {
+	kind, _ := watchedKind(obj)
+	if kind == "" {
+		return
+	}
+	wk := watchKey{kind: kind, ObjectKey: client.ObjectKeyFromObject(obj)}
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	if o, ok := w.objects[wk]; ok {
+		o.obj = obj.DeepCopyObject().(client.Object)
+		w.generation.Add(1)
+	}
+}
+
+// Update behaves like the original Update method, and refreshes the snapshot
+func (w *WatchingClient) Update(
+	ctx context.Context,
+	obj client.Object,
+	opts ...client.UpdateOption,
+) error {
+	if err := w.Client.Update(ctx, obj, opts...); err != nil {
+		return err
+	}
+	w.refresh(obj)
+	return nil
+}
+
+// Patch behaves like the original Patch method, and refreshes the snapshot
+func (w *WatchingClient) Patch(
+	ctx context.Context,
+	obj client.Object,
+	patch client.Patch,
+	opts ...client.PatchOption,
+) error {
+	if err := w.Client.Patch(ctx, obj, patch, opts...); err != nil {
+		return err
+	}
+	w.refresh(obj)
+	return nil
+}
+
+// Status returns a writer refreshing the snapshots of the updated objects
+func (w *WatchingClient) Status() client.SubResourceWriter {
+	return &watchingStatusWriter{SubResourceWriter: w.Client.Status(), client: w}
+}
+
+type watchingStatusWriter struct {
+	client.SubResourceWriter
+	client *WatchingClient
+}
+
+func (s *watchingStatusWriter) Update(
+	ctx context.Context,
+	obj client.Object,
+	opts ...client.SubResourceUpdateOption,
+) error {
+	if err := s.SubResourceWriter.Update(ctx, obj, opts...); err != nil {
+		return err
+	}
+	s.client.refresh(obj)
+	return nil
+}
+
+func (s *watchingStatusWriter) Patch(
+	ctx context.Context,
+	obj client.Object,
+	patch client.Patch,
+	opts ...client.SubResourcePatchOption,
+) error {
+	if err := s.SubResourceWriter.Patch(ctx, obj, patch, opts...); err != nil {
+		return err
+	}
+	s.client.refresh(obj)
+	return nil
+}
@@ -0,0 +1,113 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// countingClient counts the Get calls reaching the API server
+type countingClient struct {
+	client.WithWatch
+	gets int
+}
+
+func (c *countingClient) Get(
+	ctx context.Context,
+	key client.ObjectKey,
+	obj client.Object,
+	opts ...client.GetOption,
+) error {
+	c.gets++
+	return c.WithWatch.Get(ctx, key, obj, opts...)
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met before the deadline")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWatchingClient(t *testing.T) {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "s3", Namespace: "default"},
+		Data:       map[string][]byte{"key": []byte("v1")},
+	}
+	base := &countingClient{
+		WithWatch: fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret).Build(),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewWatchingClient(ctx, base)
+
+	key := client.ObjectKeyFromObject(secret)
+	var got corev1.Secret
+	for range 3 {
+		if err := c.Get(ctx, key, &got); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if base.gets != 1 {
+		t.Errorf("expected a single Get on the API server, got %d", base.gets)
+	}
+	if string(got.Data["key"]) != "v1" {
+		t.Errorf("unexpected secret data %v", got.Data)
+	}
+
+	// modifying the returned object must not alter the snapshot
+	got.Data["key"] = []byte("altered")
+
+	generation := c.Generation()
+	updated := secret.DeepCopy()
+	if err := base.Get(ctx, key, updated); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	updated.Data = map[string][]byte{"key": []byte("v2")}
+	if err := base.Update(ctx, updated); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitFor(t, func() bool { return c.Generation() > generation })
+
+	if err := c.Get(ctx, key, &got); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(got.Data["key"]) != "v2" {
+		t.Errorf("expected the snapshot to be updated, got %v", got.Data)
+	}
+
+	// a deleted object is dropped and read again from the API server
+	gets := base.gets
+	if err := base.Delete(ctx, updated); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitFor(t, func() bool {
+		c.mux.Lock()
+		defer c.mux.Unlock()
+		return len(c.objects) == 0
+	})
+	if err := c.Get(ctx, key, &got); err == nil {
+		t.Errorf("expected the deleted secret not to be found")
+	}
+	if base.gets != gets+1 {
+		t.Errorf("expected the API server to be queried again")
+	}
+}
@@ -0,0 +1,94 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package instance
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	pgbackrestapi "github.com/dalibo/cnpg-i-pgbackrest/api/v1"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/pgbackrest"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const stanzaCompatibilityCondition = "Compatibility"
+
+// detectVersion runs the pgBackRest version command of the sidecar image,
+// nil is returned when it can't be run: the options are then not checked.
+func detectVersion(ctx context.Context) *pgbackrest.Version {
+	contextLogger := log.FromContext(ctx)
+	v, err := pgbackrest.NewPgBackrest(nil).Version(ctx)
+	if err != nil {
+		contextLogger.Warning("can't detect the pgBackRest version, options won't be checked",
+			"error", err.Error())
+		return nil
+	}
+	contextLogger.Info("pgBackRest version detected", "version", v.String())
+	return &v
+}
+
+// stanzaCompatibility checks the options of the stanza against the
+// pgBackRest version, the error tells which options aren't supported.
+func stanzaCompatibility(
+	stanza *pgbackrestapi.Stanza,
+	version pgbackrest.Version,
+) (metav1.Condition, error) {
+	cond := metav1.Condition{
+		Type:               stanzaCompatibilityCondition,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Supported",
+		Message:            fmt.Sprintf("the options are supported by pgBackRest %s", version),
+		ObservedGeneration: stanza.Generation,
+	}
+	err := pgbackrest.CheckOptions(&stanza.Spec.Configuration, version)
+	if err != nil {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = "UnsupportedOptions"
+		cond.Message = err.Error()
+	}
+	return cond, err
+}
+
+// guardStanzaCompatibility records the pgBackRest version in the status of
+// the stanza, and refuses the stanzas using options it doesn't support
+// rather than letting pgBackRest fail on them. The status is only updated
+// when the version or the decision changes.
+func guardStanzaCompatibility(
+	ctx context.Context,
+	c client.Client,
+	stanza *pgbackrestapi.Stanza,
+	version *pgbackrest.Version,
+) error {
+	if version == nil {
+		return nil
+	}
+	var unsupported error
+	key := client.ObjectKeyFromObject(stanza)
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		cond, checkErr := stanzaCompatibility(stanza, *version)
+		unsupported = checkErr
+		if stanza.Status.PGBackRestVersion == version.String() &&
+			!conditionChanged(stanza.Status.Conditions, cond) {
+			return nil
+		}
+		stanza.Status.PGBackRestVersion = version.String()
+		apimeta.SetStatusCondition(&stanza.Status.Conditions, cond)
+		if err := c.Status().Update(ctx, stanza); err != nil {
+			if getErr := c.Get(ctx, key, stanza); getErr != nil {
+				return getErr
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return unsupported
+}
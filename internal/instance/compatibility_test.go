@@ -0,0 +1,75 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+package instance
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	pgbackrestapi "github.com/dalibo/cnpg-i-pgbackrest/api/v1"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/pgbackrest"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestGuardStanzaCompatibility(t *testing.T) {
+	scheme := runtime.NewScheme()
+	pgbackrestapi.AddKnownTypes(scheme)
+	stanza := &pgbackrestapi.Stanza{
+		ObjectMeta: metav1.ObjectMeta{Name: "stanza", Namespace: "default"},
+		Spec: pgbackrestapi.StanzaSpec{
+			Configuration: pgbackrestapi.StanzaConfiguration{
+				S3Repositories: []pgbackrestapi.S3Repository{
+					{Bucket: "bucket", Tags: map[string]string{"team": "db"}},
+				},
+			},
+		},
+	}
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithStatusSubresource(&pgbackrestapi.Stanza{}).
+		WithObjects(stanza).
+		Build()
+	ctx := context.Background()
+	getStanza := func() *pgbackrestapi.Stanza {
+		var s pgbackrestapi.Stanza
+		if err := c.Get(ctx, client.ObjectKeyFromObject(stanza), &s); err != nil {
+			t.Fatal(err)
+		}
+		return &s
+	}
+
+	if err := guardStanzaCompatibility(ctx, c, getStanza(), nil); err != nil {
+		t.Fatalf("an unknown version must not refuse the stanza, got %v", err)
+	}
+	if got := getStanza(); got.Status.PGBackRestVersion != "" || len(got.Status.Conditions) != 0 {
+		t.Errorf("the status must not change without version, got %+v", got.Status)
+	}
+
+	err := guardStanzaCompatibility(ctx, c, getStanza(), &pgbackrest.Version{Major: 2, Minor: 45})
+	if !errors.Is(err, pgbackrest.ErrUnsupportedOption) {
+		t.Fatalf("expected the tags to be refused, got %v", err)
+	}
+	got := getStanza()
+	cond := apimeta.FindStatusCondition(got.Status.Conditions, stanzaCompatibilityCondition)
+	if got.Status.PGBackRestVersion != "2.45.0" || cond == nil ||
+		cond.Status != metav1.ConditionFalse || cond.Reason != "UnsupportedOptions" {
+		t.Errorf("unexpected status %s %+v", got.Status.PGBackRestVersion, cond)
+	}
+
+	if err := guardStanzaCompatibility(
+		ctx, c, getStanza(), &pgbackrest.Version{Major: 2, Minor: 55, Patch: 1},
+	); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	got = getStanza()
+	cond = apimeta.FindStatusCondition(got.Status.Conditions, stanzaCompatibilityCondition)
+	if got.Status.PGBackRestVersion != "2.55.1" || cond == nil || cond.Status != metav1.ConditionTrue {
+		t.Errorf("unexpected status %s %+v", got.Status.PGBackRestVersion, cond)
+	}
+}
@@ -0,0 +1,189 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package instance
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	pgbackrestapi "github.com/dalibo/cnpg-i-pgbackrest/api/v1"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/config"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/pgbackrest"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DefaultDrainTimeout is how long the archive queue is drained when the
+	// instance stops, unless configured in the PluginConfig
+	DefaultDrainTimeout = 30 * time.Second
+
+	// drainStatusTimeout bounds the recording of the outcome in the status
+	// of the stanza, once the deadline of the draining is reached
+	drainStatusTimeout = 10 * time.Second
+)
+
+// SpoolDrainRunnable pushes the WAL segments left in the archive queue when
+// the instance stops. The sidecar is stopped after PostgreSQL, once it
+// received SIGTERM no new segment is queued.
+type SpoolDrainRunnable struct {
+	Client       client.Client
+	ClusterKey   types.NamespacedName
+	InstanceName string
+	PGDataPath   string
+	PGWALPath    string
+	Timeout      time.Duration
+	Recorder     events.EventRecorder
+	// PGBackRestVersion is the version of the pgBackRest binary, nil when
+	// it couldn't be detected
+	PGBackRestVersion *pgbackrest.Version
+}
+
+// Start waits for the termination of the sidecar, then drains the queue
+// until it's empty or the deadline is reached.
+func (d *SpoolDrainRunnable) Start(ctx context.Context) error {
+	<-ctx.Done()
+	contextLogger := log.FromContext(ctx).WithName("drain")
+	// the manager context is canceled, the draining gets its own deadline
+	drainCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), d.Timeout)
+	defer cancel()
+	if err := d.drain(log.IntoContext(drainCtx, contextLogger)); err != nil {
+		contextLogger.Error(err, "can't drain the archive queue")
+	}
+	return nil
+}
+
+// readySegments lists the WAL segments PostgreSQL marked as ready to be
+// archived
+func readySegments(walPath string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(walPath, "archive_status"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var segments []string
+	for _, entry := range entries {
+		if segment, ok := strings.CutSuffix(entry.Name(), ".ready"); ok {
+			segments = append(segments, segment)
+		}
+	}
+	slices.Sort(segments)
+	return segments, nil
+}
+
+// flushSegments pushes the segments in order, until the deadline is reached.
+// The segments not pushed are returned.
+func flushSegments(
+	ctx context.Context,
+	walPath string,
+	segments []string,
+	push func(context.Context, string) error,
+) (int, []string) {
+	contextLogger := log.FromContext(ctx)
+	flushed := 0
+	var unflushed []string
+	for i, segment := range segments {
+		if ctx.Err() != nil {
+			unflushed = append(unflushed, segments[i:]...)
+			break
+		}
+		if err := push(ctx, filepath.Join(walPath, segment)); err != nil {
+			contextLogger.Error(err, "can't push WAL segment", "WAL", segment)
+			unflushed = append(unflushed, segment)
+			continue
+		}
+		flushed++
+	}
+	return flushed, unflushed
+}
+
+func (d *SpoolDrainRunnable) drain(ctx context.Context) error {
+	contextLogger := log.FromContext(ctx)
+	segments, err := readySegments(d.PGWALPath)
+	if err != nil {
+		return err
+	}
+	if len(segments) == 0 {
+		contextLogger.Info("archive queue drained")
+		return nil
+	}
+
+	var cluster cnpgv1.Cluster
+	if err := d.Client.Get(ctx, d.ClusterKey, &cluster); err != nil {
+		return err
+	}
+	stanza, err := config.GetStanzaFromCluster(ctx, &cluster, d.Client, (*config.PluginConfiguration).GetStanzaRef)
+	if err != nil {
+		return err
+	}
+	if !stanza.Spec.Configuration.Archive.Async {
+		return nil
+	}
+	// the segments are pushed as by Archive, to a stanza it would accept
+	if err := guardStanzaOwnership(
+		ctx, d.Client, d.Recorder, stanza, &cluster, d.PGDataPath, "Drain",
+	); err != nil {
+		return fmt.Errorf("not draining the archive queue: %w", err)
+	}
+	if err := guardStanzaCompatibility(ctx, d.Client, stanza, d.PGBackRestVersion); err != nil {
+		return fmt.Errorf("not draining the archive queue: %w", err)
+	}
+	pgb, err := config.NewPgBackrest(ctx, stanza, d.Client)
+	if err != nil {
+		return err
+	}
+
+	contextLogger.Info("draining the archive queue", "segments", len(segments), "timeout", d.Timeout)
+	flushed, unflushed := flushSegments(ctx, d.PGWALPath, segments, func(ctx context.Context, wal string) error {
+		return <-pgb.PushWal(ctx, wal)
+	})
+	if len(unflushed) > 0 {
+		contextLogger.Warning("archive queue not drained", "flushed", flushed, "unflushed", unflushed)
+	} else {
+		contextLogger.Info("archive queue drained", "flushed", flushed)
+	}
+
+	statusCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), drainStatusTimeout)
+	defer cancel()
+	return recordDrain(statusCtx, d.Client, stanza, pgbackrestapi.StanzaDrain{
+		Instance:          d.InstanceName,
+		Time:              metav1.Now(),
+		FlushedSegments:   flushed,
+		UnflushedSegments: unflushed,
+	})
+}
+
+// recordDrain records the outcome of the draining in the status of the
+// stanza
+func recordDrain(
+	ctx context.Context,
+	c client.Client,
+	stanza *pgbackrestapi.Stanza,
+	drain pgbackrestapi.StanzaDrain,
+) error {
+	key := client.ObjectKeyFromObject(stanza)
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		stanza.Status.LastDrain = &drain
+		if err := c.Status().Update(ctx, stanza); err != nil {
+			if getErr := c.Get(ctx, key, stanza); getErr != nil {
+				return getErr
+			}
+			return err
+		}
+		return nil
+	})
+}
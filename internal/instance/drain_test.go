@@ -0,0 +1,124 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+package instance
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	pgbackrestapi "github.com/dalibo/cnpg-i-pgbackrest/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReadySegments(t *testing.T) {
+	walPath := t.TempDir()
+	if got, err := readySegments(walPath); err != nil || got != nil {
+		t.Fatalf("no archive status, got %v (%v)", got, err)
+	}
+	status := filepath.Join(walPath, "archive_status")
+	if err := os.Mkdir(status, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{
+		"000000010000000000000003.ready",
+		"000000010000000000000001.done",
+		"000000010000000000000002.ready",
+		"00000002.history.ready",
+	} {
+		if err := os.WriteFile(filepath.Join(status, f), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := readySegments(walPath)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := []string{"000000010000000000000002", "000000010000000000000003", "00000002.history"}
+	if !slices.Equal(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestFlushSegments(t *testing.T) {
+	segments := []string{"s1", "s2", "s3", "s4"}
+	testCases := []struct {
+		desc      string
+		failing   string
+		deadline  string
+		flushed   int
+		unflushed []string
+	}{
+		{"all pushed", "", "", 4, nil},
+		{"push failed", "s2", "", 3, []string{"s2"}},
+		{"deadline reached", "", "s2", 2, []string{"s3", "s4"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var pushed []string
+			flushed, unflushed := flushSegments(ctx, "/pg_wal", segments, func(_ context.Context, wal string) error {
+				pushed = append(pushed, wal)
+				if filepath.Base(wal) == tc.deadline {
+					cancel()
+				}
+				if filepath.Base(wal) == tc.failing {
+					return errors.New("push failed")
+				}
+				return nil
+			})
+			if flushed != tc.flushed || !slices.Equal(unflushed, tc.unflushed) {
+				t.Errorf("want %d %v, got %d %v", tc.flushed, tc.unflushed, flushed, unflushed)
+			}
+			if pushed[0] != filepath.Join("/pg_wal", "s1") {
+				t.Errorf("the segments must be pushed from the WAL directory, got %v", pushed)
+			}
+		})
+	}
+}
+
+func TestRecordDrain(t *testing.T) {
+	scheme := runtime.NewScheme()
+	pgbackrestapi.AddKnownTypes(scheme)
+	stanza := &pgbackrestapi.Stanza{
+		ObjectMeta: metav1.ObjectMeta{Name: "stanza", Namespace: "default"},
+	}
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithStatusSubresource(&pgbackrestapi.Stanza{}).
+		WithObjects(stanza).
+		Build()
+	ctx := context.Background()
+
+	var current pgbackrestapi.Stanza
+	if err := c.Get(ctx, client.ObjectKeyFromObject(stanza), &current); err != nil {
+		t.Fatal(err)
+	}
+	drain := pgbackrestapi.StanzaDrain{
+		Instance:          "cluster-1",
+		Time:              metav1.Now(),
+		FlushedSegments:   2,
+		UnflushedSegments: []string{"000000010000000000000003"},
+	}
+	if err := recordDrain(ctx, c, &current, drain); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	var got pgbackrestapi.Stanza
+	if err := c.Get(ctx, client.ObjectKeyFromObject(stanza), &got); err != nil {
+		t.Fatal(err)
+	}
+	last := got.Status.LastDrain
+	if last == nil || last.Instance != "cluster-1" || last.FlushedSegments != 2 ||
+		!slices.Equal(last.UnflushedSegments, drain.UnflushedSegments) {
+		t.Errorf("unexpected drain %+v", last)
+	}
+}
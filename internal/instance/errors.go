@@ -0,0 +1,36 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package instance
+
+import (
+	"errors"
+
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/pgbackrest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// toGRPCError converts the typed pgBackRest errors to gRPC status errors, so
+// CNPG can tell the end of the archive (NotFound) apart from failures worth
+// retrying. Other errors are returned unchanged.
+func toGRPCError(err error) error {
+	var code codes.Code
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, pgbackrest.ErrWALNotFound):
+		code = codes.NotFound
+	case errors.Is(err, pgbackrest.ErrRepoUnreachable):
+		code = codes.Unavailable
+	case errors.Is(err, pgbackrest.ErrStanzaMismatch), errors.Is(err, errStanzaNotOwned),
+		errors.Is(err, pgbackrest.ErrUnsupportedOption):
+		code = codes.FailedPrecondition
+	case errors.Is(err, pgbackrest.ErrLockContention):
+		code = codes.Aborted
+	default:
+		return err
+	}
+	return status.Error(code, err.Error())
+}
@@ -0,0 +1,41 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package instance
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/pgbackrest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestToGRPCError(t *testing.T) {
+	testCases := []struct {
+		desc string
+		err  error
+		want codes.Code
+	}{
+		{"WAL not found", pgbackrest.ErrWALNotFound, codes.NotFound},
+		{"wrapped repo error", fmt.Errorf("can't backup: %w", pgbackrest.ErrRepoUnreachable), codes.Unavailable},
+		{"stanza mismatch", pgbackrest.ErrStanzaMismatch, codes.FailedPrecondition},
+		{"stanza not owned", fmt.Errorf("%w: refused", errStanzaNotOwned), codes.FailedPrecondition},
+		{"unsupported option", fmt.Errorf("%w 2.40.0: tags", pgbackrest.ErrUnsupportedOption), codes.FailedPrecondition},
+		{"lock contention", pgbackrest.ErrLockContention, codes.Aborted},
+		{"other error", errors.New("boom"), codes.Unknown},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := status.Code(toGRPCError(tc.err)); got != tc.want {
+				t.Errorf("want %v, got %v", tc.want, got)
+			}
+		})
+	}
+	if toGRPCError(nil) != nil {
+		t.Error("nil error must stay nil")
+	}
+}
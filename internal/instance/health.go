@@ -0,0 +1,160 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package instance
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	pgbackrestapi "github.com/dalibo/cnpg-i-pgbackrest/api/v1"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/config"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/pgbackrest"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/utils"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// HealthPgBackRest is the health service checking that the pgBackRest
+	// binary runs
+	HealthPgBackRest = "pgbackrest"
+	// HealthStanza is the health service checking that the stanzas of the
+	// instance and their secrets resolve
+	HealthStanza = "stanza"
+	// HealthArchive is the health service checking that the last
+	// archive-push succeeded, and that the archiving isn't lagging
+	HealthArchive = "archive"
+
+	// archiveMaxLag is how long a WAL segment can wait to be archived
+	// before the archiving is considered unhealthy
+	archiveMaxLag = 10 * time.Minute
+)
+
+// newHealthChecker builds the checks of the sidecar. Its readiness only
+// depends on the pgBackRest binary and the stanzas: a failing archiving
+// doesn't prevent the instance from starting, it's reported by the archive
+// service.
+func newHealthChecker(
+	c client.Client,
+	clusterKey types.NamespacedName,
+	walPath string,
+	archive *archiveState,
+) *utils.HealthChecker {
+	return &utils.HealthChecker{
+		Checks: map[string]utils.HealthCheck{
+			HealthPgBackRest: CheckPgBackRest,
+			HealthStanza: func(ctx context.Context) error {
+				return checkStanzas(ctx, c, clusterKey)
+			},
+			HealthArchive: func(context.Context) error {
+				return archive.check(walPath, archiveMaxLag)
+			},
+		},
+		Readiness: []string{HealthPgBackRest, HealthStanza},
+	}
+}
+
+// CheckPgBackRest checks that the pgBackRest binary runs
+func CheckPgBackRest(ctx context.Context) error {
+	_, err := pgbackrest.NewPgBackrest(nil).Version(ctx)
+	return err
+}
+
+// checkStanzas checks that the stanzas used by the instance, to archive and
+// to replicate, exist and that their configuration, including the secrets,
+// resolves
+func checkStanzas(ctx context.Context, c client.Client, clusterKey types.NamespacedName) error {
+	var cluster cnpgv1.Cluster
+	if err := c.Get(ctx, clusterKey, &cluster); err != nil {
+		return err
+	}
+	conf, err := config.NewFromCluster(&cluster)
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{conf.StanzaRef, conf.ReplicaStanzaRef} {
+		if name == "" {
+			continue
+		}
+		var stanza pgbackrestapi.Stanza
+		if err := c.Get(ctx, client.ObjectKey{Namespace: cluster.Namespace, Name: name}, &stanza); err != nil {
+			return err
+		}
+		if _, err := config.GetEnvVarConfig(ctx, &stanza, c); err != nil {
+			return fmt.Errorf("stanza %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// archiveState records the outcome of the last archive-push
+type archiveState struct {
+	mu   sync.Mutex
+	wal  string
+	time time.Time
+	err  error
+}
+
+// record records the outcome of an archive-push, a nil state is ignored
+func (s *archiveState) record(wal string, err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.wal, s.time, s.err = wal, time.Now(), err
+}
+
+// check returns an error when the last archive-push failed, or when a WAL
+// segment has been waiting to be archived for longer than maxLag
+func (s *archiveState) check(walPath string, maxLag time.Duration) error {
+	s.mu.Lock()
+	wal, at, lastErr := s.wal, s.time, s.err
+	s.mu.Unlock()
+	if lastErr != nil {
+		return fmt.Errorf("archive-push of %s failed at %s: %w", wal, at.Format(time.RFC3339), lastErr)
+	}
+	oldest, err := oldestReady(walPath)
+	if err != nil {
+		return err
+	}
+	if !oldest.IsZero() && time.Since(oldest) > maxLag {
+		return fmt.Errorf("WAL segments waiting to be archived since %s", oldest.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// oldestReady returns when the oldest WAL segment waiting to be archived was
+// marked ready, zero when there's none
+func oldestReady(walPath string) (time.Time, error) {
+	entries, err := os.ReadDir(filepath.Join(walPath, "archive_status"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+	var oldest time.Time
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".ready") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			// archived in the meantime
+			continue
+		}
+		if oldest.IsZero() || info.ModTime().Before(oldest) {
+			oldest = info.ModTime()
+		}
+	}
+	return oldest, nil
+}
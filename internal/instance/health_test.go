@@ -0,0 +1,52 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+package instance
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestArchiveStateCheck(t *testing.T) {
+	walPath := t.TempDir()
+	var state archiveState
+	if err := state.check(walPath, time.Minute); err != nil {
+		t.Fatalf("nothing archived yet, unexpected error %v", err)
+	}
+
+	state.record("000000010000000000000001", errors.New("push failed"))
+	if err := state.check(walPath, time.Minute); err == nil {
+		t.Error("the failed archive-push must be reported")
+	}
+	state.record("000000010000000000000001", nil)
+	if err := state.check(walPath, time.Minute); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	status := filepath.Join(walPath, "archive_status")
+	if err := os.Mkdir(status, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	ready := filepath.Join(status, "000000010000000000000002.ready")
+	if err := os.WriteFile(ready, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := state.check(walPath, time.Minute); err != nil {
+		t.Errorf("a segment just marked ready, unexpected error %v", err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(ready, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := state.check(walPath, time.Minute); err == nil {
+		t.Error("the lagging archiving must be reported")
+	}
+
+	// a nil state is ignored
+	var none *archiveState
+	none.record("000000010000000000000003", nil)
+}
@@ -9,21 +9,33 @@ import (
 
 	"github.com/cloudnative-pg/cnpg-i/pkg/identity"
 	"github.com/dalibo/cnpg-i-pgbackrest/internal/metadata"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/pgbackrest"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/utils"
+	"google.golang.org/protobuf/proto"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 // IdentityImplementation implements IdentityServer
 type IdentityImplementation struct {
 	identity.UnimplementedIdentityServer
-	Client client.Client
+	Client            client.Client
+	PGBackRestVersion *pgbackrest.Version
+	// Health gives the readiness of the sidecar
+	Health *utils.HealthChecker
 }
 
-// GetPluginMetadata implements IdentityServer
+// GetPluginMetadata implements IdentityServer, the description gives the
+// version of pgBackRest run by the sidecar
 func (i IdentityImplementation) GetPluginMetadata(
 	_ context.Context,
 	_ *identity.GetPluginMetadataRequest,
 ) (*identity.GetPluginMetadataResponse, error) {
-	return &metadata.Data, nil
+	if i.PGBackRestVersion == nil {
+		return &metadata.Data, nil
+	}
+	data := proto.CloneOf(&metadata.Data)
+	data.Description = "pgBackRest " + i.PGBackRestVersion.String()
+	return data, nil
 }
 
 // GetPluginCapabilities implements IdentityServer
@@ -58,12 +70,13 @@ func (i IdentityImplementation) GetPluginCapabilities(
 	}, nil
 }
 
-// Probe implements IdentityServer
+// Probe implements IdentityServer, the plugin is ready when the pgBackRest
+// binary runs and the stanzas resolve
 func (i IdentityImplementation) Probe(
-	_ context.Context,
+	ctx context.Context,
 	_ *identity.ProbeRequest,
 ) (*identity.ProbeResponse, error) {
 	return &identity.ProbeResponse{
-		Ready: true,
+		Ready: i.Health == nil || i.Health.Ready(ctx),
 	}, nil
 }
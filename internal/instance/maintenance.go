@@ -26,6 +26,9 @@ type StanzaMaintenanceRunnable struct {
 	Client         client.Client
 	ClusterKey     types.NamespacedName
 	CurrentPodName string
+
+	// the full backups seeding the new repositories of the rotations
+	seeds seedBackups
 }
 
 func (c *StanzaMaintenanceRunnable) Start(ctx context.Context) error {
@@ -91,6 +94,10 @@ func (c *StanzaMaintenanceRunnable) maintenance(
 		return nil
 	}
 
+	if err := c.reconcileCipherRotation(ctx, stanza); err != nil {
+		return err
+	}
+
 	backups, err := c.getBackupsInfo(ctx, stanza)
 	if err != nil {
 		return err
@@ -111,12 +118,11 @@ func (c *StanzaMaintenanceRunnable) getBackupsInfo(
 	ctx context.Context,
 	stanza *pgbackrestapi.Stanza,
 ) ([]pgbackrestapi.BackupInfo, error) {
-	env, err := config.GetEnvVarConfig(ctx, stanza, c.Client)
+	pgbExec, err := config.NewPgBackrest(ctx, stanza, c.Client)
 	if err != nil {
 		return nil, err
 	}
-	pgbExec := pgbackrest.NewPgBackrest(env)
-	return pgbExec.GetBackupInfo()
+	return pgbExec.GetBackupInfo(ctx)
 }
 
 func (c *StanzaMaintenanceRunnable) updateBackupWindow(
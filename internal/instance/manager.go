@@ -10,6 +10,7 @@ import (
 
 	apipgbackrest "github.com/dalibo/cnpg-i-pgbackrest/api/v1"
 	extendedclient "github.com/dalibo/cnpg-i-pgbackrest/internal/instance/client"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/metadata"
 
 	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
 	"github.com/spf13/viper"
@@ -20,6 +21,7 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
@@ -34,9 +36,17 @@ func Start(ctx context.Context) error {
 	namespace := viper.GetString("namespace")
 	clusterName := viper.GetString("cluster-name")
 
+	drainTimeout := DefaultDrainTimeout
+	if d := viper.GetDuration("drain-timeout"); d > 0 {
+		drainTimeout = d
+	}
+
 	sc := generateScheme(ctx)
 	controllerOptions := ctrl.Options{
 		Scheme: sc,
+		// the runnables are given the time to drain the archive queue, and
+		// record its outcome
+		GracefulShutdownTimeout: ptr.To(drainTimeout + drainStatusTimeout),
 		Client: client.Options{
 			Cache: &client.CacheOptions{
 				DisableFor: []client.Object{
@@ -54,14 +64,32 @@ func Start(ctx context.Context) error {
 		setupLog.Error(err, "unable to start manager")
 		return err
 	}
-	customCacheClient := extendedclient.NewExtendedClient(mgr.GetClient())
+	// Stanzas and Secrets are read from snapshots kept up to date by watches,
+	// instead of being fetched on every WAL archived
+	watchClient, err := client.NewWithWatch(mgr.GetConfig(), client.Options{
+		Scheme: mgr.GetScheme(),
+		Mapper: mgr.GetRESTMapper(),
+	})
+	if err != nil {
+		setupLog.Error(err, "unable to create watch client")
+		return err
+	}
+	customCacheClient := extendedclient.NewWatchingClient(ctx, watchClient)
+	recorder := mgr.GetEventRecorder(metadata.PluginName)
+	pgbackrestVersion := detectVersion(ctx)
 	if err := mgr.Add(&PgbackrestPluginServer{
 		Client:       customCacheClient,
 		InstanceName: podName,
+		ClusterKey: types.NamespacedName{
+			Namespace: namespace,
+			Name:      clusterName,
+		},
 		// TODO: improve
-		PGDataPath: viper.GetString("pgdata"),
-		PGWALPath:  path.Join(viper.GetString("pgdata"), "pg_wal"),
-		PluginPath: viper.GetString("plugin-path"),
+		PGDataPath:        viper.GetString("pgdata"),
+		PGWALPath:         path.Join(viper.GetString("pgdata"), "pg_wal"),
+		PluginPath:        viper.GetString("plugin-path"),
+		Recorder:          recorder,
+		PGBackRestVersion: pgbackrestVersion,
 	}); err != nil {
 		setupLog.Error(err, "unable to create pbacrest plugin runnable/server")
 		return err
@@ -79,6 +107,24 @@ func Start(ctx context.Context) error {
 		return err
 	}
 
+	if err := mgr.Add(&SpoolDrainRunnable{
+		// the watches of the cached client stop with the manager
+		Client: watchClient,
+		ClusterKey: types.NamespacedName{
+			Namespace: namespace,
+			Name:      clusterName,
+		},
+		InstanceName:      podName,
+		PGDataPath:        viper.GetString("pgdata"),
+		PGWALPath:         path.Join(viper.GetString("pgdata"), "pg_wal"),
+		Timeout:           drainTimeout,
+		Recorder:          recorder,
+		PGBackRestVersion: pgbackrestVersion,
+	}); err != nil {
+		setupLog.Error(err, "unable to create the spool drain runnable")
+		return err
+	}
+
 	if err := mgr.Start(ctx); err != nil {
 		return err
 	}
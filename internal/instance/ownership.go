@@ -0,0 +1,171 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package instance
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	pgbackrestapi "github.com/dalibo/cnpg-i-pgbackrest/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/events"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const stanzaOwnershipCondition = "Ownership"
+
+// errStanzaNotOwned is returned when a cluster writes to a stanza owned by
+// another cluster, or holding the WAL of another database.
+var errStanzaNotOwned = errors.New("stanza owned by another cluster")
+
+// pgControlSystemID reads the system identifier of the database, stored at
+// the beginning of its control file.
+func pgControlSystemID(pgDataPath string) (string, error) {
+	f, err := os.Open(filepath.Join(pgDataPath, "global", "pg_control"))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	var buf [8]byte
+	if _, err := f.Read(buf[:]); err != nil {
+		return "", fmt.Errorf("can't read the control file: %w", err)
+	}
+	return strconv.FormatUint(binary.NativeEndian.Uint64(buf[:]), 10), nil
+}
+
+// stanzaOwnership decides whether the candidate may write to the stanza. It
+// returns the owner to record and the condition reporting the decision, the
+// error tells why the candidate is refused.
+func stanzaOwnership(
+	stanza *pgbackrestapi.Stanza,
+	candidate pgbackrestapi.StanzaOwner,
+) (*pgbackrestapi.StanzaOwner, metav1.Condition, error) {
+	owner := stanza.Status.Owner
+	cond := metav1.Condition{
+		Type:               stanzaOwnershipCondition,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: stanza.Generation,
+	}
+
+	recordedSystemID := ""
+	switch {
+	case owner != nil:
+		recordedSystemID = owner.SystemID
+	case stanza.Status.Creation != nil:
+		recordedSystemID = stanza.Status.Creation.SystemID
+	}
+
+	if owner != nil && owner.ClusterUID != candidate.ClusterUID {
+		cond.Reason = "ClusterMismatch"
+		cond.Message = fmt.Sprintf(
+			"the stanza is owned by the cluster %s (%s), the cluster %s (%s) is refused",
+			owner.ClusterName, owner.ClusterUID, candidate.ClusterName, candidate.ClusterUID,
+		)
+		return owner, cond, fmt.Errorf("%w: %s", errStanzaNotOwned, cond.Message)
+	}
+	if recordedSystemID != "" && candidate.SystemID != "" && recordedSystemID != candidate.SystemID {
+		cond.Reason = "SystemIDMismatch"
+		cond.Message = fmt.Sprintf(
+			"the stanza holds the database %s, the database %s of the cluster %s is refused",
+			recordedSystemID, candidate.SystemID, candidate.ClusterName,
+		)
+		return owner, cond, fmt.Errorf("%w: %s", errStanzaNotOwned, cond.Message)
+	}
+
+	next := owner.DeepCopy()
+	if next == nil {
+		next = candidate.DeepCopy()
+	} else if next.SystemID == "" {
+		next.SystemID = candidate.SystemID
+	}
+	cond.Status = metav1.ConditionTrue
+	cond.Reason = "Owned"
+	cond.Message = fmt.Sprintf("the stanza is owned by the cluster %s", next.ClusterName)
+	return next, cond, nil
+}
+
+// conditionChanged tells whether setting the condition would modify the
+// status.
+func conditionChanged(conditions []metav1.Condition, cond metav1.Condition) bool {
+	current := apimeta.FindStatusCondition(conditions, cond.Type)
+	return current == nil ||
+		current.Status != cond.Status ||
+		current.Reason != cond.Reason ||
+		current.Message != cond.Message ||
+		current.ObservedGeneration != cond.ObservedGeneration
+}
+
+// guardStanzaOwnership records the first cluster writing to the stanza as
+// its owner, and refuses the other clusters or databases: they would mix
+// their WAL and backups with the ones of the owner. The status is only
+// updated when the decision changes.
+func guardStanzaOwnership(
+	ctx context.Context,
+	c client.Client,
+	recorder events.EventRecorder,
+	stanza *pgbackrestapi.Stanza,
+	cluster *cnpgv1.Cluster,
+	pgDataPath string,
+	action string,
+) error {
+	contextLogger := log.FromContext(ctx)
+	systemID, err := pgControlSystemID(pgDataPath)
+	if err != nil {
+		contextLogger.Warning("can't read the system identifier, using the one of the cluster",
+			"error", err.Error())
+		systemID = cluster.Status.SystemID
+	}
+	candidate := pgbackrestapi.StanzaOwner{
+		ClusterName: cluster.Name,
+		ClusterUID:  cluster.UID,
+		SystemID:    systemID,
+		Since:       metav1.NewTime(time.Now()),
+	}
+
+	var refused error
+	key := client.ObjectKeyFromObject(stanza)
+	err = retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		owner, cond, ownershipErr := stanzaOwnership(stanza, candidate)
+		refused = ownershipErr
+		ownerChanged := ownershipErr == nil && (stanza.Status.Owner == nil ||
+			stanza.Status.Owner.SystemID != owner.SystemID)
+		if !ownerChanged && !conditionChanged(stanza.Status.Conditions, cond) {
+			return nil
+		}
+		stanza.Status.Owner = owner
+		apimeta.SetStatusCondition(&stanza.Status.Conditions, cond)
+		if err := c.Status().Update(ctx, stanza); err != nil {
+			// decide again on the latest version of the stanza
+			if getErr := c.Get(ctx, key, stanza); getErr != nil {
+				return getErr
+			}
+			return err
+		}
+		if ownershipErr == nil && ownerChanged {
+			contextLogger.Info("cluster recorded as the owner of the stanza",
+				"stanza", stanza.Name, "systemID", owner.SystemID)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if refused != nil && recorder != nil {
+		recorder.Eventf(stanza, cluster, corev1.EventTypeWarning,
+			"OwnershipRefused", action, "%s", refused.Error())
+	}
+	return refused
+}
@@ -0,0 +1,181 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+package instance
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	pgbackrestapi "github.com/dalibo/cnpg-i-pgbackrest/api/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestPgControlSystemID(t *testing.T) {
+	pgdata := t.TempDir()
+	if err := os.Mkdir(filepath.Join(pgdata, "global"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	control := make([]byte, 8192)
+	binary.NativeEndian.PutUint64(control, 7480000000000000001)
+	if err := os.WriteFile(filepath.Join(pgdata, "global", "pg_control"), control, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	got, err := pgControlSystemID(pgdata)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if got != "7480000000000000001" {
+		t.Errorf("want 7480000000000000001, got %s", got)
+	}
+	if _, err := pgControlSystemID(t.TempDir()); err == nil {
+		t.Errorf("expected an error without control file")
+	}
+}
+
+func TestStanzaOwnership(t *testing.T) {
+	owner := &pgbackrestapi.StanzaOwner{ClusterName: "cluster", ClusterUID: "uid-1", SystemID: "1"}
+	testCases := []struct {
+		desc      string
+		status    pgbackrestapi.StanzaStatus
+		candidate pgbackrestapi.StanzaOwner
+		reason    string
+		systemID  string
+	}{
+		{
+			"first cluster is recorded",
+			pgbackrestapi.StanzaStatus{},
+			pgbackrestapi.StanzaOwner{ClusterName: "cluster", ClusterUID: "uid-1", SystemID: "1"},
+			"Owned", "1",
+		},
+		{
+			"owner",
+			pgbackrestapi.StanzaStatus{Owner: owner},
+			pgbackrestapi.StanzaOwner{ClusterName: "cluster", ClusterUID: "uid-1", SystemID: "1"},
+			"Owned", "1",
+		},
+		{
+			"missing system identifier is completed",
+			pgbackrestapi.StanzaStatus{
+				Owner: &pgbackrestapi.StanzaOwner{ClusterName: "cluster", ClusterUID: "uid-1"},
+			},
+			pgbackrestapi.StanzaOwner{ClusterName: "cluster", ClusterUID: "uid-1", SystemID: "1"},
+			"Owned", "1",
+		},
+		{
+			"another cluster",
+			pgbackrestapi.StanzaStatus{Owner: owner},
+			pgbackrestapi.StanzaOwner{ClusterName: "copy", ClusterUID: "uid-2", SystemID: "1"},
+			"ClusterMismatch", "1",
+		},
+		{
+			"another database",
+			pgbackrestapi.StanzaStatus{Owner: owner},
+			pgbackrestapi.StanzaOwner{ClusterName: "cluster", ClusterUID: "uid-1", SystemID: "2"},
+			"SystemIDMismatch", "1",
+		},
+		{
+			"stanza created for another database",
+			pgbackrestapi.StanzaStatus{Creation: &pgbackrestapi.StanzaCreation{SystemID: "1"}},
+			pgbackrestapi.StanzaOwner{ClusterName: "copy", ClusterUID: "uid-2", SystemID: "2"},
+			"SystemIDMismatch", "",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			stanza := &pgbackrestapi.Stanza{Status: tc.status}
+			next, cond, err := stanzaOwnership(stanza, tc.candidate)
+			if cond.Reason != tc.reason {
+				t.Errorf("want reason %s, got %s", tc.reason, cond.Reason)
+			}
+			if (cond.Status == metav1.ConditionTrue) != (err == nil) {
+				t.Errorf("condition %s doesn't match error %v", cond.Status, err)
+			}
+			if err != nil && !errors.Is(err, errStanzaNotOwned) {
+				t.Errorf("unexpected error %v", err)
+			}
+			gotSystemID := ""
+			if next != nil {
+				gotSystemID = next.SystemID
+			}
+			if gotSystemID != tc.systemID {
+				t.Errorf("want system identifier %q, got %q", tc.systemID, gotSystemID)
+			}
+		})
+	}
+}
+
+func TestGuardStanzaOwnership(t *testing.T) {
+	scheme := runtime.NewScheme()
+	pgbackrestapi.AddKnownTypes(scheme)
+	stanza := &pgbackrestapi.Stanza{
+		ObjectMeta: metav1.ObjectMeta{Name: "stanza", Namespace: "default"},
+	}
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithStatusSubresource(&pgbackrestapi.Stanza{}).
+		WithObjects(stanza).
+		Build()
+	recorder := events.NewFakeRecorder(10)
+	ctx := context.Background()
+	newCluster := func(name, uid string) *cnpgv1.Cluster {
+		cluster := &cnpgv1.Cluster{}
+		cluster.Name = name
+		cluster.UID = types.UID("uid-" + uid)
+		cluster.Status.SystemID = "7480000000000000001"
+		return cluster
+	}
+	getStanza := func() *pgbackrestapi.Stanza {
+		var s pgbackrestapi.Stanza
+		if err := c.Get(ctx, client.ObjectKeyFromObject(stanza), &s); err != nil {
+			t.Fatal(err)
+		}
+		return &s
+	}
+
+	if err := guardStanzaOwnership(
+		ctx, c, recorder, getStanza(), newCluster("cluster", "1"), t.TempDir(), "Archive",
+	); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	got := getStanza()
+	if got.Status.Owner == nil || got.Status.Owner.ClusterUID != "uid-1" ||
+		got.Status.Owner.SystemID != "7480000000000000001" {
+		t.Fatalf("unexpected owner %+v", got.Status.Owner)
+	}
+
+	err := guardStanzaOwnership(
+		ctx, c, recorder, getStanza(), newCluster("copy", "2"), t.TempDir(), "Backup",
+	)
+	if !errors.Is(err, errStanzaNotOwned) {
+		t.Fatalf("expected the cluster to be refused, got %v", err)
+	}
+	got = getStanza()
+	cond := apimeta.FindStatusCondition(got.Status.Conditions, stanzaOwnershipCondition)
+	if cond == nil || cond.Status != metav1.ConditionFalse || cond.Reason != "ClusterMismatch" {
+		t.Errorf("unexpected condition %+v", cond)
+	}
+	if got.Status.Owner.ClusterUID != "uid-1" {
+		t.Errorf("the owner must not change, got %+v", got.Status.Owner)
+	}
+	select {
+	case event := <-recorder.Events:
+		if !strings.HasPrefix(event, "Warning OwnershipRefused") {
+			t.Errorf("unexpected event %s", event)
+		}
+	default:
+		t.Errorf("expected an event")
+	}
+}
@@ -0,0 +1,126 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package instance
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	pgbackrestapi "github.com/dalibo/cnpg-i-pgbackrest/api/v1"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/config"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/pgbackrest"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// uninitializedRepositories returns the repositories of the stanza on which
+// it isn't recorded as created.
+func uninitializedRepositories(stanza *pgbackrestapi.Stanza) []int {
+	var created []int
+	if stanza.Status.Creation != nil {
+		created = stanza.Status.Creation.Repositories
+	}
+	var missing []int
+	for _, index := range config.RepositoryIndexes(stanza) {
+		if !slices.Contains(created, index) {
+			missing = append(missing, index)
+		}
+	}
+	return missing
+}
+
+// nextStanzaCreation adds the repositories the stanza was just created on to
+// its creation status. The repositories removed from the stanza are
+// forgotten, so that the stanza is created on a repository added later with
+// the same index.
+func nextStanzaCreation(
+	current *pgbackrestapi.StanzaCreation,
+	indexes []int,
+	created []int,
+	db *pgbackrest.DBInfo,
+	now time.Time,
+) *pgbackrestapi.StanzaCreation {
+	next := &pgbackrestapi.StanzaCreation{}
+	if current != nil {
+		current.DeepCopyInto(next)
+	}
+	next.Repositories = slices.DeleteFunc(next.Repositories, func(index int) bool {
+		return !slices.Contains(indexes, index)
+	})
+	if len(created) == 0 {
+		return next
+	}
+	for _, index := range created {
+		if !slices.Contains(next.Repositories, index) {
+			next.Repositories = append(next.Repositories, index)
+		}
+	}
+	slices.Sort(next.Repositories)
+	if db != nil {
+		next.SystemID = strconv.FormatUint(db.SystemID, 10)
+		next.PGVersion = db.Version
+	}
+	next.CreatedAt = metav1.NewTime(now)
+	return next
+}
+
+func updateStanzaCreation(
+	ctx context.Context,
+	c client.Client,
+	stanza *pgbackrestapi.Stanza,
+	created []int,
+	db *pgbackrest.DBInfo,
+) error {
+	key := client.ObjectKeyFromObject(stanza)
+	now := time.Now()
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		if err := c.Get(ctx, key, stanza); err != nil {
+			return err
+		}
+		stanza.Status.Creation = nextStanzaCreation(
+			stanza.Status.Creation,
+			config.RepositoryIndexes(stanza),
+			created,
+			db,
+			now,
+		)
+		return c.Status().Update(ctx, stanza)
+	})
+}
+
+// ensureStanzaCreated creates the stanza on the repositories where it isn't
+// recorded as created, and records them in the status. pgBackRest isn't
+// called when the stanza is recorded on every repository.
+func ensureStanzaCreated(
+	ctx context.Context,
+	c client.Client,
+	stanza *pgbackrestapi.Stanza,
+	pgb *pgbackrest.PgBackrestRunner,
+) error {
+	missing := uninitializedRepositories(stanza)
+	if len(missing) == 0 {
+		return nil
+	}
+	contextLogger := log.FromContext(ctx)
+	name := stanza.Spec.Configuration.Name
+	var db *pgbackrest.DBInfo
+	for _, repo := range missing {
+		if err := pgb.CreateStanza(ctx, name, repo); err != nil {
+			return err
+		}
+		info, err := pgb.StanzaDatabase(ctx, repo)
+		if err != nil {
+			return err
+		}
+		db = info
+		contextLogger.Info("stanza created",
+			"stanza", name, "repository", repo, "systemID", info.SystemID, "pgVersion", info.Version)
+	}
+	return updateStanzaCreation(ctx, c, stanza, missing, db)
+}
@@ -0,0 +1,103 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+package instance
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	pgbackrestapi "github.com/dalibo/cnpg-i-pgbackrest/api/v1"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/pgbackrest"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestUninitializedRepositories(t *testing.T) {
+	stanza := &pgbackrestapi.Stanza{
+		Spec: pgbackrestapi.StanzaSpec{
+			Configuration: pgbackrestapi.StanzaConfiguration{
+				S3Repositories:    []pgbackrestapi.S3Repository{{}, {}},
+				AzureRepositories: []pgbackrestapi.AzureRepository{{}},
+			},
+		},
+	}
+	testCases := []struct {
+		desc   string
+		status pgbackrestapi.StanzaStatus
+		want   []int
+	}{
+		{"never created", pgbackrestapi.StanzaStatus{}, []int{1, 2, 3}},
+		{
+			"created on every repository",
+			pgbackrestapi.StanzaStatus{
+				Creation: &pgbackrestapi.StanzaCreation{Repositories: []int{1, 2, 3}},
+			},
+			nil,
+		},
+		{
+			"repository added",
+			pgbackrestapi.StanzaStatus{
+				Creation: &pgbackrestapi.StanzaCreation{Repositories: []int{1, 2}},
+			},
+			[]int{3},
+		},
+		{
+			"passphrase rotation",
+			pgbackrestapi.StanzaStatus{
+				Creation: &pgbackrestapi.StanzaCreation{Repositories: []int{1, 2, 3}},
+				Repositories: []pgbackrestapi.RepositoryStatus{
+					{Index: 1, Rotation: &pgbackrestapi.CipherRotation{Index: 4}},
+				},
+			},
+			[]int{4},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			s := stanza.DeepCopy()
+			s.Status = tc.status
+			if got := uninitializedRepositories(s); !slices.Equal(got, tc.want) {
+				t.Errorf("want %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestNextStanzaCreation(t *testing.T) {
+	created := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	now := created.Add(time.Hour)
+	db := &pgbackrest.DBInfo{ID: 1, RepoKey: 2, SystemID: 7480000000000000001, Version: "17"}
+	current := &pgbackrestapi.StanzaCreation{
+		SystemID:     "7480000000000000001",
+		PGVersion:    "17",
+		Repositories: []int{1, 3},
+		CreatedAt:    metav1.NewTime(created),
+	}
+
+	t.Run("first creation", func(t *testing.T) {
+		next := nextStanzaCreation(nil, []int{1, 2}, []int{2, 1}, db, now)
+		if !slices.Equal(next.Repositories, []int{1, 2}) || next.SystemID != "7480000000000000001" ||
+			next.PGVersion != "17" || !next.CreatedAt.Time.Equal(now) {
+			t.Errorf("unexpected creation %+v", next)
+		}
+	})
+
+	t.Run("repository added", func(t *testing.T) {
+		next := nextStanzaCreation(current, []int{1, 2, 3}, []int{2}, db, now)
+		if !slices.Equal(next.Repositories, []int{1, 2, 3}) || !next.CreatedAt.Time.Equal(now) {
+			t.Errorf("unexpected creation %+v", next)
+		}
+		if !slices.Equal(current.Repositories, []int{1, 3}) {
+			t.Errorf("the current creation must not be modified, got %+v", current)
+		}
+	})
+
+	t.Run("repository removed", func(t *testing.T) {
+		next := nextStanzaCreation(current, []int{1, 2}, nil, nil, now)
+		if !slices.Equal(next.Repositories, []int{1}) || next.SystemID != "7480000000000000001" ||
+			!next.CreatedAt.Time.Equal(created) {
+			t.Errorf("unexpected creation %+v", next)
+		}
+	})
+}
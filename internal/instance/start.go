@@ -11,8 +11,11 @@ import (
 	"github.com/cloudnative-pg/cnpg-i/pkg/backup"
 	"github.com/cloudnative-pg/cnpg-i/pkg/metrics"
 	"github.com/cloudnative-pg/cnpg-i/pkg/wal"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/pgbackrest"
 	"github.com/dalibo/cnpg-i-pgbackrest/internal/utils"
 	"google.golang.org/grpc"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -24,31 +27,46 @@ type PgbackrestPluginServer struct {
 	// mutually exclusive with serverAddress
 	PluginPath   string
 	InstanceName string
+	ClusterKey   types.NamespacedName
+	Recorder     events.EventRecorder
+	// PGBackRestVersion is the version of the pgBackRest binary, nil when
+	// it couldn't be detected
+	PGBackRestVersion *pgbackrest.Version
 }
 
 // Start starts the GRPC service
 func (c *PgbackrestPluginServer) Start(ctx context.Context) error {
+	archive := &archiveState{}
+	health := newHealthChecker(c.Client, c.ClusterKey, c.PGWALPath, archive)
 	enrich := func(server *grpc.Server) error {
 		wal.RegisterWALServer(server, &WALSrvImplementation{
-			InstanceName: c.InstanceName,
-			Client:       c.Client,
-			PGDataPath:   c.PGDataPath,
-			PGWALPath:    c.PGWALPath,
+			InstanceName:      c.InstanceName,
+			Client:            c.Client,
+			PGDataPath:        c.PGDataPath,
+			PGWALPath:         c.PGWALPath,
+			Recorder:          c.Recorder,
+			PGBackRestVersion: c.PGBackRestVersion,
+			archive:           archive,
 		})
 		backup.RegisterBackupServer(server, BackupServiceImplementation{
-			Client:       c.Client,
-			InstanceName: c.InstanceName,
+			Client:            c.Client,
+			InstanceName:      c.InstanceName,
+			PGDataPath:        c.PGDataPath,
+			Recorder:          c.Recorder,
+			PGBackRestVersion: c.PGBackRestVersion,
 		})
 		metrics.RegisterMetricsServer(server, &metricsImpl{
 			Client: c.Client,
 		})
-		utils.AddHealthCheck(server)
+		utils.AddHealthCheck(server, health)
 		return nil
 	}
 
 	srv := http.Server{
 		IdentityImpl: IdentityImplementation{
-			Client: c.Client,
+			Client:            c.Client,
+			PGBackRestVersion: c.PGBackRestVersion,
+			Health:            health,
 		},
 		Enrichers:  []http.ServerEnricher{enrich},
 		PluginPath: c.PluginPath,
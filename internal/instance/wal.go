@@ -13,6 +13,7 @@ import (
 	"github.com/dalibo/cnpg-i-pgbackrest/internal/config"
 	"github.com/dalibo/cnpg-i-pgbackrest/internal/pgbackrest"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
@@ -23,9 +24,13 @@ type WALSrvImplementation struct {
 	PGDataPath string
 	PGWALPath  string
 	// mutually exclusive with serverAddress
-	PluginPath    string
-	InstanceName  string
-	StanzaCreated bool
+	PluginPath        string
+	InstanceName      string
+	Recorder          events.EventRecorder
+	PGBackRestVersion *pgbackrest.Version
+	// archive records the outcome of the archive-push for the health
+	// checks, nil when they aren't served
+	archive *archiveState
 }
 
 // GetCapabilities gets the capabilities of the WAL service
@@ -58,9 +63,14 @@ func (WALSrvImplementation) GetCapabilities(
 func (w_impl *WALSrvImplementation) Archive(
 	ctx context.Context,
 	request *wal.WALArchiveRequest,
-) (*wal.WALArchiveResult, error) {
+) (_ *wal.WALArchiveResult, err error) {
 	contextLogger := log.FromContext(ctx)
 	walName := request.GetSourceFileName()
+	defer func() { w_impl.archive.record(walName, err) }()
+	conf, err := config.NewFromClusterJSON(request.GetClusterDefinition())
+	if err != nil {
+		return nil, err
+	}
 	stanza, err := config.GetStanza(ctx,
 		request,
 		w_impl.Client,
@@ -69,26 +79,24 @@ func (w_impl *WALSrvImplementation) Archive(
 	if err != nil {
 		return nil, err
 	}
-	env, err := config.GetEnvVarConfig(ctx, stanza, w_impl.Client)
+	if err := guardStanzaOwnership(
+		ctx, w_impl.Client, w_impl.Recorder, stanza, conf.Cluster, w_impl.PGDataPath, "Archive",
+	); err != nil {
+		return nil, toGRPCError(err)
+	}
+	if err := guardStanzaCompatibility(ctx, w_impl.Client, stanza, w_impl.PGBackRestVersion); err != nil {
+		return nil, toGRPCError(err)
+	}
+	pgb, err := config.NewPgBackrest(ctx, stanza, w_impl.Client)
 	if err != nil {
 		return nil, err
 	}
-	pgb := pgbackrest.NewPgBackrest(env)
-	if !w_impl.StanzaCreated {
-		ok, err := pgb.EnsureStanzaExists(stanza.Spec.Configuration.Name)
-		if err != nil {
-			return nil, fmt.Errorf("stanza creation failed: %w", err)
-		}
-		if ok {
-			w_impl.StanzaCreated = ok
-			contextLogger.Info("stanza created while archiving", "WAL", walName)
-		}
-	} else {
-		contextLogger.Info("stanza already exists, let's archive", "WAL", walName)
+	if err := ensureStanzaCreated(ctx, w_impl.Client, stanza, pgb); err != nil {
+		return nil, toGRPCError(fmt.Errorf("stanza creation failed: %w", err))
 	}
-	errCh := pgb.PushWal(context.Background(), walName)
+	errCh := pgb.PushWal(ctx, walName)
 	if err := <-errCh; err != nil {
-		return nil, err
+		return nil, toGRPCError(err)
 	}
 	contextLogger.Info("pgBackRest archive-push successful", "WAL", walName)
 	return &wal.WALArchiveResult{}, nil
@@ -142,16 +150,15 @@ func (w WALSrvImplementation) Restore(
 	if err != nil {
 		return nil, err
 	}
-	env, err := config.GetEnvVarConfig(ctx, stanza, w.Client)
+	pgb, err := config.NewPgBackrest(ctx, stanza, w.Client)
 	if err != nil {
 		return nil, err
 	}
 	logger.Info("Restoring WAL", "WAL", walName, "destination", dstPath)
 
-	pgb := pgbackrest.NewPgBackrest(env)
 	errCh := pgb.GetWAL(ctx, walName, dstPath)
 	if err := <-errCh; err != nil {
-		return nil, err
+		return nil, toGRPCError(err)
 	}
 
 	logger.Info("Successfully restored WAL", "WAL", walName, "destination", dstPath)
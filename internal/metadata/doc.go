@@ -9,6 +9,20 @@ import "github.com/cloudnative-pg/cnpg-i/pkg/identity"
 // PluginName is the name of the plugin
 const PluginName = "pgbackrest.dalibo.com"
 
+// RebuildAnnotationName is the Cluster annotation listing (comma separated)
+// the instances that should be rebuilt with a pgBackRest delta restore
+// the next time their Pod is created
+const RebuildAnnotationName = PluginName + "/rebuild"
+
+// RebuiltAnnotationName marks the Pods whose rebuild succeeded, once they're
+// removed from the rebuild annotation of their Cluster
+const RebuiltAnnotationName = PluginName + "/rebuilt"
+
+// StanzaFinalizerName is the finalizer keeping a Stanza until no Cluster
+// references it, and its repositories are cleaned up according to its
+// deletion policy
+const StanzaFinalizerName = PluginName + "/stanza"
+
 // Data is the metadata of this plugin
 var Data = identity.GetPluginMetadataResponse{
 	Name:          PluginName,
@@ -0,0 +1,67 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package operator
+
+import (
+	"path"
+	"slices"
+
+	pluginv1 "github.com/dalibo/cnpg-i-pgbackrest/api/v1"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/config"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/utils"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// caBundles lists the CA bundles of the repositories of the stanzas
+func caBundles(stanzas []*pluginv1.Stanza) []*pluginv1.CABundle {
+	var bundles []*pluginv1.CABundle
+	for _, stanza := range stanzas {
+		conf := &stanza.Spec.Configuration
+		for i := range conf.S3Repositories {
+			if b := conf.S3Repositories[i].CABundle; b != nil {
+				bundles = append(bundles, b)
+			}
+		}
+		for i := range conf.AzureRepositories {
+			if b := conf.AzureRepositories[i].CABundle; b != nil {
+				bundles = append(bundles, b)
+			}
+		}
+	}
+	return bundles
+}
+
+// injectCABundles mounts the objects holding the CA bundles on the given
+// containers, where the pgBackRest repo-storage-ca-file options point to.
+func injectCABundles(spec *corev1.PodSpec, bundles []*pluginv1.CABundle, containerNames ...string) {
+	for _, b := range bundles {
+		volName := config.CABundleVolumeName(b)
+		vol := corev1.Volume{Name: volName}
+		switch kind, name, _ := config.CABundleSource(b); kind {
+		case "configmap":
+			vol.ConfigMap = &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: name},
+			}
+		case "secret":
+			vol.Secret = &corev1.SecretVolumeSource{SecretName: name}
+		default:
+			continue
+		}
+		spec.Volumes = utils.EnsureVolume(spec.Volumes, vol)
+		for _, containers := range [][]corev1.Container{spec.InitContainers, spec.Containers} {
+			for i := range containers {
+				c := &containers[i]
+				if !slices.Contains(containerNames, c.Name) {
+					continue
+				}
+				c.VolumeMounts = utils.EnsureVolumeMount(c.VolumeMounts, corev1.VolumeMount{
+					Name:      volName,
+					MountPath: path.Join(config.CABundleDirectory, volName),
+					ReadOnly:  true,
+				})
+			}
+		}
+	}
+}
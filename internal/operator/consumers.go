@@ -0,0 +1,137 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package operator
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	pluginv1 "github.com/dalibo/cnpg-i-pgbackrest/api/v1"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/config"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// stanzaRoles returns the roles of the stanza for the Cluster of the plugin
+// configuration, none when the Cluster doesn't reference it.
+func stanzaRoles(conf *config.PluginConfiguration, name string) []string {
+	var roles []string
+	if conf.StanzaRef == name {
+		roles = append(roles, pluginv1.StanzaRoleArchive)
+	}
+	if conf.RecoveryStanzaRef == name {
+		roles = append(roles, pluginv1.StanzaRoleRecovery)
+	}
+	if conf.ReplicaStanzaRef == name {
+		roles = append(roles, pluginv1.StanzaRoleReplica)
+	}
+	return roles
+}
+
+// stanzaConsumers lists the Clusters referencing the stanza, with their roles
+func stanzaConsumers(
+	clusters []cnpgv1.Cluster,
+	stanza *pluginv1.Stanza,
+) ([]pluginv1.StanzaConsumer, error) {
+	var consumers []pluginv1.StanzaConsumer
+	for i := range clusters {
+		if clusters[i].Namespace != stanza.Namespace {
+			continue
+		}
+		conf, err := config.NewFromCluster(&clusters[i])
+		if err != nil {
+			return nil, err
+		}
+		if roles := stanzaRoles(conf, stanza.Name); len(roles) > 0 {
+			consumers = append(consumers, pluginv1.StanzaConsumer{
+				Cluster: clusters[i].Name,
+				Roles:   roles,
+			})
+		}
+	}
+	slices.SortFunc(consumers, func(a, b pluginv1.StanzaConsumer) int {
+		return strings.Compare(a.Cluster, b.Cluster)
+	})
+	return consumers, nil
+}
+
+// upsertConsumer sets the roles of the stanza for a Cluster among the
+// consumers, the Cluster is removed without roles.
+func upsertConsumer(
+	consumers []pluginv1.StanzaConsumer,
+	cluster string,
+	roles []string,
+) []pluginv1.StanzaConsumer {
+	next := make([]pluginv1.StanzaConsumer, 0, len(consumers)+1)
+	for i := range consumers {
+		if consumers[i].Cluster != cluster {
+			next = append(next, *consumers[i].DeepCopy())
+		}
+	}
+	if len(roles) > 0 {
+		next = append(next, pluginv1.StanzaConsumer{Cluster: cluster, Roles: roles})
+	}
+	slices.SortFunc(next, func(a, b pluginv1.StanzaConsumer) int {
+		return strings.Compare(a.Cluster, b.Cluster)
+	})
+	return next
+}
+
+// updateStanzaConsumers sets the consumers of the stanza, computed from its
+// latest version. The status is only updated when they changed.
+func updateStanzaConsumers(
+	ctx context.Context,
+	c client.Client,
+	stanza *pluginv1.Stanza,
+	consumersOf func(*pluginv1.Stanza) ([]pluginv1.StanzaConsumer, error),
+) error {
+	key := client.ObjectKeyFromObject(stanza)
+	first := true
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		if !first {
+			if err := c.Get(ctx, key, stanza); err != nil {
+				return err
+			}
+		}
+		first = false
+		consumers, err := consumersOf(stanza)
+		if err != nil {
+			return err
+		}
+		if len(consumers) == 0 {
+			consumers = nil
+		}
+		if equality.Semantic.DeepEqual(consumers, stanza.Status.Consumers) &&
+			stanza.Status.ConsumerCount == len(consumers) {
+			return nil
+		}
+		stanza.Status.Consumers = consumers
+		stanza.Status.ConsumerCount = len(consumers)
+		return c.Status().Update(ctx, stanza)
+	})
+}
+
+// recordConsumer records the roles of the stanzas for the Cluster in their
+// status.
+func (r ReconcilerImplementation) recordConsumer(
+	ctx context.Context,
+	conf *config.PluginConfiguration,
+	stanzas []pluginv1.Stanza,
+) error {
+	for i := range stanzas {
+		err := updateStanzaConsumers(ctx, r.Client, &stanzas[i],
+			func(stanza *pluginv1.Stanza) ([]pluginv1.StanzaConsumer, error) {
+				roles := stanzaRoles(conf, stanza.Name)
+				return upsertConsumer(stanza.Status.Consumers, conf.Cluster.Name, roles), nil
+			})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+package operator
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	pluginv1 "github.com/dalibo/cnpg-i-pgbackrest/api/v1"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/config"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/metadata"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestStanzaRoles(t *testing.T) {
+	conf := &config.PluginConfiguration{
+		StanzaRef:         "main",
+		RecoveryStanzaRef: "origin",
+		ReplicaStanzaRef:  "main",
+	}
+	testCases := []struct {
+		name  string
+		roles []string
+	}{
+		{"main", []string{pluginv1.StanzaRoleArchive, pluginv1.StanzaRoleReplica}},
+		{"origin", []string{pluginv1.StanzaRoleRecovery}},
+		{"other", nil},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := stanzaRoles(conf, tc.name); !reflect.DeepEqual(got, tc.roles) {
+				t.Errorf("want %v, got %v", tc.roles, got)
+			}
+		})
+	}
+}
+
+func TestUpsertConsumer(t *testing.T) {
+	consumers := []pluginv1.StanzaConsumer{
+		{Cluster: "b", Roles: []string{pluginv1.StanzaRoleArchive}},
+		{Cluster: "c", Roles: []string{pluginv1.StanzaRoleRecovery}},
+	}
+	testCases := []struct {
+		desc    string
+		cluster string
+		roles   []string
+		want    []string
+	}{
+		{"added", "a", []string{pluginv1.StanzaRoleReplica}, []string{"a", "b", "c"}},
+		{"updated", "b", []string{pluginv1.StanzaRoleRecovery}, []string{"b", "c"}},
+		{"removed", "c", nil, []string{"b"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			next := upsertConsumer(consumers, tc.cluster, tc.roles)
+			var got []string
+			for _, c := range next {
+				got = append(got, c.Cluster)
+				if c.Cluster == tc.cluster && !reflect.DeepEqual(c.Roles, tc.roles) {
+					t.Errorf("want roles %v, got %v", tc.roles, c.Roles)
+				}
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("want clusters %v, got %v", tc.want, got)
+			}
+		})
+	}
+	if consumers[0].Roles[0] != pluginv1.StanzaRoleArchive {
+		t.Errorf("the consumers must not be modified")
+	}
+}
+
+func TestStanzaReconciler_Consumers(t *testing.T) {
+	stanza := &pluginv1.Stanza{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "stanza",
+			Namespace:  "default",
+			Finalizers: []string{metadata.StanzaFinalizerName},
+		},
+		Status: pluginv1.StanzaStatus{
+			Consumers: []pluginv1.StanzaConsumer{
+				{Cluster: "deleted", Roles: []string{pluginv1.StanzaRoleArchive}},
+			},
+			ConsumerCount: 1,
+		},
+	}
+	cluster := testCluster("cluster", map[string]string{"stanzaRef": "stanza"})
+	c := newFakeClient(stanza, cluster)
+	r := &StanzaReconciler{Client: c, Scheme: scheme}
+	runReconcile(t, r, stanza)
+	got := &pluginv1.Stanza{ObjectMeta: stanza.ObjectMeta}
+	getObject(t, c, got)
+	want := []pluginv1.StanzaConsumer{{Cluster: "cluster", Roles: []string{pluginv1.StanzaRoleArchive}}}
+	if !reflect.DeepEqual(got.Status.Consumers, want) || got.Status.ConsumerCount != 1 {
+		t.Errorf("unexpected consumers %+v (%d)", got.Status.Consumers, got.Status.ConsumerCount)
+	}
+
+	if err := c.Delete(context.Background(), cluster); err != nil {
+		t.Fatal(err)
+	}
+	runReconcile(t, r, stanza)
+	getObject(t, c, got)
+	if got.Status.Consumers != nil || got.Status.ConsumerCount != 0 {
+		t.Errorf("the deleted cluster must be forgotten, got %+v", got.Status.Consumers)
+	}
+}
+
+func TestRecordConsumer(t *testing.T) {
+	stanza := &pluginv1.Stanza{
+		ObjectMeta: metav1.ObjectMeta{Name: "stanza", Namespace: "default"},
+	}
+	c := newFakeClient(stanza)
+	impl := ReconcilerImplementation{Client: c}
+	conf := &config.PluginConfiguration{
+		Cluster:           &cnpgv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "default"}},
+		RecoveryStanzaRef: "stanza",
+	}
+	current := &pluginv1.Stanza{ObjectMeta: stanza.ObjectMeta}
+	getObject(t, c, current)
+	if err := impl.recordConsumer(context.Background(), conf, []pluginv1.Stanza{*current}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	got := &pluginv1.Stanza{ObjectMeta: stanza.ObjectMeta}
+	getObject(t, c, got)
+	want := []pluginv1.StanzaConsumer{{Cluster: "cluster", Roles: []string{pluginv1.StanzaRoleRecovery}}}
+	if !reflect.DeepEqual(got.Status.Consumers, want) || got.Status.ConsumerCount != 1 {
+		t.Errorf("unexpected consumers %+v (%d)", got.Status.Consumers, got.Status.ConsumerCount)
+	}
+}
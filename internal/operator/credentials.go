@@ -0,0 +1,177 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package operator
+
+import (
+	"fmt"
+	"path"
+	"slices"
+
+	pluginv1 "github.com/dalibo/cnpg-i-pgbackrest/api/v1"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/config"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+const (
+	awsTokenVolumeName = "pgbackrest-aws-token"
+	awsTokenPath       = "/var/run/secrets/pgbackrest/aws"
+	// the kubelet refreshes the token when 80% of its lifetime has elapsed
+	tokenExpirationSeconds int64 = 86400
+	// azureWorkloadIdentityLabel asks the Microsoft Entra Workload ID webhook
+	// to inject the federated token and the AZURE_* variables in the pod
+	azureWorkloadIdentityLabel = "azure.workload.identity/use"
+)
+
+// s3WebIdentity returns the web identity of the S3 repositories using the
+// web-id key type. The role is given to pgBackRest through variables of the
+// process environment, so only one role can be assumed by a container: the
+// repositories must all use the same web identity.
+func s3WebIdentity(stanzas []*pluginv1.Stanza) (*pluginv1.S3WebIdentity, error) {
+	var identity *pluginv1.S3WebIdentity
+	var owner string
+	for _, stanza := range stanzas {
+		for _, repo := range stanza.Spec.Configuration.S3Repositories {
+			if repo.KeyType != pluginv1.S3KeyTypeWebID || repo.WebIdentity == nil {
+				continue
+			}
+			name := fmt.Sprintf("S3 repository %s of the stanza %s", repo.Bucket, stanza.Name)
+			if identity == nil {
+				identity, owner = repo.WebIdentity, name
+				continue
+			}
+			if *repo.WebIdentity != *identity {
+				return nil, fmt.Errorf(
+					"the %s and the %s use different web identities, only one role can be assumed",
+					owner, name)
+			}
+		}
+	}
+	return identity, nil
+}
+
+// checkWebIdentities checks that the stanzas used by the instances of the
+// Cluster, to archive and to replicate, share one web identity. The recovery
+// stanza is used by another pod, the recovery job.
+func checkWebIdentities(conf *config.PluginConfiguration, stanzas []pluginv1.Stanza) error {
+	var used []*pluginv1.Stanza
+	for i := range stanzas {
+		if name := stanzas[i].Name; name == conf.StanzaRef || name == conf.ReplicaStanzaRef {
+			used = append(used, &stanzas[i])
+		}
+	}
+	_, err := s3WebIdentity(used)
+	return err
+}
+
+// injectS3WebIdentity projects a service account token for the web identity
+// audience into the given containers, along with the variables used by
+// pgBackRest to exchange it for temporary credentials.
+func injectS3WebIdentity(
+	spec *corev1.PodSpec,
+	identity *pluginv1.S3WebIdentity,
+	containerNames ...string,
+) {
+	audience := identity.Audience
+	if audience == "" {
+		audience = "sts.amazonaws.com"
+	}
+	spec.Volumes = utils.EnsureVolume(spec.Volumes, corev1.Volume{
+		Name: awsTokenVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			Projected: &corev1.ProjectedVolumeSource{
+				Sources: []corev1.VolumeProjection{
+					{
+						ServiceAccountToken: &corev1.ServiceAccountTokenProjection{
+							Audience:          audience,
+							ExpirationSeconds: ptr.To(tokenExpirationSeconds),
+							Path:              "token",
+						},
+					},
+				},
+			},
+		},
+	})
+	env := []corev1.EnvVar{
+		{Name: "AWS_ROLE_ARN", Value: identity.RoleARN},
+		{Name: "AWS_WEB_IDENTITY_TOKEN_FILE", Value: path.Join(awsTokenPath, "token")},
+	}
+	for _, containers := range [][]corev1.Container{spec.InitContainers, spec.Containers} {
+		for i := range containers {
+			c := &containers[i]
+			if !slices.Contains(containerNames, c.Name) {
+				continue
+			}
+			c.VolumeMounts = utils.EnsureVolumeMount(c.VolumeMounts, corev1.VolumeMount{
+				Name:      awsTokenVolumeName,
+				MountPath: awsTokenPath,
+				ReadOnly:  true,
+			})
+			c.Env = ensureEnv(c.Env, env...)
+		}
+	}
+}
+
+// ensureEnv sets the variables, replacing the existing ones with the same
+// name.
+func ensureEnv(env []corev1.EnvVar, vars ...corev1.EnvVar) []corev1.EnvVar {
+	for _, v := range vars {
+		i := slices.IndexFunc(env, func(e corev1.EnvVar) bool { return e.Name == v.Name })
+		if i < 0 {
+			env = append(env, v)
+		} else {
+			env[i] = v
+		}
+	}
+	return env
+}
+
+// azureWorkloadIdentity reports whether one of the Azure repositories
+// authenticates with Microsoft Entra Workload ID.
+func azureWorkloadIdentity(stanzas []*pluginv1.Stanza) bool {
+	for _, stanza := range stanzas {
+		for _, repo := range stanza.Spec.Configuration.AzureRepositories {
+			if repo.KeyType == pluginv1.AzureKeyTypeWorkloadIdentity {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// injectAzureWorkloadIdentity labels the pod so that the Workload ID webhook
+// projects the federated token into all its containers. The client ID is
+// read by the webhook from the annotations of the service account.
+func injectAzureWorkloadIdentity(meta *metav1.ObjectMeta) {
+	if meta.Labels == nil {
+		meta.Labels = make(map[string]string)
+	}
+	meta.Labels[azureWorkloadIdentityLabel] = "true"
+}
+
+// injectCredentials gives the containers of the pod access to the
+// repositories of the stanzas: the web identity of the S3 repositories, the
+// workload identity of the Azure ones, and the CA bundles.
+func injectCredentials(
+	meta *metav1.ObjectMeta,
+	spec *corev1.PodSpec,
+	stanzas []*pluginv1.Stanza,
+	containerNames ...string,
+) error {
+	identity, err := s3WebIdentity(stanzas)
+	if err != nil {
+		return err
+	}
+	if identity != nil {
+		injectS3WebIdentity(spec, identity, containerNames...)
+	}
+	if azureWorkloadIdentity(stanzas) {
+		injectAzureWorkloadIdentity(meta)
+	}
+	injectCABundles(spec, caBundles(stanzas), containerNames...)
+	return nil
+}
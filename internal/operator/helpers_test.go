@@ -0,0 +1,69 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+package operator
+
+import (
+	"context"
+	"testing"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	pluginv1 "github.com/dalibo/cnpg-i-pgbackrest/api/v1"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/metadata"
+	batchv1 "k8s.io/api/batch/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// newFakeClient builds a client holding the objects, with the status
+// subresources updated by the controllers
+func newFakeClient(objs ...client.Object) client.Client {
+	return fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithStatusSubresource(&pluginv1.Stanza{}, &batchv1.Job{}).
+		WithObjects(objs...).
+		Build()
+}
+
+// testCluster builds a Cluster of the default namespace using the plugin
+// with the given parameters
+func testCluster(name string, params map[string]string) *cnpgv1.Cluster {
+	cluster := &cnpgv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(name + "-uid")},
+		Spec: cnpgv1.ClusterSpec{
+			Plugins: []cnpgv1.PluginConfiguration{
+				{Name: metadata.PluginName, Parameters: params},
+			},
+		},
+	}
+	cluster.SetGroupVersionKind(cnpgv1.SchemeGroupVersion.WithKind(cnpgv1.ClusterKind))
+	return cluster
+}
+
+// runReconcile reconciles the object, the test fails on error
+func runReconcile(t *testing.T, r reconcile.Reconciler, obj client.Object) {
+	t.Helper()
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(obj)}
+	if _, err := r.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+// getObject reads the object named like obj into it, it reports whether it
+// exists
+func getObject(t *testing.T, c client.Client, obj client.Object) bool {
+	t.Helper()
+	err := c.Get(context.Background(), client.ObjectKeyFromObject(obj), obj)
+	if apierrs.IsNotFound(err) {
+		return false
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return true
+}
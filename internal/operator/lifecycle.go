@@ -9,12 +9,15 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path"
+	"slices"
 	"strings"
 
 	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
 	"github.com/cloudnative-pg/cnpg-i-machinery/pkg/pluginhelper/decoder"
 	"github.com/cloudnative-pg/cnpg-i-machinery/pkg/pluginhelper/object"
 	"github.com/cloudnative-pg/cnpg-i/pkg/lifecycle"
+	machineryapi "github.com/cloudnative-pg/machinery/pkg/api"
 	"github.com/cloudnative-pg/machinery/pkg/log"
 	pluginv1 "github.com/dalibo/cnpg-i-pgbackrest/api/v1"
 	"github.com/dalibo/cnpg-i-pgbackrest/internal/config"
@@ -22,6 +25,7 @@ import (
 	"github.com/dalibo/cnpg-i-pgbackrest/internal/utils"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -30,7 +34,13 @@ import (
 )
 
 const (
-	SIDECAR_NAME string = "plugin-pgbackrest"
+	SIDECAR_NAME           string = "plugin-pgbackrest"
+	REBUILD_CONTAINER_NAME string = "plugin-pgbackrest-rebuild"
+	SPOOL_PATH             string = "/var/spool/pgbackrest"
+	CONFIG_RENDER_PATH     string = "/run/pgbackrest"
+	// EXPORTER_PORT_NAME is the name of the port of the exporter, port names
+	// are unique in a pod (the one of PostgreSQL is named metrics)
+	EXPORTER_PORT_NAME string = "pgbr-metrics"
 )
 
 // LifecycleImplementation is the implementation of the lifecycle handler
@@ -101,7 +111,7 @@ func (impl LifecycleImplementation) LifecycleHook(
 	case "Pod":
 		return impl.reconcilePod(ctx, &cluster, request, pluginConfig)
 	case "Job":
-		return impl.reconcileJob(ctx, &cluster, request)
+		return impl.reconcileJob(ctx, &cluster, request, pluginConfig)
 	default:
 		return nil, fmt.Errorf("unsupported kind: %s", kind)
 	}
@@ -122,6 +132,7 @@ func (impl LifecycleImplementation) reconcileJob(
 	ctx context.Context,
 	cluster *cnpgv1.Cluster,
 	request *lifecycle.OperatorLifecycleRequest,
+	pluginConfig *config.PluginConfiguration,
 ) (*lifecycle.OperatorLifecycleResponse, error) {
 	logger := log.FromContext(ctx).WithName("lifecycle")
 
@@ -147,12 +158,20 @@ func (impl LifecycleImplementation) reconcileJob(
 		return nil, nil
 	}
 
+	var pc pluginv1.PluginConfig
+	if err := impl.getSharedPluginConfig(ctx, &pc, pluginConfig); err != nil {
+		return nil, err
+	}
+
 	mutatedJob := job.DeepCopy()
 	podSpec := &mutatedJob.Spec.Template.Spec
 
 	sidecarContainer := &corev1.Container{Args: []string{"restore"}}
 	sidecarContainer.Name = "pgbackrest-sidecar"
-	reconcilePodSpec(cluster, podSpec, role, sidecarContainer, false)
+	if resources := restoreResources(&pc.Spec); resources != nil {
+		sidecarContainer.Resources = *resources
+	}
+	reconcilePodSpec(cluster, &pc.Spec, podSpec, role, sidecarContainer, false)
 
 	// Inject plugin-specific volume mounts
 	// only needed here, for postgres container, it's done by the CNPG machenery
@@ -179,6 +198,33 @@ func (impl LifecycleImplementation) reconcileJob(
 		podSpec.InitContainers = append(podSpec.InitContainers, *sidecarContainer)
 	}
 
+	if err := impl.reconcileJobSpoolVolume(ctx, cluster, pluginConfig, &pc.Spec, podSpec); err != nil {
+		return nil, err
+	}
+
+	if pc.Spec.ConfigRendering == pluginv1.ConfigRenderingFile {
+		injectConfigRendering(podSpec, sidecarContainer.Name)
+	}
+
+	if pluginConfig.RecoveryStanzaRef != "" {
+		stanzas, err := impl.getStanzas(
+			ctx,
+			cluster,
+			[]config.StanzaRefGetter{(*config.PluginConfiguration).GetRecoveryStanzaRef},
+		)
+		if err != nil {
+			return nil, err
+		}
+		if err := injectCredentials(
+			&mutatedJob.Spec.Template.ObjectMeta,
+			podSpec,
+			stanzas,
+			sidecarContainer.Name,
+		); err != nil {
+			return nil, err
+		}
+	}
+
 	patch, err := object.CreatePatch(mutatedJob, &job)
 	if err != nil {
 		return nil, err
@@ -188,8 +234,181 @@ func (impl LifecycleImplementation) reconcileJob(
 	return &lifecycle.OperatorLifecycleResponse{JsonPatch: patch}, nil
 }
 
+// restoreResources returns the resources of the restore sidecar: the ones
+// defined for the restore, or the ones of the other sidecars
+func restoreResources(pc *pluginv1.PluginConfigSpec) *corev1.ResourceRequirements {
+	if pc.RestoreConfig != nil && pc.RestoreConfig.Resources != nil {
+		return pc.RestoreConfig.Resources
+	}
+	return pc.Resources
+}
+
+// reconcileJobSpoolVolume gives the restore sidecar a spool directory when
+// the recovery stanza uses asynchronous archiving, so that archive-get can
+// prefetch WAL while PostgreSQL replays them. The job is short-lived, an
+// emptyDir is enough.
+func (impl LifecycleImplementation) reconcileJobSpoolVolume(
+	ctx context.Context,
+	cluster *cnpgv1.Cluster,
+	pluginConfig *config.PluginConfiguration,
+	pc *pluginv1.PluginConfigSpec,
+	podSpec *corev1.PodSpec,
+) error {
+	if pluginConfig.RecoveryStanzaRef == "" {
+		return nil
+	}
+	stanza, err := config.GetStanzaFromCluster(
+		ctx,
+		cluster,
+		impl.Client,
+		(*config.PluginConfiguration).GetRecoveryStanzaRef,
+	)
+	if err != nil {
+		return err
+	}
+	if !needsSpool(&stanza.Spec.Configuration) {
+		return nil
+	}
+
+	size := resource.MustParse(getSpoolWALSize(cluster.Spec.WalStorage, pc.StorageConfig))
+	injectSpoolVolume(podSpec, "pgbackrest-sidecar", corev1.Volume{
+		Name: "pgbackrest-spool",
+		VolumeSource: corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{SizeLimit: &size},
+		},
+	})
+	return nil
+}
+
+// injectConfigRendering adds a memory-backed volume where the plugin
+// containers render the pgBackRest configuration files, and tells them to do
+// so through the environment. Each container gets its own directory, where it
+// removes the files rendered for previous credentials.
+func injectConfigRendering(spec *corev1.PodSpec, containerNames ...string) {
+	const volName = "pgbackrest-config"
+	spec.Volumes = utils.EnsureVolume(spec.Volumes, corev1.Volume{
+		Name: volName,
+		VolumeSource: corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{Medium: corev1.StorageMediumMemory},
+		},
+	})
+	for _, containers := range [][]corev1.Container{spec.InitContainers, spec.Containers} {
+		for i := range containers {
+			c := &containers[i]
+			if !slices.Contains(containerNames, c.Name) {
+				continue
+			}
+			c.VolumeMounts = utils.EnsureVolumeMount(c.VolumeMounts, corev1.VolumeMount{
+				Name:      volName,
+				MountPath: CONFIG_RENDER_PATH,
+			})
+			if !slices.ContainsFunc(c.Env, func(e corev1.EnvVar) bool {
+				return e.Name == config.ConfigDirectoryEnv
+			}) {
+				c.Env = append(c.Env, corev1.EnvVar{
+					Name:  config.ConfigDirectoryEnv,
+					Value: path.Join(CONFIG_RENDER_PATH, c.Name),
+				})
+			}
+		}
+	}
+}
+
+// needsSpool reports whether pgBackRest needs a spool directory for the
+// given stanza configuration, for archive-push or archive-get queues.
+func needsSpool(conf *pluginv1.StanzaConfiguration) bool {
+	return conf.ProcessMax != 1 && conf.Archive.Async
+}
+
+// injectSpoolVolume adds the volume to the pod spec and mounts it on the
+// spool path of the given init container.
+func injectSpoolVolume(spec *corev1.PodSpec, containerName string, vol corev1.Volume) {
+	spec.Volumes = utils.EnsureVolume(spec.Volumes, vol)
+	for i := range spec.InitContainers {
+		if spec.InitContainers[i].Name == containerName {
+			spec.InitContainers[i].VolumeMounts = utils.EnsureVolumeMount(
+				spec.InitContainers[i].VolumeMounts,
+				corev1.VolumeMount{
+					Name:      vol.Name,
+					MountPath: SPOOL_PATH,
+				},
+			)
+		}
+	}
+}
+
+// injectExporterWebConfig mounts the Secret holding the web configuration of
+// the exporter, along with the files it references, where the exporter is
+// told to read it
+func injectExporterWebConfig(
+	spec *corev1.PodSpec,
+	exporter *corev1.Container,
+	ref *machineryapi.SecretKeySelector,
+) {
+	const volName = "pgbackrest-exporter-web"
+	if ref == nil {
+		return
+	}
+	spec.Volumes = utils.EnsureVolume(spec.Volumes, corev1.Volume{
+		Name: volName,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{SecretName: ref.Name},
+		},
+	})
+	exporter.VolumeMounts = utils.EnsureVolumeMount(exporter.VolumeMounts, corev1.VolumeMount{
+		Name:      volName,
+		MountPath: pluginv1.ExporterWebConfigPath,
+		ReadOnly:  true,
+	})
+}
+
+// sidecarImage returns the image of the sidecar containers, with or without
+// the exporter
+func sidecarImage(isExporter bool) string {
+	if isExporter {
+		if i, ok := os.LookupEnv("SIDECAR_EXPORTER_IMAGE"); ok {
+			return i
+		}
+		return "pgbackrest-sidecar-exporter"
+	}
+	if i, ok := os.LookupEnv("SIDECAR_IMAGE"); ok {
+		return i
+	}
+	return "pgbackrest-sidecar"
+}
+
+// pluginImage returns the image of the sidecar containers defined by the
+// PluginConfig, or the one of the operator environment
+func pluginImage(pc *pluginv1.PluginConfigSpec, isExporter bool) string {
+	if isExporter && pc.ExporterConfig != nil && pc.ExporterConfig.Image != "" {
+		return pc.ExporterConfig.Image
+	}
+	if !isExporter && pc.Image != "" {
+		return pc.Image
+	}
+	return sidecarImage(isExporter)
+}
+
+// sidecarSecurityContext is the restricted security context of the
+// containers running the sidecar images
+func sidecarSecurityContext() *corev1.SecurityContext {
+	return &corev1.SecurityContext{
+		AllowPrivilegeEscalation: ptr.To(false),
+		RunAsNonRoot:             ptr.To(true),
+		Privileged:               ptr.To(false),
+		ReadOnlyRootFilesystem:   ptr.To(true),
+		SeccompProfile: &corev1.SeccompProfile{
+			Type: corev1.SeccompProfileTypeRuntimeDefault,
+		},
+		Capabilities: &corev1.Capabilities{
+			Drop: []corev1.Capability{"ALL"},
+		},
+	}
+}
+
 func reconcilePodSpec(
 	cluster *cnpgv1.Cluster,
+	pc *pluginv1.PluginConfigSpec,
 	spec *corev1.PodSpec,
 	mainContainerName string,
 	containerConfig *corev1.Container,
@@ -209,35 +428,50 @@ func reconcilePodSpec(
 		},
 	}
 	// Set required fields
-	img := "pgbackrest-sidecar"
-	if isExporter {
-		img = "pgbackrest-sidecar-exporter"
-		if i, ok := os.LookupEnv("SIDECAR_EXPORTER_IMAGE"); ok {
-			img = i
+	containerConfig.Image = pluginImage(pc, isExporter)
+	containerConfig.ImagePullPolicy = cluster.Spec.ImagePullPolicy
+	if pc.ImagePullPolicy != "" {
+		containerConfig.ImagePullPolicy = pc.ImagePullPolicy
+	}
+	containerConfig.SecurityContext = sidecarSecurityContext()
+	if pc.SecurityContext != nil {
+		containerConfig.SecurityContext = pc.SecurityContext.DeepCopy()
+	}
+	containerConfig.Env = mergeEnv(envFromContainer(mainContainerName, spec, containerConfig.Env), pc.Env)
+	for _, source := range pc.EnvFrom {
+		if !slices.ContainsFunc(containerConfig.EnvFrom, func(e corev1.EnvFromSource) bool {
+			return equality.Semantic.DeepEqual(e, source)
+		}) {
+			containerConfig.EnvFrom = append(containerConfig.EnvFrom, source)
 		}
-	} else if i, ok := os.LookupEnv("SIDECAR_IMAGE"); ok {
-		img = i
 	}
-	containerConfig.Image = img
-	containerConfig.ImagePullPolicy = cluster.Spec.ImagePullPolicy
-	containerConfig.SecurityContext = &corev1.SecurityContext{
-		AllowPrivilegeEscalation: ptr.To(false),
-		RunAsNonRoot:             ptr.To(true),
-		Privileged:               ptr.To(false),
-		ReadOnlyRootFilesystem:   ptr.To(true),
-		SeccompProfile: &corev1.SeccompProfile{
-			Type: corev1.SeccompProfileTypeRuntimeDefault,
-		},
-		Capabilities: &corev1.Capabilities{
-			Drop: []corev1.Capability{"ALL"},
-		},
+	for _, vm := range pc.AdditionalVolumeMounts {
+		containerConfig.VolumeMounts = utils.EnsureVolumeMount(containerConfig.VolumeMounts, vm)
 	}
-	containerConfig.Env = envFromContainer(mainContainerName, spec, containerConfig.Env)
 	containerConfig.StartupProbe = baseProbe.DeepCopy()
 	containerConfig.RestartPolicy = ptr.To(corev1.ContainerRestartPolicyAlways)
+	for _, secret := range pc.ImagePullSecrets {
+		if !slices.Contains(spec.ImagePullSecrets, secret) {
+			spec.ImagePullSecrets = append(spec.ImagePullSecrets, secret)
+		}
+	}
 	object.InjectPluginVolumeSpec(spec)
 }
 
+// mergeEnv adds the extra variables to the environment, replacing the ones
+// with the same name
+func mergeEnv(env []corev1.EnvVar, extra []corev1.EnvVar) []corev1.EnvVar {
+	for _, e := range extra {
+		i := slices.IndexFunc(env, func(v corev1.EnvVar) bool { return v.Name == e.Name })
+		if i == -1 {
+			env = append(env, e)
+		} else {
+			env[i] = e
+		}
+	}
+	return env
+}
+
 func envFromContainer(
 	srcContainerName string,
 	srcPod *corev1.PodSpec,
@@ -272,7 +506,7 @@ func (impl LifecycleImplementation) requestPVC(
 	cluster *cnpgv1.Cluster,
 	pod *corev1.Pod,
 ) (string, error) {
-	name := pod.Name + "-pgbackrest-spool"
+	name := spoolPVCName(pod.Name)
 	pvc := &corev1.PersistentVolumeClaim{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace: cluster.Namespace,
@@ -287,23 +521,39 @@ func (impl LifecycleImplementation) requestPVC(
 				},
 			},
 		},
-		Spec: corev1.PersistentVolumeClaimSpec{
-			StorageClassName: &stClass,
-			AccessModes: []corev1.PersistentVolumeAccessMode{
-				corev1.ReadWriteOnce,
-			},
-			Resources: corev1.VolumeResourceRequirements{
-				Requests: corev1.ResourceList{
-					corev1.ResourceStorage: resource.MustParse(stSize),
-				},
-			},
-		},
+		Spec: spoolPVCSpec(stClass, stSize),
 	}
 	// Create PVC if it does not exist
 	err := impl.Client.Create(ctx, pvc)
 	return name, client.IgnoreAlreadyExists(err)
 }
 
+// spoolPVCName returns the name of the PersistentVolumeClaim holding the
+// spool of an instance
+func spoolPVCName(instance string) string {
+	return instance + spoolPVCSuffix
+}
+
+// spoolPVCSpec returns the claim of a spool volume. An empty storage class
+// would disable dynamic provisioning, the cluster default storage class is
+// used instead.
+func spoolPVCSpec(stClass, stSize string) corev1.PersistentVolumeClaimSpec {
+	spec := corev1.PersistentVolumeClaimSpec{
+		AccessModes: []corev1.PersistentVolumeAccessMode{
+			corev1.ReadWriteOnce,
+		},
+		Resources: corev1.VolumeResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceStorage: resource.MustParse(stSize),
+			},
+		},
+	}
+	if stClass != "" {
+		spec.StorageClassName = &stClass
+	}
+	return spec
+}
+
 // returns the configured size for the spool WAL volume.
 //
 // The size is determined by using the following precedence:
@@ -351,34 +601,40 @@ func (impl LifecycleImplementation) injectWALVolume(
 	}
 
 	// create a PVC based on plugin config (or WAL size with fallback to 1Gi if required)
-	stSize := getSpoolWALSize(cluster.Spec.WalStorage, pc.Spec.StorageConfig)
-	pvcName, err := impl.requestPVC(ctx, pc.Spec.StorageConfig.StorageClass, stSize, cluster, pod)
-	if err != nil {
-		return err
+	var stClass string
+	var volumeType pluginv1.SpoolVolumeType
+	if pc.Spec.StorageConfig != nil {
+		stClass = pc.Spec.StorageConfig.StorageClass
+		volumeType = pc.Spec.StorageConfig.VolumeType
 	}
+	stSize := getSpoolWALSize(cluster.Spec.WalStorage, pc.Spec.StorageConfig)
 
-	// Inject the volume and volume mount into the Pod Spec
-	volName := fmt.Sprintf("%s-wal-vol", pod.Name)
-
-	pod.Spec.Volumes = utils.EnsureVolume(
-		pod.Spec.Volumes,
-		corev1.Volume{
-			Name: volName,
-			VolumeSource: corev1.VolumeSource{
-				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
-					ClaimName: pvcName,
-				},
+	var source corev1.VolumeSource
+	switch volumeType {
+	case pluginv1.SpoolVolumeEmptyDir:
+		size := resource.MustParse(stSize)
+		source.EmptyDir = &corev1.EmptyDirVolumeSource{SizeLimit: &size}
+	case pluginv1.SpoolVolumeEphemeral:
+		source.Ephemeral = &corev1.EphemeralVolumeSource{
+			VolumeClaimTemplate: &corev1.PersistentVolumeClaimTemplate{
+				Spec: spoolPVCSpec(stClass, stSize),
 			},
-		},
-	)
+		}
+	default:
+		pvcName, err := impl.requestPVC(ctx, stClass, stSize, cluster, pod)
+		if err != nil {
+			return err
+		}
+		source.PersistentVolumeClaim = &corev1.PersistentVolumeClaimVolumeSource{
+			ClaimName: pvcName,
+		}
+	}
 
-	pod.Spec.InitContainers[sidecar].VolumeMounts = utils.EnsureVolumeMount(
-		pod.Spec.InitContainers[sidecar].VolumeMounts,
-		corev1.VolumeMount{
-			Name:      volName,
-			MountPath: "/var/spool/pgbackrest",
-		},
-	)
+	// Inject the volume and volume mount into the Pod Spec
+	injectSpoolVolume(&pod.Spec, SIDECAR_NAME, corev1.Volume{
+		Name:         fmt.Sprintf("%s-wal-vol", pod.Name),
+		VolumeSource: source,
+	})
 
 	return nil
 }
@@ -414,33 +670,48 @@ func createPatch(
 	}, nil
 }
 
+// podStanzaRefs lists the stanzas used by the instances: the one used to
+// archive WAL, and the replica one from which a designated primary fetches
+// WAL with archive-get.
+func podStanzaRefs(pluginConfig *config.PluginConfiguration) []config.StanzaRefGetter {
+	var getRefs []config.StanzaRefGetter
+	if pluginConfig.StanzaRef != "" {
+		getRefs = append(getRefs, (*config.PluginConfiguration).GetStanzaRef)
+	}
+	if pluginConfig.ReplicaStanzaRef != "" {
+		getRefs = append(getRefs, (*config.PluginConfiguration).GetReplicaStanzaRef)
+	}
+	return getRefs
+}
+
+func (impl LifecycleImplementation) getStanzas(
+	ctx context.Context,
+	cluster *cnpgv1.Cluster,
+	getRefs []config.StanzaRefGetter,
+) ([]*pluginv1.Stanza, error) {
+	stanzas := make([]*pluginv1.Stanza, 0, len(getRefs))
+	for _, getRef := range getRefs {
+		stanza, err := config.GetStanzaFromCluster(ctx, cluster, impl.Client, getRef)
+		if err != nil {
+			return nil, err
+		}
+		stanzas = append(stanzas, stanza)
+	}
+	return stanzas, nil
+}
+
 func (impl LifecycleImplementation) reconcileWALVolume(
 	ctx context.Context,
 	cluster *cnpgv1.Cluster,
-	request *lifecycle.OperatorLifecycleRequest,
+	stanzas []*pluginv1.Stanza,
 	pluginConfig *config.PluginConfiguration,
 	mutated *corev1.Pod,
 ) error {
-	// If a plugin configuration is defined and a stanza can be retrieved,
-	// inject the WAL volume only when async archiving is enabled and ProcessMax != 1.
-	if pluginConfig.PluginConfigRef == "" || pluginConfig.StanzaRef == "" {
-		return nil
-	}
-
-	stanza, err := config.GetStanza(
-		ctx,
-		request,
-		impl.Client,
-		(*config.PluginConfiguration).GetStanzaRef,
-	)
-	if err != nil {
-		return err
-	}
-
-	conf := stanza.Spec.Configuration
-	if conf.ProcessMax != 1 && conf.Archive.Async {
-		if err := impl.injectWALVolume(ctx, pluginConfig, mutated, cluster); err != nil {
-			return err
+	// Inject the WAL volume only when async archiving is enabled (and
+	// ProcessMax != 1) on one of the stanzas used by the instance.
+	for _, stanza := range stanzas {
+		if needsSpool(&stanza.Spec.Configuration) {
+			return impl.injectWALVolume(ctx, pluginConfig, mutated, cluster)
 		}
 	}
 
@@ -482,7 +753,12 @@ func (impl LifecycleImplementation) reconcilePod(
 
 	mutatedPod := pod.DeepCopy()
 	// Reuse reconcilePodSpec to mutate PodSpec
-	reconcilePodSpec(cluster, &mutatedPod.Spec, "postgres", &sidecar, false)
+	reconcilePodSpec(cluster, &pc.Spec, &mutatedPod.Spec, "postgres", &sidecar, false)
+	if pc.Spec.DrainTimeout != nil {
+		sidecar.Env = mergeEnv(sidecar.Env, []corev1.EnvVar{
+			{Name: config.DrainTimeoutEnv, Value: pc.Spec.DrainTimeout.Duration.String()},
+		})
+	}
 	if err := object.InjectPluginInitContainerSidecarSpec(&mutatedPod.Spec, &sidecar, true); err != nil {
 		return nil, err
 	}
@@ -493,14 +769,16 @@ func (impl LifecycleImplementation) reconcilePod(
 		sidecarExporter.Name = "plugin-pgbackrest-exporter"
 		sidecarExporter.Ports = []corev1.ContainerPort{
 			{
-				ContainerPort: 9854,
+				Name:          EXPORTER_PORT_NAME,
+				ContainerPort: pc.Spec.ExporterConfig.GetPort(),
 				Protocol:      corev1.ProtocolTCP,
 			},
 		}
-		reconcilePodSpec(cluster, &mutatedPod.Spec, "postgres", &sidecarExporter, true)
+		reconcilePodSpec(cluster, &pc.Spec, &mutatedPod.Spec, "postgres", &sidecarExporter, true)
 		if pc.Spec.ExporterConfig.Resources != nil {
 			sidecarExporter.Resources = *pc.Spec.ExporterConfig.Resources
 		}
+		injectExporterWebConfig(&mutatedPod.Spec, &sidecarExporter, pc.Spec.ExporterConfig.WebConfig)
 		if err := object.InjectPluginInitContainerSidecarSpec(
 			&mutatedPod.Spec,
 			&sidecarExporter,
@@ -510,9 +788,70 @@ func (impl LifecycleImplementation) reconcilePod(
 		}
 	}
 
-	if err := impl.reconcileWALVolume(ctx, cluster, request, pluginConfig, mutatedPod); err != nil {
+	stanzas, err := impl.getStanzas(ctx, cluster, podStanzaRefs(pluginConfig))
+	if err != nil {
+		return nil, err
+	}
+	if err := impl.reconcileWALVolume(ctx, cluster, stanzas, pluginConfig, mutatedPod); err != nil {
+		return nil, err
+	}
+
+	if rebuildRequested(cluster, pod.Name) {
+		logger.Info("delta restore requested, injecting rebuild container", "pod name", pod.Name)
+		if err := injectRebuildContainer(cluster, &pc.Spec, &mutatedPod.Spec); err != nil {
+			return nil, err
+		}
+	}
+
+	pluginContainers := []string{SIDECAR_NAME, "plugin-pgbackrest-exporter", REBUILD_CONTAINER_NAME}
+	if pc.Spec.ConfigRendering == pluginv1.ConfigRenderingFile {
+		injectConfigRendering(&mutatedPod.Spec, pluginContainers...)
+	}
+	if err := injectCredentials(&mutatedPod.ObjectMeta, &mutatedPod.Spec, stanzas, pluginContainers...); err != nil {
 		return nil, err
 	}
 
 	return createPatch(logger, pod, mutatedPod)
 }
+
+// rebuildRequested checks if the instance is listed in the rebuild
+// annotation of the cluster
+func rebuildRequested(cluster *cnpgv1.Cluster, podName string) bool {
+	instances, ok := cluster.Annotations[metadata.RebuildAnnotationName]
+	if !ok {
+		return false
+	}
+	for _, name := range strings.Split(instances, ",") {
+		if strings.TrimSpace(name) == podName {
+			return true
+		}
+	}
+	return false
+}
+
+// injectRebuildContainer adds an init container running a pgBackRest delta
+// restore on the existing PGDATA. Unlike the sidecar, it is a regular init
+// container: it has to complete before PostgreSQL is started.
+func injectRebuildContainer(
+	cluster *cnpgv1.Cluster,
+	pc *pluginv1.PluginConfigSpec,
+	spec *corev1.PodSpec,
+) error {
+	rebuild := corev1.Container{Args: []string{"rebuild"}}
+	rebuild.Name = REBUILD_CONTAINER_NAME
+	reconcilePodSpec(cluster, pc, spec, "postgres", &rebuild, false)
+	rebuild.RestartPolicy = nil
+	rebuild.StartupProbe = nil
+	if err := utils.AddVolumeMountsFromContainer(&rebuild, "postgres", spec.Containers); err != nil {
+		return err
+	}
+
+	for i := range spec.InitContainers {
+		if spec.InitContainers[i].Name == rebuild.Name {
+			spec.InitContainers[i] = rebuild
+			return nil
+		}
+	}
+	spec.InitContainers = append(spec.InitContainers, rebuild)
+	return nil
+}
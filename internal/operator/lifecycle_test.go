@@ -5,12 +5,25 @@
 package operator
 
 import (
+	"context"
+	"encoding/json"
 	"reflect"
+	"slices"
+	"strings"
 	"testing"
 
 	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/cnpg-i/pkg/lifecycle"
+	machineryapi "github.com/cloudnative-pg/machinery/pkg/api"
 	pluginv1 "github.com/dalibo/cnpg-i-pgbackrest/api/v1"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/config"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/metadata"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
 func envVarSliceToMap(envVars []corev1.EnvVar) map[string]string {
@@ -170,3 +183,666 @@ func TestGetSpoolWALSize(t *testing.T) {
 		})
 	}
 }
+
+func TestRebuildRequested(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		podName     string
+		expected    bool
+	}{
+		{"no annotation", nil, "cluster-2", false},
+		{
+			"pod listed",
+			map[string]string{metadata.RebuildAnnotationName: "cluster-2"},
+			"cluster-2",
+			true,
+		},
+		{
+			"pod listed among others",
+			map[string]string{metadata.RebuildAnnotationName: "cluster-3, cluster-2"},
+			"cluster-2",
+			true,
+		},
+		{
+			"pod not listed",
+			map[string]string{metadata.RebuildAnnotationName: "cluster-3"},
+			"cluster-2",
+			false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := &cnpgv1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Annotations: tt.annotations},
+			}
+			if got := rebuildRequested(cluster, tt.podName); got != tt.expected {
+				t.Fatalf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestInjectRebuildContainer(t *testing.T) {
+	spec := &corev1.PodSpec{
+		Containers: []corev1.Container{
+			{
+				Name: "postgres",
+				Env:  []corev1.EnvVar{{Name: "PGDATA", Value: "/var/lib/postgresql/data/pgdata"}},
+				VolumeMounts: []corev1.VolumeMount{
+					{Name: "pgdata", MountPath: "/var/lib/postgresql/data"},
+				},
+			},
+		},
+	}
+	cluster := &cnpgv1.Cluster{}
+
+	// injecting twice must not duplicate the container
+	for range 2 {
+		if err := injectRebuildContainer(cluster, &pluginv1.PluginConfigSpec{}, spec); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if len(spec.InitContainers) != 1 {
+		t.Fatalf("expected one init container, got %d", len(spec.InitContainers))
+	}
+	rebuild := spec.InitContainers[0]
+	if rebuild.RestartPolicy != nil || rebuild.StartupProbe != nil {
+		t.Errorf("rebuild container must be a regular init container")
+	}
+	if !reflect.DeepEqual(rebuild.Args, []string{"rebuild"}) {
+		t.Errorf("unexpected args %v", rebuild.Args)
+	}
+	if envVarSliceToMap(rebuild.Env)["PGDATA"] == "" {
+		t.Errorf("expected PGDATA to be copied from the postgres container")
+	}
+	found := false
+	for _, vm := range rebuild.VolumeMounts {
+		if vm.Name == "pgdata" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected pgdata volume mount, got %v", rebuild.VolumeMounts)
+	}
+}
+
+func TestReconcilePodSpec_PluginConfig(t *testing.T) {
+	t.Setenv("SIDECAR_IMAGE", "sidecar:operator")
+	t.Setenv("SIDECAR_EXPORTER_IMAGE", "exporter:operator")
+	cluster := &cnpgv1.Cluster{Spec: cnpgv1.ClusterSpec{ImagePullPolicy: corev1.PullIfNotPresent}}
+	newSpec := func() *corev1.PodSpec {
+		return &corev1.PodSpec{
+			Containers: []corev1.Container{
+				{Name: "postgres", Env: []corev1.EnvVar{{Name: "PGDATA", Value: "/pgdata"}}},
+			},
+		}
+	}
+
+	var sidecar corev1.Container
+	reconcilePodSpec(cluster, &pluginv1.PluginConfigSpec{}, newSpec(), "postgres", &sidecar, false)
+	if sidecar.Image != "sidecar:operator" || sidecar.ImagePullPolicy != corev1.PullIfNotPresent ||
+		!reflect.DeepEqual(sidecar.SecurityContext, sidecarSecurityContext()) {
+		t.Errorf("unexpected defaults %+v", sidecar)
+	}
+
+	pc := &pluginv1.PluginConfigSpec{
+		Image:            "sidecar:canary",
+		ImagePullPolicy:  corev1.PullAlways,
+		ImagePullSecrets: []corev1.LocalObjectReference{{Name: "registry"}},
+		Env: []corev1.EnvVar{
+			{Name: "PGDATA", Value: "/other"},
+			{Name: "PGBACKREST_LOG_LEVEL_STDERR", Value: "debug"},
+		},
+		EnvFrom: []corev1.EnvFromSource{
+			{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "env"}}},
+		},
+		AdditionalVolumeMounts: []corev1.VolumeMount{{Name: "projected", MountPath: "/projected"}},
+		SecurityContext:        &corev1.SecurityContext{RunAsUser: ptr.To(int64(26))},
+		ExporterConfig:         &pluginv1.ExporterConfig{Image: "exporter:canary"},
+	}
+	spec := newSpec()
+	sidecar = corev1.Container{}
+	// reconciling several containers must not duplicate the pull secrets
+	for range 2 {
+		reconcilePodSpec(cluster, pc, spec, "postgres", &sidecar, false)
+	}
+	if sidecar.Image != "sidecar:canary" || sidecar.ImagePullPolicy != corev1.PullAlways {
+		t.Errorf("unexpected image %s (%s)", sidecar.Image, sidecar.ImagePullPolicy)
+	}
+	if !reflect.DeepEqual(spec.ImagePullSecrets, pc.ImagePullSecrets) {
+		t.Errorf("unexpected pull secrets %v", spec.ImagePullSecrets)
+	}
+	env := envVarSliceToMap(sidecar.Env)
+	if len(sidecar.Env) != 2 || env["PGDATA"] != "/other" || env["PGBACKREST_LOG_LEVEL_STDERR"] != "debug" {
+		t.Errorf("unexpected env %v", sidecar.Env)
+	}
+	if !reflect.DeepEqual(sidecar.EnvFrom, pc.EnvFrom) {
+		t.Errorf("unexpected envFrom %v", sidecar.EnvFrom)
+	}
+	if !slices.Contains(sidecar.VolumeMounts, pc.AdditionalVolumeMounts[0]) {
+		t.Errorf("expected the additional volume mount, got %v", sidecar.VolumeMounts)
+	}
+	if !reflect.DeepEqual(sidecar.SecurityContext, pc.SecurityContext) {
+		t.Errorf("unexpected security context %+v", sidecar.SecurityContext)
+	}
+
+	var exporter corev1.Container
+	reconcilePodSpec(cluster, pc, newSpec(), "postgres", &exporter, true)
+	if exporter.Image != "exporter:canary" {
+		t.Errorf("unexpected exporter image %s", exporter.Image)
+	}
+}
+
+func TestInjectExporterWebConfig(t *testing.T) {
+	spec := &corev1.PodSpec{}
+	var exporter corev1.Container
+	injectExporterWebConfig(spec, &exporter, nil)
+	if len(spec.Volumes) != 0 || len(exporter.VolumeMounts) != 0 {
+		t.Fatalf("nothing must be mounted without web config, got %v %v", spec.Volumes, exporter.VolumeMounts)
+	}
+
+	ref := &machineryapi.SecretKeySelector{
+		LocalObjectReference: machineryapi.LocalObjectReference{Name: "exporter-web"},
+		Key:                  "web-config.yml",
+	}
+	for range 2 {
+		injectExporterWebConfig(spec, &exporter, ref)
+	}
+	if len(spec.Volumes) != 1 || spec.Volumes[0].Secret == nil || spec.Volumes[0].Secret.SecretName != "exporter-web" {
+		t.Errorf("unexpected volumes %+v", spec.Volumes)
+	}
+	if len(exporter.VolumeMounts) != 1 || exporter.VolumeMounts[0].MountPath != pluginv1.ExporterWebConfigPath ||
+		!exporter.VolumeMounts[0].ReadOnly {
+		t.Errorf("unexpected volume mounts %+v", exporter.VolumeMounts)
+	}
+}
+
+func TestNeedsSpool(t *testing.T) {
+	tests := []struct {
+		name     string
+		conf     pluginv1.StanzaConfiguration
+		expected bool
+	}{
+		{"sync archiving", pluginv1.StanzaConfiguration{}, false},
+		{
+			"async archiving",
+			pluginv1.StanzaConfiguration{Archive: pluginv1.ArchiveOption{Async: true}},
+			true,
+		},
+		{
+			"async archiving with a single process",
+			pluginv1.StanzaConfiguration{ProcessMax: 1, Archive: pluginv1.ArchiveOption{Async: true}},
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := needsSpool(&tt.conf); got != tt.expected {
+				t.Fatalf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestReconcileJobSpoolVolume(t *testing.T) {
+	cluster := &cnpgv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "restored", Namespace: "default"},
+		Spec: cnpgv1.ClusterSpec{
+			Bootstrap: &cnpgv1.BootstrapConfiguration{
+				Recovery: &cnpgv1.BootstrapRecovery{Source: "origin"},
+			},
+			ExternalClusters: []cnpgv1.ExternalCluster{
+				{
+					Name: "origin",
+					PluginConfiguration: &cnpgv1.PluginConfiguration{
+						Name:       metadata.PluginName,
+						Parameters: map[string]string{"stanzaRef": "origin-stanza"},
+					},
+				},
+			},
+		},
+	}
+	pluginConfig, err := config.NewFromCluster(cluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, async := range []bool{false, true} {
+		stanza := &pluginv1.Stanza{
+			ObjectMeta: metav1.ObjectMeta{Name: "origin-stanza", Namespace: "default"},
+			Spec: pluginv1.StanzaSpec{
+				Configuration: pluginv1.StanzaConfiguration{
+					Name:    "origin",
+					Archive: pluginv1.ArchiveOption{Async: async},
+				},
+			},
+		}
+		impl := LifecycleImplementation{
+			Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(stanza).Build(),
+		}
+		spec := &corev1.PodSpec{
+			InitContainers: []corev1.Container{{Name: "pgbackrest-sidecar"}},
+		}
+		pc := &pluginv1.PluginConfigSpec{StorageConfig: &pluginv1.StorageConfig{Size: "2Gi"}}
+		if err := impl.reconcileJobSpoolVolume(context.Background(), cluster, pluginConfig, pc, spec); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		mounts := spec.InitContainers[0].VolumeMounts
+		if !async {
+			if len(spec.Volumes) != 0 || len(mounts) != 0 {
+				t.Errorf("no spool volume expected without async archiving")
+			}
+			continue
+		}
+		if len(spec.Volumes) != 1 || spec.Volumes[0].EmptyDir == nil {
+			t.Fatalf("expected an emptyDir spool volume, got %v", spec.Volumes)
+		}
+		if spec.Volumes[0].EmptyDir.SizeLimit.String() != "2Gi" {
+			t.Errorf("unexpected spool size limit %v", spec.Volumes[0].EmptyDir.SizeLimit)
+		}
+		if len(mounts) != 1 || mounts[0].MountPath != SPOOL_PATH {
+			t.Errorf("expected spool volume mounted on %s, got %v", SPOOL_PATH, mounts)
+		}
+	}
+}
+
+func TestInjectWALVolume_VolumeType(t *testing.T) {
+	testCases := []struct {
+		volumeType pluginv1.SpoolVolumeType
+		check      func(corev1.VolumeSource) bool
+		pvc        bool
+	}{
+		{"", func(v corev1.VolumeSource) bool {
+			return v.PersistentVolumeClaim != nil && v.PersistentVolumeClaim.ClaimName == "cluster-1-pgbackrest-spool"
+		}, true},
+		{pluginv1.SpoolVolumeEmptyDir, func(v corev1.VolumeSource) bool {
+			return v.EmptyDir != nil && v.EmptyDir.SizeLimit.String() == "2Gi"
+		}, false},
+		{pluginv1.SpoolVolumeEphemeral, func(v corev1.VolumeSource) bool {
+			if v.Ephemeral == nil {
+				return false
+			}
+			spec := v.Ephemeral.VolumeClaimTemplate.Spec
+			size := spec.Resources.Requests[corev1.ResourceStorage]
+			return *spec.StorageClassName == "fast" && size.String() == "2Gi"
+		}, false},
+	}
+	for _, tc := range testCases {
+		t.Run(string(tc.volumeType), func(t *testing.T) {
+			cluster := &cnpgv1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "default", UID: "cluster-uid"},
+				Spec: cnpgv1.ClusterSpec{
+					Plugins: []cnpgv1.PluginConfiguration{{
+						Name:       metadata.PluginName,
+						Parameters: map[string]string{"pluginConfigRef": "conf"},
+					}},
+				},
+			}
+			pc := &pluginv1.PluginConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: "conf", Namespace: "default"},
+				Spec: pluginv1.PluginConfigSpec{StorageConfig: &pluginv1.StorageConfig{
+					StorageClass: "fast",
+					Size:         "2Gi",
+					VolumeType:   tc.volumeType,
+				}},
+			}
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(pc).Build()
+			pluginConfig, err := config.NewFromCluster(cluster)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "cluster-1", Namespace: "default"},
+				Spec: corev1.PodSpec{
+					InitContainers: []corev1.Container{{Name: SIDECAR_NAME}},
+				},
+			}
+			impl := LifecycleImplementation{Client: c}
+			if err := impl.injectWALVolume(context.Background(), pluginConfig, pod, cluster); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(pod.Spec.Volumes) != 1 || !tc.check(pod.Spec.Volumes[0].VolumeSource) {
+				t.Errorf("unexpected spool volume %+v", pod.Spec.Volumes)
+			}
+			var pvcs corev1.PersistentVolumeClaimList
+			if err := c.List(context.Background(), &pvcs); err != nil {
+				t.Fatal(err)
+			}
+			if (len(pvcs.Items) == 1) != tc.pvc {
+				t.Errorf("want a claim %v, got %d", tc.pvc, len(pvcs.Items))
+			}
+		})
+	}
+}
+
+func TestRestoreResources(t *testing.T) {
+	sidecar := &corev1.ResourceRequirements{
+		Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")},
+	}
+	restore := &corev1.ResourceRequirements{
+		Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("8Gi")},
+	}
+	testCases := []struct {
+		desc string
+		pc   pluginv1.PluginConfigSpec
+		want *corev1.ResourceRequirements
+	}{
+		{"none", pluginv1.PluginConfigSpec{}, nil},
+		{"sidecar resources", pluginv1.PluginConfigSpec{Resources: sidecar}, sidecar},
+		{
+			"restore resources",
+			pluginv1.PluginConfigSpec{
+				Resources:     sidecar,
+				RestoreConfig: &pluginv1.RestoreConfig{Resources: restore},
+			},
+			restore,
+		},
+		{
+			"restore without resources",
+			pluginv1.PluginConfigSpec{Resources: sidecar, RestoreConfig: &pluginv1.RestoreConfig{}},
+			sidecar,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := restoreResources(&tc.pc); got != tc.want {
+				t.Errorf("want %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestReconcileJob_PluginConfig(t *testing.T) {
+	cluster := &cnpgv1.Cluster{
+		TypeMeta:   metav1.TypeMeta{APIVersion: cnpgv1.SchemeGroupVersion.String(), Kind: "Cluster"},
+		ObjectMeta: metav1.ObjectMeta{Name: "restored", Namespace: "default"},
+		Spec: cnpgv1.ClusterSpec{
+			Bootstrap: &cnpgv1.BootstrapConfiguration{
+				Recovery: &cnpgv1.BootstrapRecovery{Source: "origin"},
+			},
+			ExternalClusters: []cnpgv1.ExternalCluster{
+				{
+					Name: "origin",
+					PluginConfiguration: &cnpgv1.PluginConfiguration{
+						Name: metadata.PluginName,
+						Parameters: map[string]string{
+							"stanzaRef":       "origin-stanza",
+							"pluginConfigRef": "restore-config",
+						},
+					},
+				},
+			},
+		},
+	}
+	stanza := &pluginv1.Stanza{
+		ObjectMeta: metav1.ObjectMeta{Name: "origin-stanza", Namespace: "default"},
+	}
+	pc := &pluginv1.PluginConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "restore-config", Namespace: "default"},
+		Spec: pluginv1.PluginConfigSpec{
+			Image: "sidecar:canary",
+			RestoreConfig: &pluginv1.RestoreConfig{
+				Resources: &corev1.ResourceRequirements{
+					Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("8Gi")},
+				},
+			},
+		},
+	}
+	job := &batchv1.Job{
+		TypeMeta:   metav1.TypeMeta{APIVersion: batchv1.SchemeGroupVersion.String(), Kind: "Job"},
+		ObjectMeta: metav1.ObjectMeta{Name: "restored-1-full-recovery", Namespace: "default"},
+		Spec: batchv1.JobSpec{
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"cnpg.io/jobRole": "full-recovery"}},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "full-recovery"}},
+				},
+			},
+		},
+	}
+	clusterJSON, err := json.Marshal(cluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobJSON, err := json.Marshal(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pluginConfig, err := config.NewFromCluster(cluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	impl := LifecycleImplementation{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(stanza, pc).Build(),
+	}
+	response, err := impl.reconcileJob(context.Background(), cluster, &lifecycle.OperatorLifecycleRequest{
+		ObjectDefinition:  jobJSON,
+		ClusterDefinition: clusterJSON,
+	}, pluginConfig)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	patch := string(response.GetJsonPatch())
+	for _, want := range []string{`"image":"sidecar:canary"`, `"memory":"8Gi"`} {
+		if !strings.Contains(patch, want) {
+			t.Errorf("expected %s in the patch %s", want, patch)
+		}
+	}
+}
+
+func TestInjectConfigRendering(t *testing.T) {
+	spec := &corev1.PodSpec{
+		InitContainers: []corev1.Container{
+			{Name: SIDECAR_NAME},
+			{Name: "other"},
+		},
+	}
+	// injecting twice must not duplicate anything
+	for range 2 {
+		injectConfigRendering(spec, SIDECAR_NAME, REBUILD_CONTAINER_NAME)
+	}
+
+	if len(spec.Volumes) != 1 || spec.Volumes[0].EmptyDir == nil ||
+		spec.Volumes[0].EmptyDir.Medium != corev1.StorageMediumMemory {
+		t.Fatalf("expected a memory-backed volume, got %v", spec.Volumes)
+	}
+	sidecar := spec.InitContainers[0]
+	if len(sidecar.VolumeMounts) != 1 || sidecar.VolumeMounts[0].MountPath != CONFIG_RENDER_PATH {
+		t.Errorf("unexpected volume mounts %v", sidecar.VolumeMounts)
+	}
+	if len(sidecar.Env) != 1 || envVarSliceToMap(sidecar.Env)[config.ConfigDirectoryEnv] != CONFIG_RENDER_PATH+"/"+SIDECAR_NAME {
+		t.Errorf("unexpected env %v", sidecar.Env)
+	}
+	if other := spec.InitContainers[1]; len(other.VolumeMounts) != 0 || len(other.Env) != 0 {
+		t.Errorf("other containers must not be modified")
+	}
+}
+
+func TestInjectS3WebIdentity(t *testing.T) {
+	stanzas := []*pluginv1.Stanza{
+		{Spec: pluginv1.StanzaSpec{Configuration: pluginv1.StanzaConfiguration{
+			S3Repositories: []pluginv1.S3Repository{{Bucket: "shared"}},
+		}}},
+		{Spec: pluginv1.StanzaSpec{Configuration: pluginv1.StanzaConfiguration{
+			S3Repositories: []pluginv1.S3Repository{{
+				Bucket:      "web-id",
+				KeyType:     pluginv1.S3KeyTypeWebID,
+				WebIdentity: &pluginv1.S3WebIdentity{RoleARN: "arn:aws:iam::123456789012:role/pgbackrest"},
+			}},
+		}}},
+	}
+	if identity, err := s3WebIdentity(stanzas[:1]); err != nil || identity != nil {
+		t.Fatalf("no web identity expected with shared keys, got %v, %v", identity, err)
+	}
+	identity, err := s3WebIdentity(stanzas)
+	if err != nil || identity == nil {
+		t.Fatalf("expected a web identity, got %v", err)
+	}
+
+	spec := &corev1.PodSpec{
+		InitContainers: []corev1.Container{
+			{Name: SIDECAR_NAME},
+			{Name: "other"},
+		},
+	}
+	for range 2 {
+		injectS3WebIdentity(spec, identity, SIDECAR_NAME)
+	}
+
+	if len(spec.Volumes) != 1 || spec.Volumes[0].Projected == nil {
+		t.Fatalf("expected a projected volume, got %v", spec.Volumes)
+	}
+	token := spec.Volumes[0].Projected.Sources[0].ServiceAccountToken
+	if token == nil || token.Audience != "sts.amazonaws.com" {
+		t.Errorf("unexpected token projection %v", token)
+	}
+	sidecar := spec.InitContainers[0]
+	if len(sidecar.VolumeMounts) != 1 || !sidecar.VolumeMounts[0].ReadOnly {
+		t.Errorf("unexpected volume mounts %v", sidecar.VolumeMounts)
+	}
+	expectedEnv := map[string]string{
+		"AWS_ROLE_ARN":                identity.RoleARN,
+		"AWS_WEB_IDENTITY_TOKEN_FILE": awsTokenPath + "/token",
+	}
+	if env := envVarSliceToMap(sidecar.Env); !reflect.DeepEqual(env, expectedEnv) {
+		t.Errorf("unexpected env %v", env)
+	}
+	if other := spec.InitContainers[1]; len(other.VolumeMounts) != 0 || len(other.Env) != 0 {
+		t.Errorf("other containers must not be modified")
+	}
+}
+
+func TestCheckWebIdentities(t *testing.T) {
+	webIDStanza := func(name string, roles ...string) pluginv1.Stanza {
+		stanza := pluginv1.Stanza{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		for _, role := range roles {
+			stanza.Spec.Configuration.S3Repositories = append(stanza.Spec.Configuration.S3Repositories,
+				pluginv1.S3Repository{
+					Bucket:      role,
+					KeyType:     pluginv1.S3KeyTypeWebID,
+					WebIdentity: &pluginv1.S3WebIdentity{RoleARN: "arn:aws:iam::123456789012:role/" + role},
+				})
+		}
+		return stanza
+	}
+	testCases := []struct {
+		desc    string
+		conf    config.PluginConfiguration
+		stanzas []pluginv1.Stanza
+		wantErr bool
+	}{
+		{
+			desc:    "same role",
+			conf:    config.PluginConfiguration{StanzaRef: "main", ReplicaStanzaRef: "replica"},
+			stanzas: []pluginv1.Stanza{webIDStanza("main", "a", "a"), webIDStanza("replica", "a")},
+		},
+		{
+			desc:    "different roles in a stanza",
+			conf:    config.PluginConfiguration{StanzaRef: "main"},
+			stanzas: []pluginv1.Stanza{webIDStanza("main", "a", "b")},
+			wantErr: true,
+		},
+		{
+			desc:    "different roles to archive and replicate",
+			conf:    config.PluginConfiguration{StanzaRef: "main", ReplicaStanzaRef: "replica"},
+			stanzas: []pluginv1.Stanza{webIDStanza("main", "a"), webIDStanza("replica", "b")},
+			wantErr: true,
+		},
+		{
+			desc:    "different role to recover",
+			conf:    config.PluginConfiguration{StanzaRef: "main", RecoveryStanzaRef: "origin"},
+			stanzas: []pluginv1.Stanza{webIDStanza("main", "a"), webIDStanza("origin", "b")},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := checkWebIdentities(&tc.conf, tc.stanzas)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("want error %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestInjectAzureWorkloadIdentity(t *testing.T) {
+	stanzas := []*pluginv1.Stanza{
+		{Spec: pluginv1.StanzaSpec{Configuration: pluginv1.StanzaConfiguration{
+			AzureRepositories: []pluginv1.AzureRepository{{KeyType: pluginv1.AzureKeyTypeShared}},
+		}}},
+	}
+	if azureWorkloadIdentity(stanzas) {
+		t.Fatalf("no workload identity expected with a shared key")
+	}
+	stanzas[0].Spec.Configuration.AzureRepositories[0].KeyType = pluginv1.AzureKeyTypeWorkloadIdentity
+	if !azureWorkloadIdentity(stanzas) {
+		t.Fatalf("expected a workload identity")
+	}
+
+	var meta metav1.ObjectMeta
+	injectAzureWorkloadIdentity(&meta)
+	if meta.Labels[azureWorkloadIdentityLabel] != "true" {
+		t.Errorf("unexpected labels %v", meta.Labels)
+	}
+}
+
+func TestInjectCABundles(t *testing.T) {
+	configMapRef := func(name, key string) *pluginv1.CABundle {
+		return &pluginv1.CABundle{ConfigMapKeyRef: &machineryapi.ConfigMapKeySelector{
+			LocalObjectReference: machineryapi.LocalObjectReference{Name: name},
+			Key:                  key,
+		}}
+	}
+	stanzas := []*pluginv1.Stanza{
+		{Spec: pluginv1.StanzaSpec{Configuration: pluginv1.StanzaConfiguration{
+			S3Repositories: []pluginv1.S3Repository{
+				{CABundle: configMapRef("internal-ca", "ca.crt")},
+				{},
+			},
+			AzureRepositories: []pluginv1.AzureRepository{
+				// another key of the same ConfigMap shares the volume
+				{CABundle: configMapRef("internal-ca", "azurite.crt")},
+				{CABundle: &pluginv1.CABundle{SecretKeyRef: &machineryapi.SecretKeySelector{
+					LocalObjectReference: machineryapi.LocalObjectReference{Name: "azurite-tls"},
+					Key:                  "ca.crt",
+				}}},
+			},
+		}}},
+	}
+	bundles := caBundles(stanzas)
+	if len(bundles) != 3 {
+		t.Fatalf("expected 3 bundles, got %d", len(bundles))
+	}
+
+	spec := &corev1.PodSpec{
+		InitContainers: []corev1.Container{{Name: SIDECAR_NAME}, {Name: "other"}},
+	}
+	injectCABundles(spec, bundles, SIDECAR_NAME)
+
+	if len(spec.Volumes) != 2 {
+		t.Fatalf("expected 2 volumes, got %v", spec.Volumes)
+	}
+	if spec.Volumes[0].ConfigMap == nil || spec.Volumes[0].ConfigMap.Name != "internal-ca" {
+		t.Errorf("unexpected ConfigMap volume %v", spec.Volumes[0])
+	}
+	if spec.Volumes[1].Secret == nil || spec.Volumes[1].Secret.SecretName != "azurite-tls" {
+		t.Errorf("unexpected Secret volume %v", spec.Volumes[1])
+	}
+	mounts := spec.InitContainers[0].VolumeMounts
+	if len(mounts) != 2 {
+		t.Fatalf("expected 2 volume mounts, got %v", mounts)
+	}
+	for _, b := range bundles {
+		file := config.CABundleFile(b)
+		if !slices.ContainsFunc(mounts, func(m corev1.VolumeMount) bool {
+			return m.ReadOnly && strings.HasPrefix(file, m.MountPath+"/")
+		}) {
+			t.Errorf("CA file %s is not in a mounted volume: %v", file, mounts)
+		}
+	}
+	if len(spec.InitContainers[1].VolumeMounts) != 0 {
+		t.Errorf("other containers must not be modified")
+	}
+}
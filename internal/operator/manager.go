@@ -12,11 +12,16 @@ import (
 	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
 	"github.com/cloudnative-pg/machinery/pkg/log"
 	apipgbackrest "github.com/dalibo/cnpg-i-pgbackrest/api/v1"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
@@ -33,6 +38,7 @@ func init() {
 	apipgbackrest.AddKnownTypes(scheme)
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 	utilruntime.Must(cnpgv1.AddToScheme(scheme))
+	utilruntime.Must(monitoringv1.AddToScheme(scheme))
 	// +kubebuilder:scaffold:scheme
 }
 
@@ -62,12 +68,68 @@ func Start(ctx context.Context) error {
 		LeaderElection:                true,
 		LeaderElectionID:              "822e3f5c.pgbackrest.cnpg.io",
 		LeaderElectionReleaseOnCancel: true,
+		// the Secrets of the repositories are read directly from the API
+		// server, caching them would watch every Secret of the cluster
+		Client: client.Options{
+			Cache: &client.CacheOptions{DisableFor: []client.Object{&corev1.Secret{}}},
+		},
+		// only the Pods of the instances are watched, to consume the
+		// rebuild requests
+		Cache: cache.Options{
+			ByObject: map[client.Object]cache.ByObject{
+				&corev1.Pod{}: {Label: labels.SelectorFromSet(labels.Set{"cnpg.io/podRole": "instance"})},
+			},
+		},
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		return err
 	}
 
+	if err := (&StanzaReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create the stanza controller")
+		return err
+	}
+	if err := (&ClusterRBACReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create the cluster RBAC controller")
+		return err
+	}
+	if err := (&RebuildReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create the rebuild controller")
+		return err
+	}
+	if err := (&SpoolReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create the spool controller")
+		return err
+	}
+	// the PodMonitors are only managed when the Prometheus operator is
+	// installed, the controller can't watch them otherwise
+	if available, err := podMonitorAvailable(mgr.GetRESTMapper()); err != nil {
+		setupLog.Error(err, "unable to check whether the PodMonitor CRD is installed")
+		return err
+	} else if available {
+		if err := (&PodMonitorReconciler{
+			Client: mgr.GetClient(),
+			Scheme: mgr.GetScheme(),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create the podmonitor controller")
+			return err
+		}
+	} else {
+		setupLog.Info("the PodMonitor CRD isn't installed, the PodMonitors won't be managed")
+	}
 	// +kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -0,0 +1,231 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package operator
+
+import (
+	"context"
+	"strings"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	machineryapi "github.com/cloudnative-pg/machinery/pkg/api"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	pluginv1 "github.com/dalibo/cnpg-i-pgbackrest/api/v1"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/config"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+)
+
+// podMonitorSuffix is appended to the name of the Cluster to name its
+// PodMonitor, the one of CloudNativePG is named after the Cluster
+const podMonitorSuffix = "-pgbackrest"
+
+// PodMonitorReconciler manages the PodMonitor scraping the exporter of the
+// instances of the Clusters, when enabled in their PluginConfig.
+type PodMonitorReconciler struct {
+	Client client.Client
+	Scheme *runtime.Scheme
+}
+
+// podMonitorAvailable tells whether the PodMonitor CRD is installed
+func podMonitorAvailable(mapper meta.RESTMapper) (bool, error) {
+	gvk := monitoringv1.SchemeGroupVersion.WithKind(monitoringv1.PodMonitorsKind)
+	_, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// SetupWithManager registers the reconciler, the Clusters are reconciled
+// again when their PodMonitor drifts or the PluginConfig they reference
+// changes.
+func (r *PodMonitorReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&cnpgv1.Cluster{}).
+		Owns(&monitoringv1.PodMonitor{}).
+		Watches(&pluginv1.PluginConfig{}, handler.EnqueueRequestsFromMapFunc(pluginConfigClusters(r.Client))).
+		Named("podmonitor").
+		Complete(r)
+}
+
+// Reconcile ensures the PodMonitor of a Cluster whose PluginConfig enables
+// it, and deletes it otherwise.
+func (r *PodMonitorReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	contextLogger := log.FromContext(ctx).WithValues("cluster", req.NamespacedName)
+	ctx = log.IntoContext(ctx, contextLogger)
+
+	var cluster cnpgv1.Cluster
+	if err := r.Client.Get(ctx, req.NamespacedName, &cluster); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !cluster.DeletionTimestamp.IsZero() {
+		// the PodMonitor is owned by the Cluster, and garbage collected with it
+		return ctrl.Result{}, nil
+	}
+	// the type metadata isn't set by the client, it's required for the owner
+	// references
+	cluster.SetGroupVersionKind(cnpgv1.SchemeGroupVersion.WithKind(cnpgv1.ClusterKind))
+
+	conf, err := config.NewFromCluster(&cluster)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	var pc pluginv1.PluginConfig
+	if ref, _ := conf.GetSharedPluginConfig(); ref != nil && usesPlugin(conf) {
+		if err := r.Client.Get(ctx, *ref, &pc); client.IgnoreNotFound(err) != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	ec := pc.Spec.ExporterConfig
+	if ec == nil || ec.PodMonitor == nil || !ec.PodMonitor.Enabled {
+		return ctrl.Result{}, r.deletePodMonitor(ctx, &cluster)
+	}
+	return ctrl.Result{}, r.ensurePodMonitor(ctx, &cluster, ec)
+}
+
+// buildPodMonitor builds the PodMonitor scraping the exporter of the
+// instances of the Cluster
+func buildPodMonitor(cluster *cnpgv1.Cluster, ec *pluginv1.ExporterConfig) *monitoringv1.PodMonitor {
+	endpoint := monitoringv1.PodMetricsEndpoint{
+		Port: ptr.To(EXPORTER_PORT_NAME),
+		Path: "/metrics",
+	}
+	if ec.PodMonitor.PrimaryOnly {
+		endpoint.RelabelConfigs = []monitoringv1.RelabelConfig{{
+			SourceLabels: []monitoringv1.LabelName{"__meta_kubernetes_pod_label_cnpg_io_instanceRole"},
+			Regex:        "primary",
+			Action:       "keep",
+		}}
+	}
+	if ec.WebConfig != nil {
+		// the web configuration of the exporter sets TLS and basic
+		// authentication, the scrapes must match it
+		applyWebConfigScrapes(&endpoint, ec.PodMonitor)
+	}
+	return &monitoringv1.PodMonitor{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: cluster.Namespace,
+			Name:      cluster.Name + podMonitorSuffix,
+			Labels:    map[string]string{"cnpg.io/cluster": cluster.Name},
+		},
+		Spec: monitoringv1.PodMonitorSpec{
+			Selector: metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"cnpg.io/cluster": cluster.Name,
+					"cnpg.io/podRole": "instance",
+				},
+			},
+			PodMetricsEndpoints: []monitoringv1.PodMetricsEndpoint{endpoint},
+		},
+	}
+}
+
+// ensurePodMonitor creates the PodMonitor of the Cluster, or updates its
+// spec when it drifted. A PodMonitor of the same name not created for the
+// Cluster is left untouched.
+func (r *PodMonitorReconciler) ensurePodMonitor(
+	ctx context.Context,
+	cluster *cnpgv1.Cluster,
+	ec *pluginv1.ExporterConfig,
+) error {
+	contextLogger := log.FromContext(ctx)
+	desired := buildPodMonitor(cluster, ec)
+	if err := setOwnerReference(cluster, desired); err != nil {
+		return err
+	}
+
+	var current monitoringv1.PodMonitor
+	err := r.Client.Get(ctx, client.ObjectKeyFromObject(desired), &current)
+	switch {
+	case apierrs.IsNotFound(err):
+		contextLogger.Info("Creating the PodMonitor of the exporter", "name", desired.Name)
+		return client.IgnoreAlreadyExists(r.Client.Create(ctx, desired))
+	case err != nil:
+		return err
+	}
+	if !isControlledBy(&current, cluster) {
+		contextLogger.Warning("a PodMonitor not created for the cluster already exists, leaving it",
+			"name", current.Name)
+		return nil
+	}
+	if equality.Semantic.DeepEqual(current.Spec, desired.Spec) {
+		return nil
+	}
+	contextLogger.Info("Updating the PodMonitor of the exporter", "name", current.Name)
+	oldPodMonitor := current.DeepCopy()
+	current.Spec = desired.Spec
+	return r.Client.Patch(ctx, &current, client.MergeFrom(oldPodMonitor))
+}
+
+// deletePodMonitor deletes the PodMonitor created for the Cluster, once the
+// PluginConfig doesn't enable it anymore
+func (r *PodMonitorReconciler) deletePodMonitor(ctx context.Context, cluster *cnpgv1.Cluster) error {
+	var current monitoringv1.PodMonitor
+	key := client.ObjectKey{Namespace: cluster.Namespace, Name: cluster.Name + podMonitorSuffix}
+	if err := r.Client.Get(ctx, key, &current); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !isControlledBy(&current, cluster) {
+		return nil
+	}
+	log.FromContext(ctx).Info("Deleting the PodMonitor of the exporter", "name", current.Name)
+	return client.IgnoreNotFound(r.Client.Delete(ctx, &current))
+}
+
+// applyWebConfigScrapes sets the scheme, the TLS settings and the basic
+// authentication of the scrapes of the exporter
+func applyWebConfigScrapes(endpoint *monitoringv1.PodMetricsEndpoint, pm *pluginv1.ExporterPodMonitor) {
+	if pm.Scheme != "" {
+		endpoint.Scheme = ptr.To(monitoringv1.Scheme(strings.ToUpper(pm.Scheme)))
+	}
+	if tls := pm.TLSConfig; tls != nil {
+		endpoint.TLSConfig = &monitoringv1.SafeTLSConfig{
+			Cert:      monitoringv1.SecretOrConfigMap{Secret: secretKeySelector(tls.CertSecretRef)},
+			KeySecret: secretKeySelector(tls.KeySecretRef),
+		}
+		if tls.InsecureSkipVerify {
+			endpoint.TLSConfig.InsecureSkipVerify = ptr.To(true)
+		}
+		if tls.ServerName != "" {
+			endpoint.TLSConfig.ServerName = ptr.To(tls.ServerName)
+		}
+		if tls.CA != nil {
+			endpoint.TLSConfig.CA = monitoringv1.SecretOrConfigMap{Secret: secretKeySelector(tls.CA.SecretKeyRef)}
+			if ref := tls.CA.ConfigMapKeyRef; ref != nil {
+				endpoint.TLSConfig.CA.ConfigMap = &corev1.ConfigMapKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: ref.Name},
+					Key:                  ref.Key,
+				}
+			}
+		}
+	}
+	if auth := pm.BasicAuth; auth != nil {
+		endpoint.BasicAuth = &monitoringv1.BasicAuth{
+			Username: *secretKeySelector(&auth.Username),
+			Password: *secretKeySelector(&auth.Password),
+		}
+	}
+}
+
+// secretKeySelector converts a reference to the key of a Secret
+func secretKeySelector(ref *machineryapi.SecretKeySelector) *corev1.SecretKeySelector {
+	if ref == nil {
+		return nil
+	}
+	return &corev1.SecretKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{Name: ref.Name},
+		Key:                  ref.Key,
+	}
+}
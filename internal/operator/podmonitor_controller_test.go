@@ -0,0 +1,163 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+package operator
+
+import (
+	"testing"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	machineryapi "github.com/cloudnative-pg/machinery/pkg/api"
+	pluginv1 "github.com/dalibo/cnpg-i-pgbackrest/api/v1"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestPodMonitorReconciler(t *testing.T) {
+	cluster := testCluster("cluster", map[string]string{"stanzaRef": "stanza", "pluginConfigRef": "conf"})
+	other := testCluster("other", nil)
+	existing := func(owner *cnpgv1.Cluster, primaryOnly bool) *monitoringv1.PodMonitor {
+		pm := buildPodMonitor(cluster, &pluginv1.ExporterConfig{
+			PodMonitor: &pluginv1.ExporterPodMonitor{Enabled: true, PrimaryOnly: primaryOnly},
+		})
+		if err := setOwnerReference(owner, pm); err != nil {
+			t.Fatal(err)
+		}
+		return pm
+	}
+	testCases := []struct {
+		desc        string
+		podMonitor  *pluginv1.ExporterPodMonitor
+		existing    *monitoringv1.PodMonitor
+		wantExists  bool
+		wantOwner   *cnpgv1.Cluster
+		wantRelabel int
+	}{
+		{
+			desc:        "created for the primary",
+			podMonitor:  &pluginv1.ExporterPodMonitor{Enabled: true, PrimaryOnly: true},
+			wantExists:  true,
+			wantOwner:   cluster,
+			wantRelabel: 1,
+		},
+		{
+			desc:       "created for all the instances",
+			podMonitor: &pluginv1.ExporterPodMonitor{Enabled: true},
+			wantExists: true,
+			wantOwner:  cluster,
+		},
+		{
+			desc:       "updated when drifted",
+			podMonitor: &pluginv1.ExporterPodMonitor{Enabled: true},
+			existing:   existing(cluster, true),
+			wantExists: true,
+			wantOwner:  cluster,
+		},
+		{
+			desc:     "deleted when disabled",
+			existing: existing(cluster, false),
+		},
+		{
+			desc:        "not created for the cluster, enabled",
+			podMonitor:  &pluginv1.ExporterPodMonitor{Enabled: true},
+			existing:    existing(other, true),
+			wantExists:  true,
+			wantOwner:   other,
+			wantRelabel: 1,
+		},
+		{
+			desc:        "not created for the cluster, disabled",
+			existing:    existing(other, true),
+			wantExists:  true,
+			wantOwner:   other,
+			wantRelabel: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			pc := &pluginv1.PluginConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: "conf", Namespace: "default"},
+				Spec: pluginv1.PluginConfigSpec{
+					ExporterConfig: &pluginv1.ExporterConfig{PodMonitor: tc.podMonitor},
+				},
+			}
+			objs := []client.Object{cluster.DeepCopy(), pc}
+			if tc.existing != nil {
+				objs = append(objs, tc.existing.DeepCopy())
+			}
+			c := newFakeClient(objs...)
+			runReconcile(t, &PodMonitorReconciler{Client: c, Scheme: scheme}, cluster)
+
+			pm := &monitoringv1.PodMonitor{
+				ObjectMeta: metav1.ObjectMeta{Name: "cluster-pgbackrest", Namespace: "default"},
+			}
+			if exists := getObject(t, c, pm); exists != tc.wantExists {
+				t.Fatalf("want exists %v, got %v", tc.wantExists, exists)
+			}
+			if !tc.wantExists {
+				return
+			}
+			if !isControlledBy(pm, tc.wantOwner) {
+				t.Errorf("unexpected owners %+v", pm.OwnerReferences)
+			}
+			if sel := pm.Spec.Selector.MatchLabels; sel["cnpg.io/cluster"] != "cluster" || sel["cnpg.io/podRole"] != "instance" {
+				t.Errorf("unexpected selector %v", sel)
+			}
+			endpoints := pm.Spec.PodMetricsEndpoints
+			if len(endpoints) != 1 || *endpoints[0].Port != EXPORTER_PORT_NAME {
+				t.Fatalf("unexpected endpoints %+v", endpoints)
+			}
+			if relabel := endpoints[0].RelabelConfigs; len(relabel) != tc.wantRelabel {
+				t.Errorf("want %d relabelings, got %+v", tc.wantRelabel, relabel)
+			}
+		})
+	}
+}
+
+func TestBuildPodMonitor_WebConfig(t *testing.T) {
+	cluster := testCluster("cluster", nil)
+	secretRef := func(name, key string) *machineryapi.SecretKeySelector {
+		return &machineryapi.SecretKeySelector{LocalObjectReference: machineryapi.LocalObjectReference{Name: name}, Key: key}
+	}
+	podMonitor := &pluginv1.ExporterPodMonitor{
+		Enabled: true,
+		Scheme:  "https",
+		TLSConfig: &pluginv1.ExporterPodMonitorTLSConfig{
+			CA:            &pluginv1.CABundle{SecretKeyRef: secretRef("tls", "ca.crt")},
+			CertSecretRef: secretRef("tls", "tls.crt"),
+			KeySecretRef:  secretRef("tls", "tls.key"),
+			ServerName:    "exporter",
+		},
+		BasicAuth: &pluginv1.ExporterPodMonitorBasicAuth{
+			Username: *secretRef("auth", "username"),
+			Password: *secretRef("auth", "password"),
+		},
+	}
+
+	t.Run("ignored without web configuration", func(t *testing.T) {
+		endpoint := buildPodMonitor(cluster, &pluginv1.ExporterConfig{PodMonitor: podMonitor}).Spec.PodMetricsEndpoints[0]
+		if endpoint.Scheme != nil || endpoint.TLSConfig != nil || endpoint.BasicAuth != nil {
+			t.Errorf("unexpected endpoint %+v", endpoint)
+		}
+	})
+
+	t.Run("applied with web configuration", func(t *testing.T) {
+		endpoint := buildPodMonitor(cluster, &pluginv1.ExporterConfig{
+			WebConfig:  secretRef("web", "web.yml"),
+			PodMonitor: podMonitor,
+		}).Spec.PodMetricsEndpoints[0]
+		if endpoint.Scheme == nil || *endpoint.Scheme != monitoringv1.SchemeHTTPS {
+			t.Errorf("want the HTTPS scheme, got %v", endpoint.Scheme)
+		}
+		tls := endpoint.TLSConfig
+		if tls == nil || tls.CA.Secret.Key != "ca.crt" || tls.Cert.Secret.Key != "tls.crt" ||
+			tls.KeySecret.Key != "tls.key" || *tls.ServerName != "exporter" || tls.InsecureSkipVerify != nil {
+			t.Errorf("unexpected TLS settings %+v", tls)
+		}
+		auth := endpoint.BasicAuth
+		if auth == nil || auth.Username.Name != "auth" || auth.Username.Key != "username" || auth.Password.Key != "password" {
+			t.Errorf("unexpected basic authentication %+v", auth)
+		}
+	})
+}
@@ -0,0 +1,212 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package operator
+
+import (
+	"context"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	apipgbackrest "github.com/dalibo/cnpg-i-pgbackrest/api/v1"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/config"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// usesPlugin tells whether the Cluster references a stanza, its instances
+// then need the Role giving access to it
+func usesPlugin(conf *config.PluginConfiguration) bool {
+	return len(conf.GetReferredPgBackrestObjectKey()) > 0
+}
+
+// referencedObjects returns the stanzas and the shared plugin configuration
+// referenced by the Cluster. The stanzas not found are reported, the Role
+// can't grant access to them yet.
+func referencedObjects(
+	ctx context.Context,
+	c client.Client,
+	conf *config.PluginConfiguration,
+) ([]apipgbackrest.Stanza, *apipgbackrest.PluginConfig, bool, error) {
+	contextLogger := log.FromContext(ctx)
+	refPgBackrestObj := conf.GetReferredPgBackrestObjectKey()
+	stanzas := make([]apipgbackrest.Stanza, 0, len(refPgBackrestObj))
+	missing := false
+	for _, rpb := range refPgBackrestObj {
+		var stanza apipgbackrest.Stanza
+		contextLogger.Debug("parsing cluster definition", "pgbackrestObjectKey", rpb)
+		if err := c.Get(ctx, rpb, &stanza); err != nil {
+			if apierrs.IsNotFound(err) {
+				contextLogger.Debug("stanza not found", "error", err)
+				missing = true
+				continue
+			}
+			return nil, nil, false, err
+		}
+		stanzas = append(stanzas, stanza)
+	}
+
+	refPluginConf, _ := conf.GetSharedPluginConfig()
+	var sharedPluginConf *apipgbackrest.PluginConfig
+	if refPluginConf != nil {
+		sharedPluginConf = &apipgbackrest.PluginConfig{}
+		if err := c.Get(ctx, *refPluginConf, sharedPluginConf); err != nil {
+			if !apierrs.IsNotFound(err) {
+				return nil, nil, false, err
+			}
+			sharedPluginConf = nil
+		}
+	}
+	return stanzas, sharedPluginConf, missing, nil
+}
+
+func ensureRole(
+	ctx context.Context,
+	c client.Client,
+	cluster *cnpgv1.Cluster,
+	stanza []apipgbackrest.Stanza,
+	pluginconfig *apipgbackrest.PluginConfig,
+) error {
+	contextLogger := log.FromContext(ctx)
+	newRole := BuildK8SRole(cluster.Namespace, cluster.Name, stanza, pluginconfig)
+
+	var role rbacv1.Role
+	if err := c.Get(ctx, client.ObjectKey{
+		Namespace: newRole.Namespace,
+		Name:      newRole.Name,
+	}, &role); err != nil {
+		if !apierrs.IsNotFound(err) {
+			return err
+		}
+
+		contextLogger.Info(
+			"Creating role",
+			"name", newRole.Name,
+			"namespace", newRole.Namespace,
+		)
+
+		if err := setOwnerReference(cluster, newRole); err != nil {
+			return err
+		}
+
+		return c.Create(ctx, newRole)
+	}
+
+	if equality.Semantic.DeepEqual(newRole.Rules, role.Rules) {
+		// There's no need to hit the API server again
+		return nil
+	}
+
+	contextLogger.Info(
+		"Patching role",
+		"name", newRole.Name,
+		"namespace", newRole.Namespace,
+		"rules", newRole.Rules,
+	)
+
+	oldRole := role.DeepCopy()
+
+	// Apply to the role the new rules
+	role.Rules = newRole.Rules
+
+	// Push it back to the API server
+	return c.Patch(ctx, &role, client.MergeFrom(oldRole))
+}
+
+// ensureRoleBinding creates the RoleBinding of the Cluster, or restores it
+// when it drifted. The role of a binding can't be changed, the binding is
+// then recreated.
+func ensureRoleBinding(
+	ctx context.Context,
+	c client.Client,
+	cluster *cnpgv1.Cluster,
+) error {
+	contextLogger := log.FromContext(ctx)
+	newBinding := BindingK8SRole(cluster.Namespace, cluster.Name)
+
+	var binding rbacv1.RoleBinding
+	if err := c.Get(ctx, client.ObjectKeyFromObject(newBinding), &binding); err != nil {
+		if apierrs.IsNotFound(err) {
+			return createRoleBinding(ctx, c, cluster, newBinding)
+		}
+		return err
+	}
+
+	if binding.RoleRef != newBinding.RoleRef {
+		contextLogger.Info(
+			"Recreating role binding with a drifted role",
+			"name", binding.Name,
+			"namespace", binding.Namespace,
+			"roleRef", binding.RoleRef,
+		)
+		if err := c.Delete(ctx, &binding); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		return createRoleBinding(ctx, c, cluster, newBinding)
+	}
+
+	if equality.Semantic.DeepEqual(newBinding.Subjects, binding.Subjects) {
+		return nil
+	}
+
+	contextLogger.Info(
+		"Patching role binding",
+		"name", binding.Name,
+		"namespace", binding.Namespace,
+		"subjects", newBinding.Subjects,
+	)
+	oldBinding := binding.DeepCopy()
+	binding.Subjects = newBinding.Subjects
+	return c.Patch(ctx, &binding, client.MergeFrom(oldBinding))
+}
+
+func createRoleBinding(
+	ctx context.Context,
+	c client.Client,
+	cluster *cnpgv1.Cluster,
+	roleBinding *rbacv1.RoleBinding,
+) error {
+	if err := setOwnerReference(cluster, roleBinding); err != nil {
+		return err
+	}
+	return c.Create(ctx, roleBinding)
+}
+
+// isControlledBy tells whether the Cluster is the controller of the object,
+// the objects with the same name created by the users are left untouched.
+func isControlledBy(obj metav1.Object, cluster *cnpgv1.Cluster) bool {
+	owner := metav1.GetControllerOf(obj)
+	return owner != nil && owner.UID == cluster.UID
+}
+
+// deleteRBAC deletes the Role and RoleBinding created for a Cluster no
+// longer using the plugin
+func deleteRBAC(
+	ctx context.Context,
+	c client.Client,
+	cluster *cnpgv1.Cluster,
+) error {
+	contextLogger := log.FromContext(ctx)
+	key := client.ObjectKey{Namespace: cluster.Namespace, Name: GetRBACName(cluster.Name)}
+	for _, obj := range []client.Object{&rbacv1.RoleBinding{}, &rbacv1.Role{}} {
+		if err := c.Get(ctx, key, obj); err != nil {
+			if apierrs.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+		if !isControlledBy(obj, cluster) {
+			continue
+		}
+		contextLogger.Info("Deleting RBAC object of a cluster not using the plugin",
+			"name", key.Name, "namespace", key.Namespace, "kind", obj.GetObjectKind().GroupVersionKind().Kind)
+		if err := c.Delete(ctx, obj); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package operator
+
+import (
+	"context"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	pluginv1 "github.com/dalibo/cnpg-i-pgbackrest/api/v1"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/config"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// ClusterRBACReconciler keeps the Role and RoleBinding of the Clusters in
+// line with the stanzas they reference, between the reconciler hooks.
+// The hooks aren't called anymore once the plugin is removed from a
+// Cluster, the objects are then deleted here.
+type ClusterRBACReconciler struct {
+	Client client.Client
+	Scheme *runtime.Scheme
+}
+
+// SetupWithManager registers the reconciler, the Clusters are reconciled
+// again when their RBAC objects drift or when a Stanza or PluginConfig they
+// reference changes.
+func (r *ClusterRBACReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&cnpgv1.Cluster{}).
+		Owns(&rbacv1.Role{}).
+		Owns(&rbacv1.RoleBinding{}).
+		Watches(&pluginv1.Stanza{}, handler.EnqueueRequestsFromMapFunc(stanzaConsumerClusters)).
+		Watches(&pluginv1.PluginConfig{}, handler.EnqueueRequestsFromMapFunc(pluginConfigClusters(r.Client))).
+		Named("cluster-rbac").
+		Complete(r)
+}
+
+// stanzaConsumerClusters lists the Clusters recorded as using a Stanza
+func stanzaConsumerClusters(_ context.Context, obj client.Object) []reconcile.Request {
+	stanza, ok := obj.(*pluginv1.Stanza)
+	if !ok {
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(stanza.Status.Consumers))
+	for _, consumer := range stanza.Status.Consumers {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+			Namespace: stanza.Namespace,
+			Name:      consumer.Cluster,
+		}})
+	}
+	return requests
+}
+
+// pluginConfigClusters lists the Clusters referencing a PluginConfig
+func pluginConfigClusters(c client.Client) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		var clusters cnpgv1.ClusterList
+		if err := c.List(ctx, &clusters, client.InNamespace(obj.GetNamespace())); err != nil {
+			log.FromContext(ctx).Error(err, "can't list the clusters referencing the plugin configuration")
+			return nil
+		}
+		var requests []reconcile.Request
+		for i := range clusters.Items {
+			conf, err := config.NewFromCluster(&clusters.Items[i])
+			if err != nil || conf.PluginConfigRef != obj.GetName() {
+				continue
+			}
+			requests = append(requests, reconcile.Request{
+				NamespacedName: client.ObjectKeyFromObject(&clusters.Items[i]),
+			})
+		}
+		return requests
+	}
+}
+
+// Reconcile ensures the Role and RoleBinding of a Cluster using the plugin,
+// and deletes them once it doesn't reference any stanza anymore.
+func (r *ClusterRBACReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	contextLogger := log.FromContext(ctx).WithValues("cluster", req.NamespacedName)
+	ctx = log.IntoContext(ctx, contextLogger)
+
+	var cluster cnpgv1.Cluster
+	if err := r.Client.Get(ctx, req.NamespacedName, &cluster); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !cluster.DeletionTimestamp.IsZero() {
+		// the objects are owned by the Cluster, and garbage collected with it
+		return ctrl.Result{}, nil
+	}
+	// the type metadata isn't set by the client, it's required for the owner
+	// references
+	cluster.SetGroupVersionKind(cnpgv1.SchemeGroupVersion.WithKind(cnpgv1.ClusterKind))
+
+	conf, err := config.NewFromCluster(&cluster)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if !usesPlugin(conf) {
+		return ctrl.Result{}, deleteRBAC(ctx, r.Client, &cluster)
+	}
+
+	// the missing stanzas are left to the Pre hook, which requeues the
+	// Cluster until they're created
+	stanzas, pluginConfig, _, err := referencedObjects(ctx, r.Client, conf)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := ensureRole(ctx, r.Client, &cluster, stanzas, pluginConfig); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, ensureRoleBinding(ctx, r.Client, &cluster)
+}
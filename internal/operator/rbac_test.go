@@ -0,0 +1,201 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+package operator
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/cnpg-i/pkg/reconciler"
+	pluginv1 "github.com/dalibo/cnpg-i-pgbackrest/api/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ownedRBAC returns the Role and RoleBinding of the cluster, owned by the
+// given UID
+func ownedRBAC(t *testing.T, cluster *cnpgv1.Cluster, uid types.UID) (*rbacv1.Role, *rbacv1.RoleBinding) {
+	t.Helper()
+	owner := cluster.DeepCopy()
+	owner.UID = uid
+	role := BuildK8SRole(cluster.Namespace, cluster.Name, nil, nil)
+	binding := BindingK8SRole(cluster.Namespace, cluster.Name)
+	if err := setOwnerReference(owner, role); err != nil {
+		t.Fatal(err)
+	}
+	if err := setOwnerReference(owner, binding); err != nil {
+		t.Fatal(err)
+	}
+	return role, binding
+}
+
+func rbacExists(t *testing.T, c client.Client, cluster *cnpgv1.Cluster) (bool, bool) {
+	t.Helper()
+	key := client.ObjectKey{Namespace: cluster.Namespace, Name: GetRBACName(cluster.Name)}
+	exists := func(obj client.Object) bool {
+		err := c.Get(context.Background(), key, obj)
+		if err != nil && !apierrs.IsNotFound(err) {
+			t.Fatal(err)
+		}
+		return err == nil
+	}
+	return exists(&rbacv1.Role{}), exists(&rbacv1.RoleBinding{})
+}
+
+func TestEnsureRoleBinding(t *testing.T) {
+	cluster := testCluster("cluster", nil)
+	want := BindingK8SRole(cluster.Namespace, cluster.Name)
+	testCases := []struct {
+		desc   string
+		mutate func(*rbacv1.RoleBinding)
+	}{
+		{"missing", nil},
+		{"unchanged", func(*rbacv1.RoleBinding) {}},
+		{"drifted subjects", func(b *rbacv1.RoleBinding) {
+			b.Subjects = append(b.Subjects, rbacv1.Subject{Kind: "ServiceAccount", Name: "intruder"})
+		}},
+		{"drifted role", func(b *rbacv1.RoleBinding) {
+			b.RoleRef.Name = "other"
+		}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			var objs []client.Object
+			if tc.mutate != nil {
+				_, binding := ownedRBAC(t, cluster, cluster.UID)
+				tc.mutate(binding)
+				objs = append(objs, binding)
+			}
+			c := newFakeClient(objs...)
+			if err := ensureRoleBinding(context.Background(), c, cluster); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			var got rbacv1.RoleBinding
+			if err := c.Get(context.Background(), client.ObjectKeyFromObject(want), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.RoleRef != want.RoleRef || len(got.Subjects) != len(want.Subjects) ||
+				got.Subjects[0] != want.Subjects[0] {
+				t.Errorf("unexpected binding %+v %+v", got.RoleRef, got.Subjects)
+			}
+			if !isControlledBy(&got, cluster) {
+				t.Errorf("the binding must be owned by the cluster, got %+v", got.OwnerReferences)
+			}
+		})
+	}
+}
+
+func TestDeleteRBAC(t *testing.T) {
+	cluster := testCluster("cluster", nil)
+	testCases := []struct {
+		desc    string
+		owner   types.UID
+		deleted bool
+	}{
+		{"owned", cluster.UID, true},
+		{"other owner", "other-uid", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			role, binding := ownedRBAC(t, cluster, tc.owner)
+			c := newFakeClient(role, binding)
+			if err := deleteRBAC(context.Background(), c, cluster); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			roleExists, bindingExists := rbacExists(t, c, cluster)
+			if roleExists == tc.deleted || bindingExists == tc.deleted {
+				t.Errorf("want deleted %v, got role %v binding %v", tc.deleted, roleExists, bindingExists)
+			}
+		})
+	}
+	if err := deleteRBAC(context.Background(), newFakeClient(), cluster); err != nil {
+		t.Errorf("nothing to delete, got %v", err)
+	}
+}
+
+func TestPostHook_DeleteRBAC(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		params  map[string]string
+		deleted bool
+	}{
+		{"using the plugin", map[string]string{"stanzaRef": "stanza"}, false},
+		{"not referencing a stanza", nil, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			cluster := testCluster("cluster", tc.params)
+			role, binding := ownedRBAC(t, cluster, cluster.UID)
+			c := newFakeClient(role, binding)
+			definition, err := json.Marshal(cluster)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := ReconcilerImplementation{Client: c}.Post(context.Background(),
+				&reconciler.ReconcilerHooksRequest{ResourceDefinition: definition})
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if result.Behavior != reconciler.ReconcilerHooksResult_BEHAVIOR_CONTINUE {
+				t.Errorf("unexpected behavior %v", result.Behavior)
+			}
+			roleExists, bindingExists := rbacExists(t, c, cluster)
+			if roleExists == tc.deleted || bindingExists == tc.deleted {
+				t.Errorf("want deleted %v, got role %v binding %v", tc.deleted, roleExists, bindingExists)
+			}
+		})
+	}
+}
+
+func TestClusterRBACReconciler(t *testing.T) {
+	stanza := &pluginv1.Stanza{
+		ObjectMeta: metav1.ObjectMeta{Name: "stanza", Namespace: "default"},
+	}
+	cluster := testCluster("cluster", map[string]string{"stanzaRef": "stanza"})
+	r := &ClusterRBACReconciler{Client: newFakeClient(stanza, cluster), Scheme: scheme}
+	ctx := context.Background()
+
+	runReconcile(t, r, cluster)
+	if roleExists, bindingExists := rbacExists(t, r.Client, cluster); !roleExists || !bindingExists {
+		t.Fatalf("the RBAC objects must be created, got role %v binding %v", roleExists, bindingExists)
+	}
+	var role rbacv1.Role
+	if err := r.Client.Get(ctx, client.ObjectKey{Namespace: "default", Name: GetRBACName("cluster")}, &role); err != nil {
+		t.Fatal(err)
+	}
+	if !isControlledBy(&role, cluster) {
+		t.Errorf("the role must be owned by the cluster, got %+v", role.OwnerReferences)
+	}
+
+	var current cnpgv1.Cluster
+	if err := r.Client.Get(ctx, client.ObjectKeyFromObject(cluster), &current); err != nil {
+		t.Fatal(err)
+	}
+	current.Spec.Plugins = nil
+	if err := r.Client.Update(ctx, &current); err != nil {
+		t.Fatal(err)
+	}
+	runReconcile(t, r, cluster)
+	if roleExists, bindingExists := rbacExists(t, r.Client, cluster); roleExists || bindingExists {
+		t.Errorf("the RBAC objects must be deleted, got role %v binding %v", roleExists, bindingExists)
+	}
+}
+
+func TestStanzaConsumerClusters(t *testing.T) {
+	stanza := &pluginv1.Stanza{
+		ObjectMeta: metav1.ObjectMeta{Name: "stanza", Namespace: "default"},
+		Status: pluginv1.StanzaStatus{
+			Consumers: []pluginv1.StanzaConsumer{{Cluster: "a"}, {Cluster: "b"}},
+		},
+	}
+	got := stanzaConsumerClusters(context.Background(), stanza)
+	if len(got) != 2 || got[1].Name != "b" || got[1].Namespace != "default" {
+		t.Errorf("unexpected requests %+v", got)
+	}
+}
@@ -0,0 +1,125 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package operator
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/metadata"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// RebuildReconciler makes the rebuild requests one-shot: an instance is
+// removed from the rebuild annotation of its Cluster once the init
+// container rebuilding its Pod succeeded, so that it isn't rebuilt again on
+// the next restart.
+type RebuildReconciler struct {
+	Client client.Client
+	Scheme *runtime.Scheme
+}
+
+// SetupWithManager registers the reconciler, the Clusters are reconciled
+// again when the rebuild of one of their Pods succeeded.
+func (r *RebuildReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&cnpgv1.Cluster{}).
+		Watches(&corev1.Pod{},
+			handler.EnqueueRequestForOwner(mgr.GetScheme(), mgr.GetRESTMapper(), &cnpgv1.Cluster{},
+				handler.OnlyControllerOwner()),
+			builder.WithPredicates(predicate.NewPredicateFuncs(func(obj client.Object) bool {
+				pod, ok := obj.(*corev1.Pod)
+				return ok && rebuildPending(pod)
+			}))).
+		Named("rebuild").
+		Complete(r)
+}
+
+// Reconcile removes the instances whose rebuild succeeded from the rebuild
+// annotation of the Cluster, then marks their Pod as rebuilt: a new request
+// for the same Pod is only consumed by its next rebuild.
+func (r *RebuildReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	contextLogger := log.FromContext(ctx).WithValues("cluster", req.NamespacedName)
+
+	var cluster cnpgv1.Cluster
+	if err := r.Client.Get(ctx, req.NamespacedName, &cluster); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	var pods corev1.PodList
+	if err := r.Client.List(ctx, &pods,
+		client.InNamespace(cluster.Namespace),
+		client.MatchingLabels{"cnpg.io/cluster": cluster.Name},
+	); err != nil {
+		return ctrl.Result{}, err
+	}
+	var rebuilt []*corev1.Pod
+	for i := range pods.Items {
+		if rebuildPending(&pods.Items[i]) {
+			rebuilt = append(rebuilt, &pods.Items[i])
+		}
+	}
+	if len(rebuilt) == 0 {
+		return ctrl.Result{}, nil
+	}
+
+	var remaining []string
+	if instances, ok := cluster.Annotations[metadata.RebuildAnnotationName]; ok {
+		for _, name := range strings.Split(instances, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" || slices.ContainsFunc(rebuilt, func(pod *corev1.Pod) bool { return pod.Name == name }) {
+				continue
+			}
+			remaining = append(remaining, name)
+		}
+		oldCluster := cluster.DeepCopy()
+		if len(remaining) == 0 {
+			delete(cluster.Annotations, metadata.RebuildAnnotationName)
+		} else {
+			cluster.Annotations[metadata.RebuildAnnotationName] = strings.Join(remaining, ",")
+		}
+		if cluster.Annotations[metadata.RebuildAnnotationName] != oldCluster.Annotations[metadata.RebuildAnnotationName] {
+			contextLogger.Info("the rebuilt instances are removed from the rebuild annotation",
+				"remaining", remaining)
+			if err := r.Client.Patch(ctx, &cluster, client.MergeFrom(oldCluster)); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+	}
+
+	for _, pod := range rebuilt {
+		oldPod := pod.DeepCopy()
+		if pod.Annotations == nil {
+			pod.Annotations = make(map[string]string)
+		}
+		pod.Annotations[metadata.RebuiltAnnotationName] = "true"
+		if err := r.Client.Patch(ctx, pod, client.MergeFrom(oldPod)); client.IgnoreNotFound(err) != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// rebuildPending tells whether the init container rebuilding the Pod
+// succeeded, and the Pod isn't marked as rebuilt yet
+func rebuildPending(pod *corev1.Pod) bool {
+	if _, ok := pod.Annotations[metadata.RebuiltAnnotationName]; ok {
+		return false
+	}
+	for _, status := range pod.Status.InitContainerStatuses {
+		if status.Name == REBUILD_CONTAINER_NAME {
+			return status.State.Terminated != nil && status.State.Terminated.ExitCode == 0
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+package operator
+
+import (
+	"slices"
+	"testing"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/metadata"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestRebuildReconciler(t *testing.T) {
+	pod := func(name string, exitCode int32, rebuilt bool) *corev1.Pod {
+		p := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels:    map[string]string{"cnpg.io/cluster": "cluster"},
+			},
+			Status: corev1.PodStatus{InitContainerStatuses: []corev1.ContainerStatus{{
+				Name: REBUILD_CONTAINER_NAME,
+				State: corev1.ContainerState{
+					Terminated: &corev1.ContainerStateTerminated{ExitCode: exitCode},
+				},
+			}}},
+		}
+		if rebuilt {
+			p.Annotations = map[string]string{metadata.RebuiltAnnotationName: "true"}
+		}
+		return p
+	}
+	testCases := []struct {
+		desc        string
+		annotation  string
+		pods        []client.Object
+		want        string
+		wantRebuilt []string
+	}{
+		{
+			desc:        "last instance rebuilt",
+			annotation:  "cluster-2",
+			pods:        []client.Object{pod("cluster-2", 0, false)},
+			wantRebuilt: []string{"cluster-2"},
+		},
+		{
+			desc:        "other instances kept",
+			annotation:  "cluster-3, cluster-2",
+			pods:        []client.Object{pod("cluster-2", 0, false)},
+			want:        "cluster-3",
+			wantRebuilt: []string{"cluster-2"},
+		},
+		{
+			desc:       "rebuild failed",
+			annotation: "cluster-2",
+			pods:       []client.Object{pod("cluster-2", 1, false)},
+			want:       "cluster-2",
+		},
+		{
+			desc:        "new request for a rebuilt pod",
+			annotation:  "cluster-2",
+			pods:        []client.Object{pod("cluster-2", 0, true)},
+			want:        "cluster-2",
+			wantRebuilt: []string{"cluster-2"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			cluster := testCluster("cluster", nil)
+			cluster.Annotations = map[string]string{metadata.RebuildAnnotationName: tc.annotation}
+			c := newFakeClient(append(tc.pods, cluster)...)
+			runReconcile(t, &RebuildReconciler{Client: c, Scheme: scheme}, cluster)
+
+			got := &cnpgv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "default"}}
+			getObject(t, c, got)
+			if annotation, ok := got.Annotations[metadata.RebuildAnnotationName]; annotation != tc.want || ok != (tc.want != "") {
+				t.Errorf("want the annotation %q, got %q", tc.want, annotation)
+			}
+			for _, obj := range tc.pods {
+				p := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: obj.GetName(), Namespace: "default"}}
+				getObject(t, c, p)
+				_, rebuilt := p.Annotations[metadata.RebuiltAnnotationName]
+				if want := slices.Contains(tc.wantRebuilt, p.Name); rebuilt != want {
+					t.Errorf("want pod %s marked rebuilt %v, got %v", p.Name, want, rebuilt)
+				}
+			}
+		})
+	}
+}
@@ -12,11 +12,7 @@ import (
 	"github.com/cloudnative-pg/cnpg-i-machinery/pkg/pluginhelper/object"
 	"github.com/cloudnative-pg/cnpg-i/pkg/reconciler"
 	"github.com/cloudnative-pg/machinery/pkg/log"
-	apipgbackrest "github.com/dalibo/cnpg-i-pgbackrest/api/v1"
 	"github.com/dalibo/cnpg-i-pgbackrest/internal/config"
-	rbacv1 "k8s.io/api/rbac/v1"
-	"k8s.io/apimachinery/pkg/api/equality"
-	apierrs "k8s.io/apimachinery/pkg/api/errors"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -76,40 +72,34 @@ func (r ReconcilerImplementation) Pre(
 	if err != nil {
 		return nil, err
 	}
-	refPgBackrestObj := conf.GetReferredPgBackrestObjectKey()
-	stanzas := make([]apipgbackrest.Stanza, 0, len(refPgBackrestObj))
-	for _, rpb := range refPgBackrestObj {
-		var stanza apipgbackrest.Stanza
-		contextLogger.Debug("parsing cluster definition", "pgbackrestObjectKey", rpb)
-		if err := r.Client.Get(ctx, rpb, &stanza); err != nil {
-			if apierrs.IsNotFound(err) {
-				contextLogger.Debug("error enforcing requeue", "error", err)
-				return &reconciler.ReconcilerHooksResult{
-					Behavior: reconciler.ReconcilerHooksResult_BEHAVIOR_REQUEUE,
-				}, nil
-			}
-			return nil, err
-		}
-		stanzas = append(stanzas, stanza)
+	if !usesPlugin(conf) {
+		// the RBAC objects are deleted by the Post hook
+		contextLogger.Debug("no stanza referenced, skipping the role")
+		return &reconciler.ReconcilerHooksResult{
+			Behavior: reconciler.ReconcilerHooksResult_BEHAVIOR_CONTINUE,
+		}, nil
 	}
-
-	refPluginConf, _ := conf.GetSharedPluginConfig()
-
-	var sharedPluginConf *apipgbackrest.PluginConfig
-	if refPluginConf != nil {
-		sharedPluginConf = &apipgbackrest.PluginConfig{}
-		if err := r.Client.Get(ctx, *refPluginConf, sharedPluginConf); err != nil {
-			if !apierrs.IsNotFound(err) {
-				return nil, err
-			}
-			sharedPluginConf = nil
-		}
+	stanzas, sharedPluginConf, missing, err := referencedObjects(ctx, r.Client, conf)
+	if err != nil {
+		return nil, err
+	}
+	if missing {
+		contextLogger.Debug("stanza not found, enforcing requeue")
+		return &reconciler.ReconcilerHooksResult{
+			Behavior: reconciler.ReconcilerHooksResult_BEHAVIOR_REQUEUE,
+		}, nil
 	}
 
-	if err := r.ensureRole(ctx, &cluster, stanzas, sharedPluginConf); err != nil {
+	if err := checkWebIdentities(conf, stanzas); err != nil {
+		return nil, err
+	}
+	if err := r.recordConsumer(ctx, conf, stanzas); err != nil {
+		return nil, err
+	}
+	if err := ensureRole(ctx, r.Client, &cluster, stanzas, sharedPluginConf); err != nil {
 		return nil, err
 	}
-	if err := r.ensureRoleBinding(ctx, &cluster); err != nil {
+	if err := ensureRoleBinding(ctx, r.Client, &cluster); err != nil {
 		return nil, err
 	}
 	contextLogger.Info("Pre hook reconciliation completed")
@@ -118,95 +108,39 @@ func (r ReconcilerImplementation) Pre(
 	}, nil
 }
 
-// Post implements the reconciler interface
+// Post implements the reconciler interface, the Role and RoleBinding of a
+// Cluster not referencing any stanza anymore are deleted
 func (r ReconcilerImplementation) Post(
-	_ context.Context,
-	_ *reconciler.ReconcilerHooksRequest,
-) (*reconciler.ReconcilerHooksResult, error) {
-	return &reconciler.ReconcilerHooksResult{
-		Behavior: reconciler.ReconcilerHooksResult_BEHAVIOR_CONTINUE,
-	}, nil
-}
-
-func (r ReconcilerImplementation) ensureRole(
 	ctx context.Context,
-	cluster *cnpgv1.Cluster,
-	stanza []apipgbackrest.Stanza,
-	pluginconfig *apipgbackrest.PluginConfig,
-) error {
-	contextLogger := log.FromContext(ctx)
-	newRole := BuildK8SRole(cluster.Namespace, cluster.Name, stanza, pluginconfig)
-
-	var role rbacv1.Role
-	if err := r.Client.Get(ctx, client.ObjectKey{
-		Namespace: newRole.Namespace,
-		Name:      newRole.Name,
-	}, &role); err != nil {
-		if !apierrs.IsNotFound(err) {
-			return err
-		}
-
-		contextLogger.Info(
-			"Creating role",
-			"name", newRole.Name,
-			"namespace", newRole.Namespace,
-		)
-
-		if err := setOwnerReference(cluster, newRole); err != nil {
-			return err
-		}
-
-		return r.Client.Create(ctx, newRole)
+	request *reconciler.ReconcilerHooksRequest,
+) (*reconciler.ReconcilerHooksResult, error) {
+	reconciledKind, err := object.GetKind(request.GetResourceDefinition())
+	if err != nil {
+		return nil, err
 	}
-
-	if equality.Semantic.DeepEqual(newRole.Rules, role.Rules) {
-		// There's no need to hit the API server again
-		return nil
+	if reconciledKind != "Cluster" {
+		return &reconciler.ReconcilerHooksResult{
+			Behavior: reconciler.ReconcilerHooksResult_BEHAVIOR_CONTINUE,
+		}, nil
 	}
 
-	contextLogger.Info(
-		"Patching role",
-		"name", newRole.Name,
-		"namespace", newRole.Namespace,
-		"rules", newRole.Rules,
-	)
-
-	oldRole := role.DeepCopy()
-
-	// Apply to the role the new rules
-	role.Rules = newRole.Rules
-
-	// Push it back to the API server
-	return r.Client.Patch(ctx, &role, client.MergeFrom(oldRole))
-}
-
-func (r ReconcilerImplementation) ensureRoleBinding(
-	ctx context.Context,
-	cluster *cnpgv1.Cluster,
-) error {
-	var role rbacv1.RoleBinding
-	if err := r.Client.Get(ctx, client.ObjectKey{
-		Namespace: cluster.Namespace,
-		Name:      GetRBACName(cluster.Name),
-	}, &role); err != nil {
-		if apierrs.IsNotFound(err) {
-			return r.createRoleBinding(ctx, cluster)
-		}
-		return err
+	var cluster cnpgv1.Cluster
+	if err := decoder.DecodeObjectLenient(
+		request.GetResourceDefinition(),
+		&cluster); err != nil {
+		return nil, err
 	}
-
-	// TODO: this assumes role bindings never change.
-	// Is that true? Should we relax this assumption?
-	return nil
-}
-
-func (r ReconcilerImplementation) createRoleBinding(
-	ctx context.Context,
-	cluster *cnpgv1.Cluster,
-) error {
-	roleBinding := BindingK8SRole(cluster.Namespace, cluster.Name)
-	if err := setOwnerReference(cluster, roleBinding); err != nil {
-		return err
+	conf, err := config.NewFromCluster(&cluster)
+	if err != nil {
+		return nil, err
+	}
+	if !usesPlugin(conf) {
+		contextLogger := log.FromContext(ctx).WithValues("name", cluster.Name, "namespace", cluster.Namespace)
+		if err := deleteRBAC(log.IntoContext(ctx, contextLogger), r.Client, &cluster); err != nil {
+			return nil, err
+		}
 	}
-	return r.Client.Create(ctx, roleBinding)
+	return &reconciler.ReconcilerHooksResult{
+		Behavior: reconciler.ReconcilerHooksResult_BEHAVIOR_CONTINUE,
+	}, nil
 }
@@ -8,13 +8,15 @@ import (
 
 	"github.com/cloudnative-pg/machinery/pkg/stringset"
 	apipgbackrest "github.com/dalibo/cnpg-i-pgbackrest/api/v1"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/config"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func getSecrets(stanza apipgbackrest.Stanza, s *stringset.Data) {
 	for _, s3r := range stanza.Spec.Configuration.S3Repositories {
-		if s3r.SecretRef != nil {
+		sharedKey := s3r.KeyType == "" || s3r.KeyType == apipgbackrest.S3KeyTypeShared
+		if s3r.SecretRef != nil && sharedKey {
 			akidr := s3r.SecretRef.AccessKeyIDReference
 			akisr := s3r.SecretRef.SecretAccessKeyReference
 			if akidr != nil {
@@ -29,13 +31,21 @@ func getSecrets(stanza apipgbackrest.Stanza, s *stringset.Data) {
 				s.Put(pr.Name)
 			}
 		}
+		if sse := s3r.ServerSideEncryption; sse != nil && sse.CustomerKeyReference != nil {
+			s.Put(sse.CustomerKeyReference.Name)
+		}
 	}
 	for _, azr := range stanza.Spec.Configuration.AzureRepositories {
-		if azr.SecretRef != nil {
+		if azr.SecretRef != nil && config.AzureKeyFromSecret(azr.KeyType) {
 			if azk := azr.SecretRef.KeyReference; azk != nil {
 				s.Put(azk.Name)
 			}
 		}
+		if azr.Cipher != nil {
+			if pr := azr.Cipher.PassReference; pr != nil {
+				s.Put(pr.Name)
+			}
+		}
 	}
 }
 
@@ -89,6 +99,12 @@ func BuildK8SRole(
 			Verbs:         []string{"get", "watch", "list"},
 			ResourceNames: secretsSet.ToSortedList(),
 		},
+		// the instances report on the stanzas through events
+		rbacv1.PolicyRule{
+			APIGroups: []string{"events.k8s.io"},
+			Resources: []string{"events"},
+			Verbs:     []string{"create", "patch"},
+		},
 	)
 	return role
 }
@@ -70,6 +70,35 @@ func TestGetSecrets(t *testing.T) {
 		}
 	})
 
+	t.Run("azure cipher and workload identity", func(t *testing.T) {
+		ref := func(name string) *machineryapi.SecretKeySelector {
+			return &machineryapi.SecretKeySelector{
+				LocalObjectReference: machineryapi.LocalObjectReference{Name: name},
+				Key:                  "key",
+			}
+		}
+		stanza := pgbackrestapi.Stanza{
+			Spec: pgbackrestapi.StanzaSpec{
+				Configuration: pgbackrestapi.StanzaConfiguration{
+					AzureRepositories: []pgbackrestapi.AzureRepository{
+						{
+							KeyType:   pgbackrestapi.AzureKeyTypeWorkloadIdentity,
+							SecretRef: &pgbackrestapi.AzureSecretRef{KeyReference: ref("unused-key")},
+							Cipher:    &pgbackrestapi.CipherConfig{PassReference: ref("cipher-pass")},
+						},
+					},
+				},
+			},
+		}
+
+		s := stringset.New()
+		getSecrets(stanza, s)
+
+		if !s.Has("cipher-pass") || s.Len() != 1 {
+			t.Errorf("expected only the cipher secret, got %v", s.ToList())
+		}
+	})
+
 	t.Run("no secrets", func(t *testing.T) {
 		stanza := pgbackrestapi.Stanza{
 			Spec: pgbackrestapi.StanzaSpec{
@@ -123,7 +152,7 @@ func TestBuildK8SRole(t *testing.T) {
 					Namespace: testNs,
 				},
 			},
-			wantRuleCount: 4,
+			wantRuleCount: 5,
 		},
 		{
 			name:        "without plugin config",
@@ -138,7 +167,7 @@ func TestBuildK8SRole(t *testing.T) {
 				},
 			},
 			pluginconf:    nil,
-			wantRuleCount: 3,
+			wantRuleCount: 4,
 		},
 		{
 			name:          "no stanzas",
@@ -146,7 +175,7 @@ func TestBuildK8SRole(t *testing.T) {
 			clusterName:   "cluster1",
 			stanzas:       nil,
 			pluginconf:    nil,
-			wantRuleCount: 3,
+			wantRuleCount: 4,
 		},
 	}
 
@@ -198,6 +227,12 @@ func TestBuildK8SRole(t *testing.T) {
 					wantStanzaNames, stanzaRule.ResourceNames)
 			}
 
+			if !slices.ContainsFunc(role.Rules, func(r rbacv1.PolicyRule) bool {
+				return slices.Contains(r.Resources, "events") && slices.Contains(r.Verbs, "create")
+			}) {
+				t.Fatalf("events rule not found")
+			}
+
 			if tt.pluginconf != nil {
 				var pluginRule *rbacv1.PolicyRule
 				for i := range role.Rules {
@@ -0,0 +1,175 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package operator
+
+import (
+	"context"
+	"strings"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	pluginv1 "github.com/dalibo/cnpg-i-pgbackrest/api/v1"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/config"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+)
+
+const spoolPVCSuffix = "-pgbackrest-spool"
+
+// SpoolReconciler manages the spool PersistentVolumeClaims of the instances
+// after their creation: they're expanded when the configured size grows,
+// and deleted once their instance is gone.
+type SpoolReconciler struct {
+	Client client.Client
+	Scheme *runtime.Scheme
+}
+
+// SetupWithManager registers the reconciler, the Clusters are reconciled
+// again when their volumes or the PluginConfig they reference change.
+func (r *SpoolReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&cnpgv1.Cluster{}).
+		Watches(&corev1.PersistentVolumeClaim{},
+			handler.EnqueueRequestForOwner(mgr.GetScheme(), mgr.GetRESTMapper(), &cnpgv1.Cluster{})).
+		Watches(&pluginv1.PluginConfig{}, handler.EnqueueRequestsFromMapFunc(pluginConfigClusters(r.Client))).
+		Named("spool").
+		Complete(r)
+}
+
+// Reconcile resizes the spool volumes of the running instances, and
+// releases the ones of the instances that are gone.
+func (r *SpoolReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	contextLogger := log.FromContext(ctx).WithValues("cluster", req.NamespacedName)
+	ctx = log.IntoContext(ctx, contextLogger)
+
+	var cluster cnpgv1.Cluster
+	if err := r.Client.Get(ctx, req.NamespacedName, &cluster); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !cluster.DeletionTimestamp.IsZero() {
+		// the volumes are owned by the Cluster, and garbage collected with it
+		return ctrl.Result{}, nil
+	}
+	conf, err := config.NewFromCluster(&cluster)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	var pc pluginv1.PluginConfig
+	if ref, _ := conf.GetSharedPluginConfig(); ref != nil {
+		if err := r.Client.Get(ctx, *ref, &pc); client.IgnoreNotFound(err) != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	size := resource.MustParse(getSpoolWALSize(cluster.Spec.WalStorage, pc.Spec.StorageConfig))
+
+	var pvcs corev1.PersistentVolumeClaimList
+	if err := r.Client.List(ctx, &pvcs, client.InNamespace(cluster.Namespace)); err != nil {
+		return ctrl.Result{}, err
+	}
+	for i := range pvcs.Items {
+		pvc := &pvcs.Items[i]
+		instance, ok := strings.CutSuffix(pvc.Name, spoolPVCSuffix)
+		if !ok || !isOwnedBy(pvc, &cluster) || !pvc.DeletionTimestamp.IsZero() {
+			continue
+		}
+		used, err := r.instanceExists(ctx, cluster.Namespace, instance)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if used {
+			err = r.resizeSpool(ctx, pvc, size)
+		} else {
+			err = r.releaseSpool(ctx, pvc)
+		}
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// isOwnedBy tells whether the Cluster is an owner of the object, the spool
+// volumes aren't controlled by the Cluster
+func isOwnedBy(obj metav1.Object, cluster *cnpgv1.Cluster) bool {
+	for _, owner := range obj.GetOwnerReferences() {
+		if owner.UID == cluster.UID {
+			return true
+		}
+	}
+	return false
+}
+
+// instanceExists tells whether an instance still exists: the volume
+// holding its data, named after it, is deleted along with the instance
+// (e.g. when the Cluster is scaled down), not when its Pod is recreated.
+func (r *SpoolReconciler) instanceExists(ctx context.Context, namespace, instance string) (bool, error) {
+	var pgdata corev1.PersistentVolumeClaim
+	err := r.Client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: instance}, &pgdata)
+	if apierrs.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return pgdata.DeletionTimestamp.IsZero(), nil
+}
+
+// resizeSpool expands the spool volume to the configured size, when its
+// storage class allows it. Volumes can't be shrunk.
+func (r *SpoolReconciler) resizeSpool(
+	ctx context.Context,
+	pvc *corev1.PersistentVolumeClaim,
+	size resource.Quantity,
+) error {
+	contextLogger := log.FromContext(ctx).WithValues("pvc", pvc.Name)
+	current := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	switch size.Cmp(current) {
+	case 0:
+		return nil
+	case -1:
+		contextLogger.Debug("the spool volume can't be shrunk", "size", current.String(), "wanted", size.String())
+		return nil
+	}
+
+	if pvc.Spec.StorageClassName == nil {
+		contextLogger.Warning("can't expand the spool volume without storage class")
+		return nil
+	}
+	var sc storagev1.StorageClass
+	if err := r.Client.Get(ctx, client.ObjectKey{Name: *pvc.Spec.StorageClassName}, &sc); err != nil {
+		if apierrs.IsNotFound(err) {
+			contextLogger.Warning("can't expand the spool volume, storage class not found",
+				"storageClass", *pvc.Spec.StorageClassName)
+			return nil
+		}
+		return err
+	}
+	if sc.AllowVolumeExpansion == nil || !*sc.AllowVolumeExpansion {
+		contextLogger.Warning("can't expand the spool volume, the storage class doesn't allow it",
+			"storageClass", sc.Name, "size", current.String(), "wanted", size.String())
+		return nil
+	}
+
+	contextLogger.Info("expanding the spool volume", "size", current.String(), "wanted", size.String())
+	oldPVC := pvc.DeepCopy()
+	pvc.Spec.Resources.Requests[corev1.ResourceStorage] = size
+	return r.Client.Patch(ctx, pvc, client.MergeFrom(oldPVC))
+}
+
+// releaseSpool deletes the spool volume of an instance that is gone. The
+// asynchronous archive-push only keeps acknowledgements there, the WAL
+// segments left to push were in the pg_wal of the instance, gone along with
+// its data volume.
+func (r *SpoolReconciler) releaseSpool(ctx context.Context, pvc *corev1.PersistentVolumeClaim) error {
+	log.FromContext(ctx).Info("deleting the spool volume of a deleted instance", "pvc", pvc.Name)
+	return client.IgnoreNotFound(r.Client.Delete(ctx, pvc))
+}
@@ -0,0 +1,98 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+package operator
+
+import (
+	"testing"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	pluginv1 "github.com/dalibo/cnpg-i-pgbackrest/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+func testPVC(name, size string, owner *cnpgv1.Cluster) *corev1.PersistentVolumeClaim {
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			StorageClassName: ptr.To("fast"),
+		},
+	}
+	if size != "" {
+		pvc.Spec.Resources.Requests = corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(size)}
+	}
+	if owner != nil {
+		pvc.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: cnpgv1.SchemeGroupVersion.String(),
+			Kind:       cnpgv1.ClusterKind,
+			Name:       owner.Name,
+			UID:        owner.UID,
+		}}
+	}
+	return pvc
+}
+
+func TestSpoolReconciler_Resize(t *testing.T) {
+	testCases := []struct {
+		desc      string
+		current   string
+		expansion *bool
+		want      string
+	}{
+		{"expanded", "1Gi", ptr.To(true), "4Gi"},
+		{"expansion not allowed", "1Gi", ptr.To(false), "1Gi"},
+		{"expansion not set", "1Gi", nil, "1Gi"},
+		{"not shrunk", "8Gi", ptr.To(true), "8Gi"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			cluster := testCluster("cluster", map[string]string{"stanzaRef": "stanza", "pluginConfigRef": "conf"})
+			pc := &pluginv1.PluginConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: "conf", Namespace: "default"},
+				Spec: pluginv1.PluginConfigSpec{
+					StorageConfig: &pluginv1.StorageConfig{StorageClass: "fast", Size: "4Gi"},
+				},
+			}
+			sc := &storagev1.StorageClass{
+				ObjectMeta:           metav1.ObjectMeta{Name: "fast"},
+				AllowVolumeExpansion: tc.expansion,
+			}
+			c := newFakeClient(cluster, pc, sc,
+				testPVC("cluster-1", "10Gi", nil),
+				testPVC("cluster-1-pgbackrest-spool", tc.current, cluster))
+			runReconcile(t, &SpoolReconciler{Client: c, Scheme: scheme}, cluster)
+
+			got := testPVC("cluster-1-pgbackrest-spool", "", nil)
+			if !getObject(t, c, got) {
+				t.Fatal("the spool must be kept")
+			}
+			size := got.Spec.Resources.Requests[corev1.ResourceStorage]
+			if size.String() != tc.want {
+				t.Errorf("want %s, got %s", tc.want, size.String())
+			}
+		})
+	}
+}
+
+func TestSpoolReconciler_Release(t *testing.T) {
+	cluster := testCluster("cluster", map[string]string{"stanzaRef": "stanza"})
+	other := testCluster("other", nil)
+	c := newFakeClient(cluster,
+		testPVC("cluster-1", "10Gi", nil),
+		testPVC("cluster-1-pgbackrest-spool", "1Gi", cluster),
+		testPVC("cluster-2-pgbackrest-spool", "1Gi", cluster),
+		testPVC("other-2-pgbackrest-spool", "1Gi", other))
+	runReconcile(t, &SpoolReconciler{Client: c, Scheme: scheme}, cluster)
+
+	if getObject(t, c, testPVC("cluster-2-pgbackrest-spool", "", nil)) {
+		t.Error("the spool of the gone instance must be deleted")
+	}
+	if !getObject(t, c, testPVC("cluster-1-pgbackrest-spool", "", nil)) ||
+		!getObject(t, c, testPVC("other-2-pgbackrest-spool", "", nil)) {
+		t.Error("the spools of the running instances and of the other clusters must be kept")
+	}
+}
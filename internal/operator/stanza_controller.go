@@ -0,0 +1,355 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package operator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	pluginv1 "github.com/dalibo/cnpg-i-pgbackrest/api/v1"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/cleanup"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/config"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/metadata"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	stanzaDeletionCondition = "Deletion"
+	stanzaDeleteContainer   = "stanza-delete"
+	// stanzaDeleteEnvPath is where the environment of the stanza-delete Job
+	// is mounted, when the configuration is rendered to files
+	stanzaDeleteEnvPath = "/etc/pgbackrest-env"
+)
+
+// StanzaReconciler keeps the Stanzas until no Cluster references them, then
+// cleans their repositories up according to their deletion policy.
+type StanzaReconciler struct {
+	Client client.Client
+	Scheme *runtime.Scheme
+}
+
+// SetupWithManager registers the reconciler, the Stanzas are reconciled again
+// when a Cluster referencing them changes.
+func (r *StanzaReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&pluginv1.Stanza{}).
+		Owns(&batchv1.Job{}).
+		Watches(&cnpgv1.Cluster{}, handler.EnqueueRequestsFromMapFunc(referencedStanzas)).
+		Named("stanza").
+		Complete(r)
+}
+
+// referencedStanzas lists the Stanzas referenced by a Cluster
+func referencedStanzas(_ context.Context, obj client.Object) []reconcile.Request {
+	cluster, ok := obj.(*cnpgv1.Cluster)
+	if !ok {
+		return nil
+	}
+	conf, err := config.NewFromCluster(cluster)
+	if err != nil {
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, key := range conf.GetReferredPgBackrestObjectKey() {
+		requests = append(requests, reconcile.Request{NamespacedName: key})
+	}
+	return requests
+}
+
+// Reconcile records the Clusters using the Stanza and adds the finalizer to
+// it, the finalizer is removed once the Stanza can be deleted.
+func (r *StanzaReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	contextLogger := log.FromContext(ctx).WithValues("stanza", req.NamespacedName)
+	ctx = log.IntoContext(ctx, contextLogger)
+
+	var stanza pluginv1.Stanza
+	if err := r.Client.Get(ctx, req.NamespacedName, &stanza); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	var clusters cnpgv1.ClusterList
+	if err := r.Client.List(ctx, &clusters, client.InNamespace(stanza.Namespace)); err != nil {
+		return ctrl.Result{}, err
+	}
+	// the consumers recorded by the reconciler hook are refreshed, to forget
+	// the Clusters deleted or not referencing the Stanza anymore
+	err := updateStanzaConsumers(ctx, r.Client, &stanza,
+		func(stanza *pluginv1.Stanza) ([]pluginv1.StanzaConsumer, error) {
+			return stanzaConsumers(clusters.Items, stanza)
+		})
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if stanza.DeletionTimestamp.IsZero() {
+		if controllerutil.AddFinalizer(&stanza, metadata.StanzaFinalizerName) {
+			return ctrl.Result{}, r.Client.Update(ctx, &stanza)
+		}
+		return ctrl.Result{}, nil
+	}
+	if !controllerutil.ContainsFinalizer(&stanza, metadata.StanzaFinalizerName) {
+		return ctrl.Result{}, nil
+	}
+
+	if consumers := stanza.Status.Consumers; len(consumers) > 0 {
+		names := make([]string, len(consumers))
+		for i := range consumers {
+			names[i] = consumers[i].Cluster
+		}
+		contextLogger.Info("deletion blocked by the clusters referencing the stanza", "clusters", names)
+		return ctrl.Result{}, r.setDeletionCondition(ctx, &stanza, "ReferencedByClusters",
+			fmt.Sprintf("the stanza is referenced by the clusters %s", strings.Join(names, ", ")))
+	}
+
+	if stanza.Spec.DeletionPolicy == pluginv1.DeletionPolicyDelete {
+		deleted, err := r.deleteRepositories(ctx, &stanza)
+		if err != nil || !deleted {
+			return ctrl.Result{}, err
+		}
+	}
+
+	contextLogger.Info("removing the finalizer", "deletionPolicy", stanza.Spec.DeletionPolicy)
+	controllerutil.RemoveFinalizer(&stanza, metadata.StanzaFinalizerName)
+	return ctrl.Result{}, r.Client.Update(ctx, &stanza)
+}
+
+func (r *StanzaReconciler) setDeletionCondition(
+	ctx context.Context,
+	stanza *pluginv1.Stanza,
+	reason string,
+	message string,
+) error {
+	cond := metav1.Condition{
+		Type:               stanzaDeletionCondition,
+		Status:             metav1.ConditionFalse,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: stanza.Generation,
+	}
+	if current := apimeta.FindStatusCondition(stanza.Status.Conditions, cond.Type); current != nil &&
+		current.Reason == cond.Reason && current.Message == cond.Message {
+		return nil
+	}
+	apimeta.SetStatusCondition(&stanza.Status.Conditions, cond)
+	return r.Client.Status().Update(ctx, stanza)
+}
+
+// stanzaDeleteName returns the name of the Job deleting the stanza from its
+// repositories, and of the Secret holding its environment
+func stanzaDeleteName(stanza *pluginv1.Stanza) string {
+	name := stanza.Name + "-" + stanzaDeleteContainer
+	if len(name) <= 63 {
+		return name
+	}
+	sum := sha256.Sum256([]byte(stanza.UID))
+	return name[:54] + "-" + hex.EncodeToString(sum[:4])
+}
+
+// deleteRepositories runs a Job deleting the stanza from every repository,
+// it reports whether the Job completed.
+func (r *StanzaReconciler) deleteRepositories(
+	ctx context.Context,
+	stanza *pluginv1.Stanza,
+) (bool, error) {
+	contextLogger := log.FromContext(ctx)
+	repositories := config.RepositoryIndexes(stanza)
+	if len(repositories) == 0 {
+		return true, nil
+	}
+
+	var job batchv1.Job
+	key := client.ObjectKey{Namespace: stanza.Namespace, Name: stanzaDeleteName(stanza)}
+	err := r.Client.Get(ctx, key, &job)
+	switch {
+	case apierrs.IsNotFound(err):
+		var jobConf pluginv1.StanzaDeletionJob
+		if stanza.Spec.DeletionJob != nil {
+			jobConf = *stanza.Spec.DeletionJob
+		}
+		if jobConf.ServiceAccountName == "" && usesIdentity(stanza) {
+			return false, r.setDeletionCondition(ctx, stanza, "ServiceAccountRequired",
+				"a repository is accessed with a web identity or a workload identity: set the "+
+					"deletionJob.serviceAccountName allowed to assume it, or set the deletionPolicy "+
+					"to retain to keep the data of the repositories")
+		}
+		var pc pluginv1.PluginConfig
+		if jobConf.PluginConfigRef != "" {
+			pcKey := client.ObjectKey{Namespace: stanza.Namespace, Name: jobConf.PluginConfigRef}
+			err := r.Client.Get(ctx, pcKey, &pc)
+			if apierrs.IsNotFound(err) {
+				return false, r.setDeletionCondition(ctx, stanza, "DeletionFailed",
+					fmt.Sprintf("the PluginConfig %s doesn't exist", pcKey.Name))
+			}
+			if err != nil {
+				return false, err
+			}
+		}
+		env, err := config.GetEnvVarConfig(ctx, stanza, r.Client)
+		if err != nil {
+			return false, r.setDeletionCondition(ctx, stanza, "DeletionFailed",
+				fmt.Sprintf("can't configure pgBackRest: %s", err))
+		}
+		secret, newJob, err := buildStanzaDeleteJob(stanza, &jobConf, &pc.Spec, key.Name, env, repositories)
+		if err != nil {
+			return false, r.setDeletionCondition(ctx, stanza, "DeletionFailed",
+				fmt.Sprintf("can't build the deletion job: %s", err))
+		}
+		if err := controllerutil.SetControllerReference(stanza, secret, r.Scheme); err != nil {
+			return false, err
+		}
+		if err := controllerutil.SetControllerReference(stanza, newJob, r.Scheme); err != nil {
+			return false, err
+		}
+		if err := r.Client.Create(ctx, secret); err != nil && !apierrs.IsAlreadyExists(err) {
+			return false, err
+		}
+		contextLogger.Info("deleting the stanza from the repositories",
+			"job", newJob.Name, "repositories", repositories)
+		if err := r.Client.Create(ctx, newJob); err != nil {
+			return false, err
+		}
+		return false, r.setDeletionCondition(ctx, stanza, "DeletingRepositories",
+			fmt.Sprintf("the job %s is deleting the stanza from the repositories", newJob.Name))
+	case err != nil:
+		return false, err
+	}
+
+	for _, cond := range job.Status.Conditions {
+		if cond.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch cond.Type {
+		case batchv1.JobComplete:
+			return true, nil
+		case batchv1.JobFailed:
+			return false, r.setDeletionCondition(ctx, stanza, "DeletionFailed",
+				fmt.Sprintf("the job %s failed, see its logs, or set the deletionPolicy to retain "+
+					"to keep the data of the repositories", job.Name))
+		}
+	}
+	return false, nil
+}
+
+// usesIdentity reports whether a repository of the stanza is accessed with
+// a web identity or a workload identity, which needs a ServiceAccount allowed
+// to assume it.
+func usesIdentity(stanza *pluginv1.Stanza) bool {
+	stanzas := []*pluginv1.Stanza{stanza}
+	identity, err := s3WebIdentity(stanzas)
+	return identity != nil || err != nil || azureWorkloadIdentity(stanzas)
+}
+
+// buildStanzaDeleteJob builds the Job deleting the stanza from the given
+// repositories, and the Secret holding its pgBackRest environment (with the
+// credentials of the repositories). The container is configured like the
+// sidecars: with the image and the settings of the PluginConfig, and the
+// credentials of the repositories.
+func buildStanzaDeleteJob(
+	stanza *pluginv1.Stanza,
+	jobConf *pluginv1.StanzaDeletionJob,
+	pc *pluginv1.PluginConfigSpec,
+	name string,
+	env []string,
+	repositories []int,
+) (*corev1.Secret, *batchv1.Job, error) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: stanza.Namespace, Name: name},
+		StringData: make(map[string]string, len(env)),
+	}
+	for _, e := range env {
+		if k, v, ok := strings.Cut(e, "="); ok {
+			secret.StringData[k] = v
+		}
+	}
+
+	repos := make([]string, len(repositories))
+	for i, repo := range repositories {
+		repos[i] = strconv.Itoa(repo)
+	}
+	container := corev1.Container{
+		Name:            stanzaDeleteContainer,
+		Image:           pluginImage(pc, false),
+		ImagePullPolicy: pc.ImagePullPolicy,
+		Args:            []string{stanzaDeleteContainer},
+		Env: mergeEnv([]corev1.EnvVar{
+			{Name: "PGBACKREST_STANZA", Value: stanza.Spec.Configuration.Name},
+			{Name: cleanup.RepositoriesEnv, Value: strings.Join(repos, ",")},
+		}, pc.Env),
+		SecurityContext: sidecarSecurityContext(),
+		// pgBackRest keeps its lock and stop files there
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: "scratch-data", MountPath: "/controller"},
+		},
+	}
+	if pc.SecurityContext != nil {
+		container.SecurityContext = pc.SecurityContext.DeepCopy()
+	}
+	spec := corev1.PodSpec{
+		RestartPolicy:                corev1.RestartPolicyNever,
+		AutomountServiceAccountToken: ptr.To(false),
+		ServiceAccountName:           jobConf.ServiceAccountName,
+		ImagePullSecrets:             pc.ImagePullSecrets,
+		Volumes: []corev1.Volume{
+			{Name: "scratch-data", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+		},
+	}
+	if pc.ConfigRendering == pluginv1.ConfigRenderingFile {
+		// the environment is read from the files of the Secret, and rendered
+		// to the configuration files
+		spec.Volumes = append(spec.Volumes, corev1.Volume{
+			Name:         "pgbackrest-env",
+			VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: name}},
+		})
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      "pgbackrest-env",
+			MountPath: stanzaDeleteEnvPath,
+			ReadOnly:  true,
+		})
+		container.Env = append(container.Env, corev1.EnvVar{Name: cleanup.EnvDirectoryEnv, Value: stanzaDeleteEnvPath})
+	} else {
+		container.EnvFrom = append(container.EnvFrom, corev1.EnvFromSource{
+			SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: name}},
+		})
+	}
+	container.EnvFrom = append(container.EnvFrom, pc.EnvFrom...)
+	spec.Containers = []corev1.Container{container}
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Namespace: stanza.Namespace, Name: name},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: ptr.To(int32(2)),
+			Template:     corev1.PodTemplateSpec{Spec: spec},
+		},
+	}
+	if pc.ConfigRendering == pluginv1.ConfigRenderingFile {
+		injectConfigRendering(&job.Spec.Template.Spec, stanzaDeleteContainer)
+	}
+	if err := injectCredentials(
+		&job.Spec.Template.ObjectMeta,
+		&job.Spec.Template.Spec,
+		[]*pluginv1.Stanza{stanza},
+		stanzaDeleteContainer,
+	); err != nil {
+		return nil, nil, err
+	}
+	return secret, job, nil
+}
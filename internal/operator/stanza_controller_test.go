@@ -0,0 +1,273 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+package operator
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	machineryapi "github.com/cloudnative-pg/machinery/pkg/api"
+	pluginv1 "github.com/dalibo/cnpg-i-pgbackrest/api/v1"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/cleanup"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/config"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/metadata"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+func TestStanzaReconciler(t *testing.T) {
+	stanza := func(policy string, deleted bool) *pluginv1.Stanza {
+		s := &pluginv1.Stanza{
+			ObjectMeta: metav1.ObjectMeta{Name: "stanza", Namespace: "default", UID: "stanza-uid"},
+			Spec: pluginv1.StanzaSpec{
+				DeletionPolicy: policy,
+				Configuration: pluginv1.StanzaConfiguration{
+					Name:           "main",
+					S3Repositories: []pluginv1.S3Repository{{Bucket: "bucket", RepoPath: "/repo"}},
+				},
+			},
+		}
+		if deleted {
+			s.Finalizers = []string{metadata.StanzaFinalizerName}
+			s.DeletionTimestamp = ptr.To(metav1.Now())
+		}
+		return s
+	}
+	deleteJob := func(condition batchv1.JobConditionType) *batchv1.Job {
+		return &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "stanza-stanza-delete", Namespace: "default"},
+			Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{
+				{Type: condition, Status: corev1.ConditionTrue},
+			}},
+		}
+	}
+	webIdentity := stanza(pluginv1.DeletionPolicyDelete, true)
+	webIdentity.Spec.Configuration.S3Repositories[0].KeyType = pluginv1.S3KeyTypeWebID
+	webIdentity.Spec.Configuration.S3Repositories[0].WebIdentity = &pluginv1.S3WebIdentity{RoleARN: "arn:aws:iam::1:role/r"}
+	missingConf := stanza(pluginv1.DeletionPolicyDelete, true)
+	missingConf.Spec.DeletionJob = &pluginv1.StanzaDeletionJob{PluginConfigRef: "conf"}
+	testCases := []struct {
+		desc          string
+		stanza        *pluginv1.Stanza
+		objs          []client.Object
+		wantDeleted   bool
+		wantCondition string
+		wantJob       bool
+	}{
+		{
+			desc:   "finalizer added",
+			stanza: stanza(pluginv1.DeletionPolicyRetain, false),
+		},
+		{
+			desc:          "referenced by a cluster",
+			stanza:        stanza(pluginv1.DeletionPolicyRetain, true),
+			objs:          []client.Object{testCluster("cluster", map[string]string{"stanzaRef": "stanza"})},
+			wantCondition: "ReferencedByClusters",
+		},
+		{
+			desc:        "retained",
+			stanza:      stanza(pluginv1.DeletionPolicyRetain, true),
+			wantDeleted: true,
+		},
+		{
+			desc:          "deletion job started",
+			stanza:        stanza(pluginv1.DeletionPolicyDelete, true),
+			wantCondition: "DeletingRepositories",
+			wantJob:       true,
+		},
+		{
+			desc:        "deletion job completed",
+			stanza:      stanza(pluginv1.DeletionPolicyDelete, true),
+			objs:        []client.Object{deleteJob(batchv1.JobComplete)},
+			wantDeleted: true,
+			wantJob:     true,
+		},
+		{
+			desc:          "deletion job failed",
+			stanza:        stanza(pluginv1.DeletionPolicyDelete, true),
+			objs:          []client.Object{deleteJob(batchv1.JobFailed)},
+			wantCondition: "DeletionFailed",
+			wantJob:       true,
+		},
+		{
+			desc:          "service account required",
+			stanza:        webIdentity,
+			wantCondition: "ServiceAccountRequired",
+		},
+		{
+			desc:          "plugin configuration missing",
+			stanza:        missingConf,
+			wantCondition: "DeletionFailed",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			c := newFakeClient(append(tc.objs, tc.stanza)...)
+			runReconcile(t, &StanzaReconciler{Client: c, Scheme: scheme}, tc.stanza)
+
+			got := &pluginv1.Stanza{ObjectMeta: metav1.ObjectMeta{Name: "stanza", Namespace: "default"}}
+			if exists := getObject(t, c, got); exists == tc.wantDeleted {
+				t.Fatalf("want deleted %v, got exists %v", tc.wantDeleted, exists)
+			}
+			if !tc.wantDeleted && !controllerutil.ContainsFinalizer(got, metadata.StanzaFinalizerName) {
+				t.Errorf("expected the finalizer, got %v", got.Finalizers)
+			}
+			reason := ""
+			if cond := apimeta.FindStatusCondition(got.Status.Conditions, stanzaDeletionCondition); cond != nil {
+				reason = cond.Reason
+			}
+			if !tc.wantDeleted && reason != tc.wantCondition {
+				t.Errorf("want condition %q, got %q", tc.wantCondition, reason)
+			}
+			job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "stanza-stanza-delete", Namespace: "default"}}
+			if exists := getObject(t, c, job); exists != tc.wantJob {
+				t.Errorf("want job %v, got %v", tc.wantJob, exists)
+			}
+		})
+	}
+}
+
+func TestBuildStanzaDeleteJob(t *testing.T) {
+	caBundle := &pluginv1.CABundle{
+		ConfigMapKeyRef: &machineryapi.ConfigMapKeySelector{
+			LocalObjectReference: machineryapi.LocalObjectReference{Name: "ca"},
+			Key:                  "ca.crt",
+		},
+	}
+	testCases := []struct {
+		desc       string
+		repository pluginv1.S3Repository
+		azure      []pluginv1.AzureRepository
+		jobConf    pluginv1.StanzaDeletionJob
+		pc         pluginv1.PluginConfigSpec
+		wantImage  string
+		wantEnv    map[string]string
+		wantMounts []string
+		wantLabels map[string]string
+		wantEnvDir bool
+	}{
+		{
+			desc:       "CA bundle",
+			repository: pluginv1.S3Repository{Bucket: "bucket", CABundle: caBundle},
+			wantImage:  sidecarImage(false),
+			wantMounts: []string{"scratch-data", config.CABundleVolumeName(caBundle)},
+		},
+		{
+			desc: "web identity",
+			repository: pluginv1.S3Repository{
+				Bucket:      "bucket",
+				KeyType:     pluginv1.S3KeyTypeWebID,
+				WebIdentity: &pluginv1.S3WebIdentity{RoleARN: "arn:aws:iam::1:role/r", Audience: "sts.amazonaws.com"},
+			},
+			jobConf:    pluginv1.StanzaDeletionJob{ServiceAccountName: "pgbackrest"},
+			wantImage:  sidecarImage(false),
+			wantEnv:    map[string]string{"AWS_ROLE_ARN": "arn:aws:iam::1:role/r"},
+			wantMounts: []string{"scratch-data", awsTokenVolumeName},
+		},
+		{
+			desc:       "workload identity",
+			repository: pluginv1.S3Repository{Bucket: "bucket"},
+			azure: []pluginv1.AzureRepository{
+				{Account: "account", Container: "container", KeyType: pluginv1.AzureKeyTypeWorkloadIdentity},
+			},
+			jobConf:    pluginv1.StanzaDeletionJob{ServiceAccountName: "pgbackrest"},
+			wantImage:  sidecarImage(false),
+			wantMounts: []string{"scratch-data"},
+			wantLabels: map[string]string{azureWorkloadIdentityLabel: "true"},
+		},
+		{
+			desc:       "plugin configuration",
+			repository: pluginv1.S3Repository{Bucket: "bucket"},
+			pc: pluginv1.PluginConfigSpec{
+				Image: "registry.example.com/pgbackrest:1",
+				Env:   []corev1.EnvVar{{Name: "HTTPS_PROXY", Value: "proxy:3128"}},
+			},
+			wantImage:  "registry.example.com/pgbackrest:1",
+			wantEnv:    map[string]string{"HTTPS_PROXY": "proxy:3128"},
+			wantMounts: []string{"scratch-data"},
+		},
+		{
+			desc:       "configuration rendered to files",
+			repository: pluginv1.S3Repository{Bucket: "bucket"},
+			pc:         pluginv1.PluginConfigSpec{ConfigRendering: pluginv1.ConfigRenderingFile},
+			wantImage:  sidecarImage(false),
+			wantEnv:    map[string]string{cleanup.EnvDirectoryEnv: stanzaDeleteEnvPath},
+			wantMounts: []string{"scratch-data", "pgbackrest-env", "pgbackrest-config"},
+			wantEnvDir: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			stanza := &pluginv1.Stanza{
+				ObjectMeta: metav1.ObjectMeta{Name: "stanza", Namespace: "default"},
+				Spec: pluginv1.StanzaSpec{
+					Configuration: pluginv1.StanzaConfiguration{
+						Name:              "main",
+						S3Repositories:    []pluginv1.S3Repository{tc.repository},
+						AzureRepositories: tc.azure,
+					},
+				},
+			}
+			env := []string{"PGBACKREST_STANZA=main", "PGBACKREST_REPO1_S3_BUCKET=bucket"}
+			secret, job, err := buildStanzaDeleteJob(stanza, &tc.jobConf, &tc.pc, "stanza-stanza-delete", env, []int{1, 3})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if secret.StringData["PGBACKREST_STANZA"] != "main" || secret.StringData["PGBACKREST_REPO1_S3_BUCKET"] != "bucket" {
+				t.Errorf("unexpected environment %v", secret.StringData)
+			}
+			spec := job.Spec.Template.Spec
+			if spec.ServiceAccountName != tc.jobConf.ServiceAccountName {
+				t.Errorf("want service account %q, got %q", tc.jobConf.ServiceAccountName, spec.ServiceAccountName)
+			}
+			container := spec.Containers[0]
+			if container.Image != tc.wantImage {
+				t.Errorf("want image %s, got %s", tc.wantImage, container.Image)
+			}
+			containerEnv := envVarSliceToMap(container.Env)
+			if container.Args[0] != "stanza-delete" || containerEnv[cleanup.RepositoriesEnv] != "1,3" {
+				t.Errorf("unexpected container %+v", container)
+			}
+			for name, value := range tc.wantEnv {
+				if containerEnv[name] != value {
+					t.Errorf("want %s=%s, got %v", name, value, containerEnv)
+				}
+			}
+			fromSecret := len(container.EnvFrom) == 1 && container.EnvFrom[0].SecretRef.Name == secret.Name
+			if fromSecret == tc.wantEnvDir {
+				t.Errorf("the environment must be read from the secret variables or files, got %+v", container.EnvFrom)
+			}
+			var mounts []string
+			for _, m := range container.VolumeMounts {
+				mounts = append(mounts, m.Name)
+			}
+			if !slices.Equal(mounts, tc.wantMounts) {
+				t.Errorf("want volumes %v, got %v", tc.wantMounts, mounts)
+			}
+			for name, value := range tc.wantLabels {
+				if job.Spec.Template.Labels[name] != value {
+					t.Errorf("want label %s=%s, got %v", name, value, job.Spec.Template.Labels)
+				}
+			}
+		})
+	}
+}
+
+func TestStanzaDeleteName(t *testing.T) {
+	stanza := &pluginv1.Stanza{ObjectMeta: metav1.ObjectMeta{Name: strings.Repeat("a", 60), UID: "uid"}}
+	if name := stanzaDeleteName(stanza); len(name) > 63 {
+		t.Errorf("name too long: %s", name)
+	}
+	stanza.Name = "short"
+	if name := stanzaDeleteName(stanza); name != "short-stanza-delete" {
+		t.Errorf("unexpected name %s", name)
+	}
+}
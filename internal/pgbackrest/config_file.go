@@ -0,0 +1,240 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package pgbackrest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const envPrefix = "PGBACKREST_"
+
+// secretOptionRegexp matches the options holding credentials, they are
+// written to a dedicated file of the include path.
+var secretOptionRegexp = regexp.MustCompile(
+	`^repo\d+-(s3-key|s3-key-secret|s3-token|s3-sse-customer-key|azure-key|cipher-pass)$`,
+)
+
+// multiValueOptionRegexp matches the options taking several values, given
+// separated by colons in the environment and on several lines in a
+// configuration file.
+var multiValueOptionRegexp = regexp.MustCompile(`^repo\d+-storage-tag$`)
+
+// stanzaOptionRegexp matches the options specific to the PostgreSQL cluster,
+// written in the stanza section.
+var stanzaOptionRegexp = regexp.MustCompile(`^pg\d+-`)
+
+// configDirGracePeriod is how long a rendered configuration directory is
+// kept after its last use: the asynchronous archive processes forked by
+// pgBackRest keep reading it once the command that started them exited.
+var configDirGracePeriod = time.Minute
+
+// configDirs tracks the use of the rendered configuration directories by the
+// commands of this process, so that the ones rendered for previous options
+// or credentials can be removed.
+var configDirs = struct {
+	sync.Mutex
+	inUse    map[string]int
+	lastUsed map[string]time.Time
+}{inUse: make(map[string]int), lastUsed: make(map[string]time.Time)}
+
+// renderedConfig is the location of a rendered pgBackRest configuration
+type renderedConfig struct {
+	ConfigFile  string
+	IncludePath string
+
+	dir         string
+	conf        string
+	credentials string
+}
+
+// envToOption converts a pgBackRest environment variable name to the name of
+// the option, e.g. PGBACKREST_REPO1_S3_KEY to repo1-s3-key.
+func envToOption(name string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(name, envPrefix), "_", "-"))
+}
+
+func renderSection(name string, options map[string]string) string {
+	if len(options) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s]\n", name)
+	for _, k := range slices.Sorted(maps.Keys(options)) {
+		values := []string{options[k]}
+		if multiValueOptionRegexp.MatchString(k) {
+			values = strings.Split(options[k], ":")
+		}
+		for _, v := range values {
+			fmt.Fprintf(&b, "%s=%s\n", k, v)
+		}
+	}
+	return b.String()
+}
+
+// renderConfig writes the pgBackRest options found in env (PGBACKREST_*
+// variables) to a pgbackrest.conf file, with a [global] and a stanza
+// section, and the credentials to conf.d/credentials.conf. Files are written
+// in a sub-directory of dir named after the hash of their content, so
+// runners of different stanzas never share files and a rendered
+// configuration is never modified while in use (e.g. by the asynchronous
+// archive processes). The other sub-directories of dir are removed once no
+// longer used, dir must then only be written by this process. It returns
+// the variables that must stay in the environment: the stanza name and the
+// variables unrelated to pgBackRest.
+func renderConfig(dir string, env []string) (*renderedConfig, []string, error) {
+	var remaining []string
+	var stanza string
+	options := make(map[string]string)
+	for _, e := range env {
+		name, value, _ := strings.Cut(e, "=")
+		if !strings.HasPrefix(name, envPrefix) {
+			remaining = append(remaining, e)
+			continue
+		}
+		if name == envPrefix+"STANZA" {
+			// the stanza can only be given on the command line or the environment
+			stanza = value
+			remaining = append(remaining, e)
+			continue
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, nil, fmt.Errorf("value of %s can't be written in a configuration file", name)
+		}
+		// later variables override the previous ones, as in an environment
+		options[envToOption(name)] = value
+	}
+
+	global := make(map[string]string)
+	stanzaOpts := make(map[string]string)
+	secrets := make(map[string]string)
+	for option, value := range options {
+		switch {
+		case secretOptionRegexp.MatchString(option):
+			secrets[option] = value
+		case stanza != "" && stanzaOptionRegexp.MatchString(option):
+			stanzaOpts[option] = value
+		default:
+			global[option] = value
+		}
+	}
+
+	conf := renderSection("global", global)
+	if s := renderSection(stanza, stanzaOpts); s != "" {
+		conf += "\n" + s
+	}
+	credentials := renderSection("global", secrets)
+
+	sum := sha256.Sum256([]byte(conf + "\x00" + credentials))
+	target := filepath.Join(dir, hex.EncodeToString(sum[:8]))
+	result := &renderedConfig{
+		ConfigFile:  filepath.Join(target, "pgbackrest.conf"),
+		IncludePath: filepath.Join(target, "conf.d"),
+		dir:         target,
+		conf:        conf,
+		credentials: credentials,
+	}
+
+	configDirs.Lock()
+	defer configDirs.Unlock()
+	if err := result.install(); err != nil {
+		return nil, nil, err
+	}
+	configDirs.lastUsed[target] = time.Now()
+	pruneConfigDirs(dir, target)
+	return result, remaining, nil
+}
+
+// install writes the configuration files, unless they are already there: the
+// directory is renamed into place once complete, so that a command never
+// reads a partial configuration.
+func (r *renderedConfig) install() error {
+	if _, err := os.Stat(r.dir); err == nil {
+		return nil
+	}
+	root := filepath.Dir(r.dir)
+	if err := os.MkdirAll(root, 0o700); err != nil {
+		return fmt.Errorf("can't create configuration directory: %w", err)
+	}
+	tmp, err := os.MkdirTemp(root, ".render-")
+	if err != nil {
+		return fmt.Errorf("can't create configuration directory: %w", err)
+	}
+	defer os.RemoveAll(tmp) //nolint:errcheck
+	if err := os.Mkdir(filepath.Join(tmp, "conf.d"), 0o700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(tmp, "pgbackrest.conf"), []byte(r.conf), 0o600); err != nil {
+		return fmt.Errorf("can't write configuration file: %w", err)
+	}
+	if err := os.WriteFile(
+		filepath.Join(tmp, "conf.d", "credentials.conf"),
+		[]byte(r.credentials),
+		0o600,
+	); err != nil {
+		return fmt.Errorf("can't write credentials file: %w", err)
+	}
+	if err := os.Rename(tmp, r.dir); err != nil {
+		return fmt.Errorf("can't install configuration directory: %w", err)
+	}
+	return nil
+}
+
+// acquire marks the configuration as used by a command until the returned
+// function is called, writing it again if it was removed in the meantime.
+func (r *renderedConfig) acquire() (func(), error) {
+	configDirs.Lock()
+	defer configDirs.Unlock()
+	if err := r.install(); err != nil {
+		return nil, err
+	}
+	configDirs.inUse[r.dir]++
+	configDirs.lastUsed[r.dir] = time.Now()
+	return func() {
+		configDirs.Lock()
+		defer configDirs.Unlock()
+		if configDirs.inUse[r.dir]--; configDirs.inUse[r.dir] <= 0 {
+			delete(configDirs.inUse, r.dir)
+		}
+		configDirs.lastUsed[r.dir] = time.Now()
+		pruneConfigDirs(filepath.Dir(r.dir), "")
+	}, nil
+}
+
+// pruneConfigDirs removes the configuration directories of root, but keep,
+// not used by a command for configDirGracePeriod, so that the credentials
+// replaced since don't stay on the volume. The directories left by a
+// previous run of the container are unknown, hence removed too. It must be
+// called with configDirs locked, failures are ignored: the directory is
+// tried again on the next call.
+func pruneConfigDirs(root string, keep string) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	for _, entry := range entries {
+		path := filepath.Join(root, entry.Name())
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || path == keep ||
+			configDirs.inUse[path] > 0 {
+			continue
+		}
+		if lastUsed, ok := configDirs.lastUsed[path]; ok && now.Sub(lastUsed) < configDirGracePeriod {
+			continue
+		}
+		if err := os.RemoveAll(path); err == nil {
+			delete(configDirs.lastUsed, path)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package pgbackrest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRenderConfig(t *testing.T) {
+	dir := t.TempDir()
+	env := []string{
+		"PGBACKREST_PG1_PATH=/var/lib/postgresql/data/pgdata",
+		"PGBACKREST_STANZA=main",
+		"PGBACKREST_REPO1_S3_BUCKET=demo",
+		"PGBACKREST_REPO1_S3_KEY=AKIA123",
+		"PGBACKREST_REPO1_S3_KEY_SECRET=SECRET123",
+		"PGBACKREST_REPO1_CIPHER_PASS=verysecret",
+		"PGBACKREST_REPO1_S3_SSE_CUSTOMER_KEY=c3NlLWM=",
+		"PGBACKREST_REPO1_STORAGE_TAG=env=prod:team=dba",
+		"PGBACKREST_LOG_LEVEL_FILE=off",
+		"PGBACKREST_LOG_LEVEL_FILE=info",
+		"HOME=/controller",
+	}
+	rendered, remaining, err := renderConfig(dir, env)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wantRemaining := []string{"PGBACKREST_STANZA=main", "HOME=/controller"}
+	if !reflect.DeepEqual(remaining, wantRemaining) {
+		t.Errorf("remaining env want %v, got %v", wantRemaining, remaining)
+	}
+
+	testCases := []struct {
+		path string
+		want string
+	}{
+		{
+			rendered.ConfigFile,
+			"[global]\n" +
+				"log-level-file=info\n" +
+				"repo1-s3-bucket=demo\n" +
+				"repo1-storage-tag=env=prod\n" +
+				"repo1-storage-tag=team=dba\n" +
+				"\n" +
+				"[main]\n" +
+				"pg1-path=/var/lib/postgresql/data/pgdata\n",
+		},
+		{
+			filepath.Join(rendered.IncludePath, "credentials.conf"),
+			"[global]\n" +
+				"repo1-cipher-pass=verysecret\n" +
+				"repo1-s3-key=AKIA123\n" +
+				"repo1-s3-key-secret=SECRET123\n" +
+				"repo1-s3-sse-customer-key=c3NlLWM=\n",
+		},
+	}
+	for _, tc := range testCases {
+		content, err := os.ReadFile(tc.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != tc.want {
+			t.Errorf("%s: want\n%s\ngot\n%s", tc.path, tc.want, content)
+		}
+		info, err := os.Stat(tc.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0o600 {
+			t.Errorf("%s: expected 0600 permissions, got %v", tc.path, info.Mode().Perm())
+		}
+	}
+
+	// rendering the same configuration again reuses the files
+	again, _, err := renderConfig(dir, env)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *again != *rendered {
+		t.Errorf("expected the same files, got %v and %v", rendered, again)
+	}
+	// while another configuration gets its own files
+	other, _, err := renderConfig(dir, append(env, "PGBACKREST_REPO1_S3_BUCKET=other"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if other.ConfigFile == rendered.ConfigFile {
+		t.Errorf("different configurations must not share files")
+	}
+}
+
+func TestRenderConfigPrune(t *testing.T) {
+	gracePeriod := configDirGracePeriod
+	configDirGracePeriod = 0
+	t.Cleanup(func() { configDirGracePeriod = gracePeriod })
+	exists := func(path string) bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}
+
+	dir := t.TempDir()
+	// left by a previous run of the container
+	stale := filepath.Join(dir, "0123456789abcdef")
+	if err := os.Mkdir(stale, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	old, _, err := renderConfig(dir, []string{"PGBACKREST_REPO1_S3_KEY=old"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if exists(stale) {
+		t.Errorf("expected the directory of a previous run to be removed")
+	}
+
+	release, err := old.acquire()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	current, _, err := renderConfig(dir, []string{"PGBACKREST_REPO1_S3_KEY=new"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !exists(old.ConfigFile) || !exists(current.ConfigFile) {
+		t.Errorf("expected the configurations in use to be kept")
+	}
+	release()
+	if exists(old.ConfigFile) {
+		t.Errorf("expected the previous configuration to be removed once unused")
+	}
+
+	// a removed configuration is written again when used
+	release, err = old.acquire()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer release()
+	content, err := os.ReadFile(filepath.Join(old.IncludePath, "credentials.conf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "[global]\nrepo1-s3-key=old\n"; string(content) != want {
+		t.Errorf("want credentials %q, got %q", want, content)
+	}
+}
+
+func TestRenderConfigRejectsNewLines(t *testing.T) {
+	if _, _, err := renderConfig(t.TempDir(), []string{"PGBACKREST_REPO1_PATH=/a\n[other]"}); err == nil {
+		t.Errorf("expected an error for a value containing a new line")
+	}
+}
+
+func TestRenderConfigArgs(t *testing.T) {
+	fExec := execCalls{}
+	pgb := newPgBackrestWithRunner([]string{"PGBACKREST_STANZA=main"}, fExec.fakeCmdRunner("", nil))
+	if err := pgb.RenderConfig(t.TempDir()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := <-pgb.PushWal(context.Background(), "/machin"); err != nil {
+		t.Fatalf("can't simulate push WAL, %v", err)
+	}
+	args := fExec.execCalls[0].args
+	if len(args) != 4 ||
+		!strings.HasPrefix(args[0], "--config=") ||
+		!strings.HasPrefix(args[1], "--config-include-path=") ||
+		args[2] != "archive-push" {
+		t.Errorf("unexpected arguments %v", args)
+	}
+}
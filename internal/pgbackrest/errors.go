@@ -0,0 +1,114 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package pgbackrest
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrWALNotFound is returned by archive-get when the requested segment
+	// is not in any repository, which is the expected end of the archive.
+	ErrWALNotFound = errors.New("WAL segment not found in the repositories")
+	// ErrRepoUnreachable is returned when no repository can be reached
+	// (network, TLS, credentials or object storage service errors).
+	ErrRepoUnreachable = errors.New("repository unreachable")
+	// ErrStanzaMismatch is returned when the repository content does not
+	// match the PostgreSQL cluster (system identifier or version).
+	ErrStanzaMismatch = errors.New("stanza does not match the PostgreSQL cluster")
+	// ErrLockContention is returned when another pgBackRest process already
+	// holds the lock required by the command.
+	ErrLockContention = errors.New("lock held by another pgBackRest process")
+)
+
+// pgBackRest error codes, see src/build/error/error.yaml in the pgBackRest
+// sources. The exit code of a failing command is the code of its error.
+const (
+	errCodeProtocol        = 39
+	errCodeArchiveMismatch = 44
+	errCodeHostConnect     = 49
+	errCodeLockAcquire     = 50
+	errCodeBackupMismatch  = 51
+	errCodeDbMismatch      = 58
+	errCodeRepoInvalid     = 103
+)
+
+// errorLineRegexp matches pgBackRest error messages, e.g.
+// "P00  ERROR: [044]: WAL segment system-id ... do not match ..."
+var errorLineRegexp = regexp.MustCompile(`ERROR: \[(\d+)\]: (.*)$`)
+
+// CommandError is returned when a pgBackRest command fails. It wraps one of
+// the typed errors above when the failure is a known one, so callers can
+// rely on errors.Is.
+type CommandError struct {
+	Command  string
+	ExitCode int
+	// Code is the pgBackRest error code, 0 when no error was reported
+	Code    int
+	Message string
+	Err     error
+
+	kind error
+}
+
+func (e *CommandError) Error() string {
+	msg := fmt.Sprintf("pgbackrest %s failed: %v", e.Command, e.Err)
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+func (e *CommandError) Unwrap() []error {
+	if e.kind == nil {
+		return []error{e.Err}
+	}
+	return []error{e.kind, e.Err}
+}
+
+// newCommandError builds the error of a failed command from the error
+// returned by the process and its (stderr or combined) output.
+func newCommandError(args []string, err error, output string) *CommandError {
+	cmdErr := &CommandError{Err: err}
+	if len(args) > 0 {
+		cmdErr.Command = args[0]
+	}
+	var exitErr interface{ ExitCode() int }
+	if errors.As(err, &exitErr) {
+		cmdErr.ExitCode = exitErr.ExitCode()
+	}
+	for _, line := range strings.Split(output, "\n") {
+		if m := errorLineRegexp.FindStringSubmatch(line); m != nil {
+			cmdErr.Code, _ = strconv.Atoi(m[1])
+			cmdErr.Message = m[2]
+		}
+	}
+	cmdErr.kind = classifyError(cmdErr.Command, cmdErr.ExitCode, cmdErr.Code)
+	return cmdErr
+}
+
+func classifyError(command string, exitCode int, code int) error {
+	if code == 0 {
+		// archive-get exits with 1 without reporting any error when the
+		// segment is missing
+		if command == "archive-get" && exitCode == 1 {
+			return ErrWALNotFound
+		}
+		code = exitCode
+	}
+	switch code {
+	case errCodeProtocol, errCodeHostConnect, errCodeRepoInvalid:
+		return ErrRepoUnreachable
+	case errCodeArchiveMismatch, errCodeBackupMismatch, errCodeDbMismatch:
+		return ErrStanzaMismatch
+	case errCodeLockAcquire:
+		return ErrLockContention
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package pgbackrest
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type fakeExitError struct {
+	code int
+}
+
+func (e fakeExitError) Error() string { return "exit status" }
+func (e fakeExitError) ExitCode() int { return e.code }
+
+func TestNewCommandError(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		args     []string
+		exitCode int
+		output   string
+		want     error
+		wantCode int
+	}{
+		{
+			desc:     "missing WAL",
+			args:     []string{"archive-get", "000000010000000000000003", "/tmp/x"},
+			exitCode: 1,
+			want:     ErrWALNotFound,
+		},
+		{
+			desc:     "unreachable repository",
+			args:     []string{"archive-get", "000000010000000000000003", "/tmp/x"},
+			exitCode: 103,
+			output:   "P00  ERROR: [103]: unable to find a valid repository:\n",
+			want:     ErrRepoUnreachable,
+			wantCode: 103,
+		},
+		{
+			desc:     "S3 request failure",
+			args:     []string{"backup"},
+			exitCode: 39,
+			output:   "P00  ERROR: [039]: HTTP request failed with 403 (Forbidden)\n",
+			want:     ErrRepoUnreachable,
+			wantCode: 39,
+		},
+		{
+			desc:     "stanza mismatch",
+			args:     []string{"archive-push", "pg_wal/000000010000000000000003"},
+			exitCode: 44,
+			output:   "P00  ERROR: [044]: WAL segment system-id 7 do not match stanza system-id 8\n",
+			want:     ErrStanzaMismatch,
+			wantCode: 44,
+		},
+		{
+			desc:     "lock contention without message",
+			args:     []string{"backup"},
+			exitCode: 50,
+			want:     ErrLockContention,
+		},
+		{
+			desc:     "unknown error",
+			args:     []string{"backup"},
+			exitCode: 32,
+			output:   "P00  ERROR: [032]: invalid value\n",
+			wantCode: 32,
+		},
+	}
+	sentinels := []error{ErrWALNotFound, ErrRepoUnreachable, ErrStanzaMismatch, ErrLockContention}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := newCommandError(tc.args, fakeExitError{tc.exitCode}, tc.output)
+			for _, s := range sentinels {
+				if got := errors.Is(err, s); got != (s == tc.want) {
+					t.Errorf("errors.Is(err, %q) = %v", s, got)
+				}
+			}
+			if err.Code != tc.wantCode {
+				t.Errorf("error code want %d, got %d", tc.wantCode, err.Code)
+			}
+			if err.ExitCode != tc.exitCode {
+				t.Errorf("exit code want %d, got %d", tc.exitCode, err.ExitCode)
+			}
+		})
+	}
+}
+
+func TestGetWALNotFound(t *testing.T) {
+	fExec := execCalls{}
+	// the fake runner runs false, which exits with 1 like archive-get does
+	// when the segment can't be found
+	pgb := newPgBackrestWithRunner(nil, fExec.fakeCmdRunner("", errors.New("failure")))
+	err := <-pgb.GetWAL(context.Background(), "000000010000000000000003", "/tmp/x")
+	if !errors.Is(err, ErrWALNotFound) {
+		t.Errorf("expected ErrWALNotFound, got %v", err)
+	}
+}
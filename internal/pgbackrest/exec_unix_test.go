@@ -0,0 +1,30 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build unix
+
+package pgbackrest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestExecuteKillsProcessGroup(t *testing.T) {
+	// the shell spawns a child which inherits the outputs: without the
+	// process group kill, we would wait for it
+	runner := newBaseRunner("sh", nil)
+	runner.SetTimeout("-c", 100*time.Millisecond)
+
+	start := time.Now()
+	err := runner.execute(context.Background(), []string{"-c", "sleep 30 & sleep 30"}, nil, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("process group not killed, command lasted %v", elapsed)
+	}
+}
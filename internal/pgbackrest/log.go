@@ -0,0 +1,104 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package pgbackrest
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/cloudnative-pg/machinery/pkg/log"
+)
+
+// logLineRegexp matches the lines printed by pgBackRest on the console, e.g.
+// "2025-03-06 10:00:00.000 P00   INFO: archive-push command end: completed successfully"
+var logLineRegexp = regexp.MustCompile(
+	`^(?:\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}\.\d{3} )?(P\d+)\s+(ERROR|WARN|INFO|DETAIL|DEBUG|TRACE):\s+(.*)$`,
+)
+
+// logRecord is a parsed pgBackRest log line
+type logRecord struct {
+	Process string
+	Level   string
+	Message string
+}
+
+func parseLogLine(line string) (logRecord, bool) {
+	m := logLineRegexp.FindStringSubmatch(line)
+	if m == nil {
+		return logRecord{}, false
+	}
+	return logRecord{Process: m[1], Level: m[2], Message: m[3]}, true
+}
+
+// logWriter forwards the lines written by pgBackRest to the logger, at the
+// level of the pgBackRest message. Error lines are kept to build the error
+// returned when the command fails.
+type logWriter struct {
+	logger log.Logger
+	buf    bytes.Buffer
+	errors strings.Builder
+	last   string
+}
+
+func newLogWriter(logger log.Logger) *logWriter {
+	return &logWriter{logger: logger}
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	for {
+		i := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := string(w.buf.Next(i + 1))
+		w.logLine(strings.TrimRight(line, "\r\n"))
+	}
+}
+
+// Flush logs the last line when it is not terminated by a new line.
+func (w *logWriter) Flush() {
+	if w.buf.Len() > 0 {
+		w.logLine(w.buf.String())
+		w.buf.Reset()
+	}
+}
+
+// Last returns the last line written
+func (w *logWriter) Last() string {
+	return w.last
+}
+
+// Errors returns the error lines, separated by new lines
+func (w *logWriter) Errors() string {
+	return w.errors.String()
+}
+
+func (w *logWriter) logLine(line string) {
+	if line == "" {
+		return
+	}
+	w.last = line
+	rec, ok := parseLogLine(line)
+	if !ok {
+		w.logger.Info(line)
+		return
+	}
+	switch rec.Level {
+	case "ERROR":
+		w.errors.WriteString(line + "\n")
+		w.logger.Error(errors.New(rec.Message), "pgbackrest error", "process", rec.Process)
+	case "WARN":
+		w.logger.Warning(rec.Message, "process", rec.Process)
+	case "INFO":
+		w.logger.Info(rec.Message, "process", rec.Process)
+	case "DETAIL", "DEBUG":
+		w.logger.Debug(rec.Message, "process", rec.Process)
+	default:
+		w.logger.Trace(rec.Message, "process", rec.Process)
+	}
+}
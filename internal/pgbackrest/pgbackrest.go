@@ -5,19 +5,12 @@
 package pgbackrest
 
 import (
-	"bufio"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"io"
-	"os"
-	"os/exec"
-	"sync"
 
 	pgbackrestapi "github.com/dalibo/cnpg-i-pgbackrest/api/v1"
 	"github.com/dalibo/cnpg-i-pgbackrest/internal/utils"
-	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 type RestoreOptions struct {
@@ -41,41 +34,21 @@ type RepoStatus struct {
 }
 
 type Repo struct {
+	Key    int        `json:"key"`
 	Status RepoStatus `json:"status"`
 }
 
-type PgBackRestInfo struct {
-	Repo []Repo `json:"repo"`
+// DBInfo is a PostgreSQL cluster the stanza was created or upgraded for
+type DBInfo struct {
+	ID       int    `json:"id"`
+	RepoKey  int    `json:"repo-key"`
+	SystemID uint64 `json:"system-id"`
+	Version  string `json:"version"`
 }
 
-type CommandExecutor interface {
-	CombinedOutput() ([]byte, error)
-	Kill() error
-	Run() error
-	Start() error
-	StderrPipe() (io.ReadCloser, error)
-	StdoutPipe() (io.ReadCloser, error)
-	Wait() error
-	SetEnv(env []string)
-}
-type ExecCmd struct {
-	*exec.Cmd
-}
-
-func (e *ExecCmd) Kill() error {
-	if e.Process == nil {
-		return fmt.Errorf("process does not exist")
-	}
-	return e.Process.Kill()
-}
-func (e *ExecCmd) SetEnv(env []string) {
-	e.Env = env
-}
-
-type baseRunner struct {
-	command   string
-	cmdRunner func(args ...string) CommandExecutor
-	baseEnv   []string
+type PgBackRestInfo struct {
+	DB   []DBInfo `json:"db"`
+	Repo []Repo   `json:"repo"`
 }
 
 type PgBackrestRunner struct {
@@ -83,95 +56,29 @@ type PgBackrestRunner struct {
 }
 
 func NewPgBackrest(env []string) *PgBackrestRunner {
-	command := "pgbackrest"
-	return &PgBackrestRunner{
-		baseRunner: baseRunner{
-			command: command,
-			cmdRunner: func(args ...string) CommandExecutor {
-				return &ExecCmd{exec.Command(command, args...)}
-			},
-			baseEnv: env,
-		},
-	}
-}
-
-func (p *baseRunner) run(args []string, extraEnv []string) CommandExecutor {
-	cmd := p.cmdRunner(args...)
-	cmd.SetEnv(append(os.Environ(), append(p.baseEnv, extraEnv...)...))
-	return cmd
+	return &PgBackrestRunner{baseRunner: newBaseRunner("pgbackrest", env)}
 }
 
-func (p *PgBackrestRunner) runBackgroundTask(
-	ctx context.Context,
-	args []string,
-	extraEnv []string,
-) <-chan error {
-	result := make(chan error, 1)
-	logger := log.FromContext(ctx)
-	cmd := p.run(args, extraEnv)
-	go func() {
-		defer close(result)
-
-		stdoutPipe, _ := cmd.StdoutPipe()
-		stderrPipe, _ := cmd.StderrPipe()
-
-		if err := cmd.Start(); err != nil {
-			logger.Error(err, "can't start pgbackrest task", "args", args)
-			result <- err
-			return
-		}
-
-		// Read stdout & stderr concurrently
-		var wg sync.WaitGroup
-		var errOut error
-
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			scanner := bufio.NewScanner(stdoutPipe)
-			for scanner.Scan() {
-				l := scanner.Text()
-				logger.Info(l)
-			}
-		}()
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			scanner := bufio.NewScanner(stderrPipe)
-			for scanner.Scan() {
-				l := scanner.Text()
-				errOut = errors.New(l)
-				logger.Error(errOut, "error from pgbackrest")
-			}
-		}()
-
-		// Wait for read completion
-		wg.Wait()
-
-		done := make(chan error, 1)
-		go func() { done <- cmd.Wait() }()
-
-		select {
-		case err := <-done:
-			if err != nil || errOut != nil {
-				logger.Error(err, "command", p.command, "failed with", "args", args)
-				result <- err
-				return
-			}
-
-		case <-ctx.Done():
-			_ = cmd.Kill() // ensure subprocess tree is killed
-			result <- ctx.Err()
-		}
-
-	}()
-
-	return result
+// RenderConfig writes the pgBackRest options of the runner environment to
+// configuration files in dir, pgBackRest is then pointed to them with the
+// --config and --config-include-path options. Only the stanza name is kept
+// in the environment, credentials are no longer exposed there.
+func (p *PgBackrestRunner) RenderConfig(dir string) error {
+	rendered, env, err := renderConfig(dir, p.baseEnv)
+	if err != nil {
+		return err
+	}
+	p.baseEnv = env
+	p.rendered = rendered
+	p.baseArgs = []string{
+		"--config=" + rendered.ConfigFile,
+		"--config-include-path=" + rendered.IncludePath,
+	}
+	return nil
 }
 
-func (p *PgBackrestRunner) RepositoriesConfigured() (bool, error) {
-	cmd := p.run([]string{"info", "--output=json"}, nil)
-	stdout, err := cmd.CombinedOutput()
+func (p *PgBackrestRunner) RepositoriesConfigured(ctx context.Context) (bool, error) {
+	stdout, err := p.output(ctx, []string{"info", "--output=json"}, nil)
 	if err != nil {
 		return false, fmt.Errorf("can't execute pgbackrest info command: %w", err)
 	}
@@ -199,20 +106,66 @@ func allReposHaveZeroStatusCode(pgbackrestInfo []PgBackRestInfo) bool {
 	return true
 }
 
-func (p *PgBackrestRunner) EnsureStanzaExists(stanza string) (bool, error) {
-	repoConfigured, err := p.RepositoriesConfigured()
-	if err != nil {
-		return false, fmt.Errorf("can't determine if stanza exists, error %w", err)
+// CreateStanza creates the stanza on a single repository. pgBackRest leaves
+// a repository where the stanza already exists untouched.
+func (p *PgBackrestRunner) CreateStanza(ctx context.Context, stanza string, repo int) error {
+	env := []string{fmt.Sprintf("PGBACKREST_REPO=%d", repo)}
+	if err := p.execute(ctx, []string{"stanza-create", "--stanza=" + stanza}, env, nil); err != nil {
+		return fmt.Errorf("can't create stanza on repository %d: %w", repo, err)
 	}
-	if repoConfigured {
-		return false, nil
+	return nil
+}
+
+// StopStanza prevents new pgBackRest commands from running on the stanza,
+// it is required before deleting it.
+func (p *PgBackrestRunner) StopStanza(ctx context.Context, stanza string) error {
+	if err := p.execute(ctx, []string{"stanza-stop", "--stanza=" + stanza}, nil, nil); err != nil {
+		return fmt.Errorf("can't stop stanza: %w", err)
 	}
-	cmd := p.run([]string{"stanza-create", "--stanza=" + stanza}, nil)
-	output, err := cmd.CombinedOutput()
+	return nil
+}
+
+// DeleteStanza deletes the stanza, with its backups and WAL, from a single
+// repository.
+func (p *PgBackrestRunner) DeleteStanza(ctx context.Context, stanza string, repo int) error {
+	env := []string{fmt.Sprintf("PGBACKREST_REPO=%d", repo)}
+	if err := p.execute(ctx, []string{"stanza-delete", "--stanza=" + stanza}, env, nil); err != nil {
+		return fmt.Errorf("can't delete stanza from repository %d: %w", repo, err)
+	}
+	return nil
+}
+
+// StanzaDatabase returns the PostgreSQL cluster the stanza currently backs up
+// on the repository.
+func (p *PgBackrestRunner) StanzaDatabase(ctx context.Context, repo int) (*DBInfo, error) {
+	env := []string{fmt.Sprintf("PGBACKREST_REPO=%d", repo)}
+	stdout, err := p.output(ctx, []string{"info", "--output=json"}, env)
 	if err != nil {
-		return false, fmt.Errorf("can't create stanza, stdout: %s, error : %w", string(output), err)
+		return nil, fmt.Errorf("can't execute pgbackrest info command: %w", err)
+	}
+	var info []PgBackRestInfo
+	if err := json.Unmarshal(stdout, &info); err != nil {
+		return nil, fmt.Errorf("can't parse pgbackrest JSON: %w", err)
+	}
+	db := currentDatabase(info, repo)
+	if db == nil {
+		return nil, fmt.Errorf("no database found for the stanza on repository %d", repo)
 	}
-	return true, nil
+	return db, nil
+}
+
+// currentDatabase returns the last database recorded on the repository, the
+// previous ones were upgraded.
+func currentDatabase(info []PgBackRestInfo, repo int) *DBInfo {
+	var found *DBInfo
+	for _, entry := range info {
+		for i, db := range entry.DB {
+			if db.RepoKey == repo && (found == nil || db.ID > found.ID) {
+				found = &entry.DB[i]
+			}
+		}
+	}
+	return found
 }
 
 func (p *PgBackrestRunner) PushWal(ctx context.Context, walName string) <-chan error {
@@ -226,36 +179,50 @@ func (p *PgBackrestRunner) GetWAL(
 	return p.runBackgroundTask(ctx, []string{"archive-get", walName, dstPath}, nil)
 }
 
-func (p *PgBackrestRunner) Backup(backupType string) error {
-	env := make([]string, 1)
-	env = append(env, "PGBACKREST_ARCHIVE_CHECK=n")
+// Backup runs a backup of the given type (the default type of pgBackRest when
+// empty). The extra variables only apply to this command.
+func (p *PgBackrestRunner) Backup(ctx context.Context, backupType string, extraEnv ...string) error {
+	env := append([]string{"PGBACKREST_ARCHIVE_CHECK=n"}, extraEnv...)
 	if backupType != "" {
 		if backupType != "full" && backupType != "diff" && backupType != "incr" {
 			return fmt.Errorf("invalid backup type %q: must be one of full, diff, incr", backupType)
 		}
 		env = append(env, "PGBACKREST_TYPE="+backupType)
 	}
-	args := []string{"backup"}
-	cmd := p.run(args, env)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("can't backup: %s, error : %w", string(output), err)
+	if err := p.execute(ctx, []string{"backup"}, env, nil); err != nil {
+		return fmt.Errorf("can't backup: %w", err)
 	}
 	return nil
 }
 
-func (p *PgBackrestRunner) GetBackupInfo() ([]pgbackrestapi.BackupInfo, error) {
-	cmd := p.run([]string{"info", "--output", "json"}, nil)
-	output, err := cmd.CombinedOutput()
+func (p *PgBackrestRunner) GetBackupInfo(ctx context.Context) ([]pgbackrestapi.BackupInfo, error) {
+	return p.backupInfo(ctx, nil)
+}
+
+// GetRepositoryBackupInfo lists the backups of a single repository.
+func (p *PgBackrestRunner) GetRepositoryBackupInfo(
+	ctx context.Context,
+	repo int,
+) ([]pgbackrestapi.BackupInfo, error) {
+	return p.backupInfo(ctx, []string{fmt.Sprintf("PGBACKREST_REPO=%d", repo)})
+}
+
+func (p *PgBackrestRunner) backupInfo(
+	ctx context.Context,
+	extraEnv []string,
+) ([]pgbackrestapi.BackupInfo, error) {
+	output, err := p.output(ctx, []string{"info", "--output", "json"}, extraEnv)
 	if err != nil {
-		return nil, fmt.Errorf("can't get pgbackrest info: %s, %w", string(output), err)
+		return nil, fmt.Errorf("can't get pgbackrest info: %w", err)
 	}
 	var pgbackrestInfo []BackupData
 	if err := json.Unmarshal(output, &pgbackrestInfo); err != nil {
 		return nil, err
 	}
+	if len(pgbackrestInfo) == 0 {
+		return nil, nil
+	}
 	return pgbackrestInfo[0].Backup, nil
-
 }
 
 func CountByType(backups []pgbackrestapi.BackupInfo) map[string]uint16 {
@@ -292,7 +259,16 @@ func FirstBackup(backups []pgbackrestapi.BackupInfo) *pgbackrestapi.BackupInfo {
 	return &found
 }
 
-func (p *PgBackrestRunner) Restore(ctx context.Context) <-chan error {
-	env := []string{"PGBACKREST_ARCHIVE_CHECK=n"}
+// Restore restores a backup in an empty PGDATA, the extra variables (restore
+// target, recovery options...) only apply to this command.
+func (p *PgBackrestRunner) Restore(ctx context.Context, extraEnv ...string) <-chan error {
+	env := append([]string{"PGBACKREST_ARCHIVE_CHECK=n"}, extraEnv...)
 	return p.runBackgroundTask(ctx, []string{"restore"}, env)
 }
+
+// DeltaRestore restores a backup on top of an existing PGDATA and configures
+// it as a standby. Thanks to the delta option, only the files whose checksum
+// differs from the backup are copied.
+func (p *PgBackrestRunner) DeltaRestore(ctx context.Context, extraEnv ...string) <-chan error {
+	return p.runBackgroundTask(ctx, []string{"restore", "--delta", "--type=standby"}, extraEnv)
+}
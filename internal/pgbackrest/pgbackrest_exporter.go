@@ -6,10 +6,9 @@ package pgbackrest
 
 import (
 	"context"
-	"os/exec"
-	"time"
+	"fmt"
 
-	"sigs.k8s.io/controller-runtime/pkg/log"
+	"github.com/cloudnative-pg/machinery/pkg/log"
 )
 
 type PgBackrestExporterRunner struct {
@@ -17,36 +16,67 @@ type PgBackrestExporterRunner struct {
 }
 
 func NewPgBackrestExporterRunner(env []string) *PgBackrestExporterRunner {
-	command := "pgbackrest_exporter"
-	return &PgBackrestExporterRunner{
-		baseRunner: baseRunner{
-			command: command,
-			cmdRunner: func(args ...string) CommandExecutor {
-				return &ExecCmd{exec.Command(command, args...)}
-			},
-			baseEnv: env,
-		},
+	return &PgBackrestExporterRunner{baseRunner: newBaseRunner("pgbackrest_exporter", env)}
+}
+
+// RenderConfig writes the pgBackRest options of the exporter environment to
+// configuration files in dir, and points the exporter to them.
+func (p *PgBackrestExporterRunner) RenderConfig(dir string) error {
+	rendered, env, err := renderConfig(dir, p.baseEnv)
+	if err != nil {
+		return err
 	}
+	p.baseEnv = env
+	p.rendered = rendered
+	p.baseArgs = []string{
+		"--backrest.config=" + rendered.ConfigFile,
+		"--backrest.config-include-path=" + rendered.IncludePath,
+	}
+	return nil
 }
 
+// RunExporter runs the exporter until it exits, or until the context is
+// cancelled: it's then killed and nil is returned. The lines printed by the
+// exporter are forwarded to the logger, the error returned when it exits
+// gives its last one.
 func (p *PgBackrestExporterRunner) RunExporter(ctx context.Context, args []string) error {
-	logger := log.FromContext(ctx)
-	logger.Info("launching pgbackrest exporter with", "args", args)
-	cmd := p.run(args, []string{})
+	logger := log.FromContext(ctx).WithValues("command", p.command)
+	logger.Info("launching pgbackrest exporter", "args", args)
+
+	if p.rendered != nil {
+		release, err := p.rendered.acquire()
+		if err != nil {
+			return fmt.Errorf("can't render pgbackrest exporter configuration: %w", err)
+		}
+		defer release()
+	}
+
+	stdoutLog := newLogWriter(logger)
+	stderrLog := newLogWriter(logger)
+	cmd := p.run(args, nil)
+	cmd.SetOutput(stdoutLog, stderrLog)
 	if err := cmd.Start(); err != nil {
-		return err
+		return fmt.Errorf("can't start pgbackrest exporter: %w", err)
 	}
-	ticker := time.NewTicker(1 * time.Minute)
-	defer ticker.Stop()
-	for {
-		select {
-		case <-ctx.Done():
-			logger.Info("killing pgbackrest exporter")
-			return cmd.Kill()
-		case <-ticker.C:
-			// TODO: Add some logic to check if the exporter is really running ?
-			logger.Info("pgbackrest exporter running")
-			continue
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	select {
+	case err := <-done:
+		stdoutLog.Flush()
+		stderrLog.Flush()
+		if err == nil {
+			err = fmt.Errorf("exited")
+		}
+		return fmt.Errorf("pgbackrest exporter stopped: %w (last output: %q)", err, stderrLog.Last())
+	case <-ctx.Done():
+		logger.Info("killing pgbackrest exporter", "reason", context.Cause(ctx))
+		if err := cmd.Kill(); err != nil {
+			logger.Error(err, "can't kill pgbackrest exporter")
 		}
+		<-done
+		stdoutLog.Flush()
+		stderrLog.Flush()
+		return nil
 	}
 }
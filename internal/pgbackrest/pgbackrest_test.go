@@ -166,6 +166,34 @@ func TestAllReposHaveZeroStatusCode(t *testing.T) {
 	}
 }
 
+func TestCurrentDatabase(t *testing.T) {
+	data := `[{"name":"main","db":[
+		{"id":1,"repo-key":1,"system-id":7480000000000000001,"version":"16"},
+		{"id":2,"repo-key":1,"system-id":7480000000000000002,"version":"17"},
+		{"id":1,"repo-key":2,"system-id":7480000000000000002,"version":"17"}
+	],"repo":[{"key":1,"status":{"code":0,"message":"ok"}},{"key":2,"status":{"code":0,"message":"ok"}}]}]`
+	var info []PgBackRestInfo
+	if err := json.Unmarshal([]byte(data), &info); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	testCases := []struct {
+		repo int
+		want *DBInfo
+	}{
+		{1, &DBInfo{ID: 2, RepoKey: 1, SystemID: 7480000000000000002, Version: "17"}},
+		{2, &DBInfo{ID: 1, RepoKey: 2, SystemID: 7480000000000000002, Version: "17"}},
+		{3, nil},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("repo%d", tc.repo), func(t *testing.T) {
+			got := currentDatabase(info, tc.repo)
+			if (got == nil) != (tc.want == nil) || (got != nil && *got != *tc.want) {
+				t.Errorf("want %+v, got %+v", tc.want, got)
+			}
+		})
+	}
+}
+
 type fakeExec struct {
 	cmdName string
 	args    []string
@@ -241,7 +269,7 @@ func TestBackup(t *testing.T) {
 		fExec := execCalls{}
 		t.Run(tc.desc, func(t *testing.T) {
 			pgb := newPgBackrestWithRunner(nil, fExec.fakeCmdRunner(backup, nil))
-			pgb.Backup("") //nolint:errcheck
+			pgb.Backup(context.Background(), "") //nolint:errcheck
 			if !reflect.DeepEqual(fExec, tc.want) {
 				t.Errorf("error want %v, got %v", fExec, tc.want)
 			}
@@ -249,35 +277,62 @@ func TestBackup(t *testing.T) {
 	}
 }
 
+func TestDeltaRestore(t *testing.T) {
+	fExec := execCalls{}
+	want := execCalls{
+		execCalls: []fakeExec{
+			{cmdName: "pgbackrest", args: []string{"restore", "--delta", "--type=standby"}},
+		},
+	}
+	pgb := newPgBackrestWithRunner(nil, fExec.fakeCmdRunner("", nil))
+	if err := <-pgb.DeltaRestore(context.Background()); err != nil {
+		t.Fatalf("can't simulate delta restore, %v", err)
+	}
+	if !reflect.DeepEqual(fExec, want) {
+		t.Errorf("error want %v, got %v", want, fExec)
+	}
+}
+
 // MockCommandExecutor implements CommandExecutor for testing
 type MockCommandExecutor struct {
-	stdout         io.ReadCloser
-	stderr         io.ReadCloser
-	startErr       error
-	waitErr        error
-	killed         bool
-	env            []string
-	mu             sync.Mutex
-	combinedOutput []byte
-	combinedErr    error
+	stdout    io.Reader
+	stderr    io.Reader
+	stdoutDst io.Writer
+	stderrDst io.Writer
+	startErr  error
+	waitErr   error
+	// hang makes Wait block until the command is killed
+	hang   chan struct{}
+	killed bool
+	env    []string
+	mu     sync.Mutex
 }
 
-func (m *MockCommandExecutor) Run() error                         { return nil }
-func (m *MockCommandExecutor) Start() error                       { return m.startErr }
-func (m *MockCommandExecutor) StdoutPipe() (io.ReadCloser, error) { return m.stdout, nil }
-func (m *MockCommandExecutor) StderrPipe() (io.ReadCloser, error) { return m.stderr, nil }
-func (m *MockCommandExecutor) Wait() error                        { return m.waitErr }
+func (m *MockCommandExecutor) Start() error { return m.startErr }
+func (m *MockCommandExecutor) Wait() error {
+	if m.hang != nil {
+		<-m.hang
+		return fmt.Errorf("signal: killed")
+	}
+	_, _ = io.Copy(m.stdoutDst, m.stdout)
+	_, _ = io.Copy(m.stderrDst, m.stderr)
+	return m.waitErr
+}
 func (m *MockCommandExecutor) Kill() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.killed = true
+	if m.hang != nil {
+		close(m.hang)
+	}
 	return nil
 }
 
 func (m *MockCommandExecutor) SetEnv(env []string) { m.env = env }
 
-func (m *MockCommandExecutor) CombinedOutput() ([]byte, error) {
-	return m.combinedOutput, m.combinedErr
+func (m *MockCommandExecutor) SetOutput(stdout io.Writer, stderr io.Writer) {
+	m.stdoutDst = stdout
+	m.stderrDst = stderr
 }
 func TestRunBackgroundTask_Normal(t *testing.T) {
 	// Prepare mock stdout/stderr
@@ -335,11 +390,9 @@ func TestRunBackgroundTask_CommandFails(t *testing.T) {
 	stdout := io.NopCloser(bytes.NewBufferString("stdout line\n"))
 	stderr := io.NopCloser(bytes.NewBufferString("stderr line\n"))
 	mockCmd := &MockCommandExecutor{
-		stdout:         stdout,
-		stderr:         stderr,
-		combinedOutput: []byte("command failed"),
-		combinedErr:    fmt.Errorf("simulated error"),
-		waitErr:        fmt.Errorf("simulated error"),
+		stdout:  stdout,
+		stderr:  stderr,
+		waitErr: fmt.Errorf("simulated error"),
 	}
 
 	// Command runner returns the mock command
@@ -450,7 +503,7 @@ func TestBackupValidation(t *testing.T) {
 		t.Run(tc.desc, func(t *testing.T) {
 			fExec := execCalls{}
 			pgb := newPgBackrestWithRunner(nil, fExec.fakeCmdRunner("", nil))
-			err := pgb.Backup(tc.backupType)
+			err := pgb.Backup(context.Background(), tc.backupType)
 			if tc.wantErr && err == nil {
 				t.Errorf("expected error for backup type %q, got nil", tc.backupType)
 			}
@@ -504,3 +557,26 @@ func TestCountByType(t *testing.T) {
 		})
 	}
 }
+
+func TestRunExporter(t *testing.T) {
+	newRunner := func(script string) *PgBackrestExporterRunner {
+		return &PgBackrestExporterRunner{baseRunner: baseRunner{
+			command: "pgbackrest_exporter",
+			cmdRunner: func(args ...string) CommandExecutor {
+				return newExecCmd("sh", "-c", script)
+			},
+		}}
+	}
+
+	err := newRunner("echo 'listening' >&2; echo 'collector failed' >&2; exit 1").
+		RunExporter(context.Background(), nil)
+	if err == nil || !strings.Contains(err.Error(), "collector failed") {
+		t.Errorf("the exit must be reported with the last output, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	if err := newRunner("sleep 30").RunExporter(ctx, nil); err != nil {
+		t.Errorf("the stopped exporter must not be reported, got %v", err)
+	}
+}
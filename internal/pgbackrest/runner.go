@@ -0,0 +1,198 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package pgbackrest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"slices"
+	"time"
+
+	"github.com/cloudnative-pg/machinery/pkg/log"
+)
+
+// outputWaitDelay bounds the time spent reading the outputs of a command
+// once it exited: the asynchronous archive processes forked by pgBackRest
+// must not keep us waiting on their inherited file descriptors.
+const outputWaitDelay = 5 * time.Second
+
+// defaultTimeouts bounds the duration of pgBackRest commands, a command
+// still running when its timeout is reached (e.g. stuck on an unresponsive
+// object storage) is killed. Commands not listed here (backup, restore...)
+// are only bounded by the context.
+var defaultTimeouts = map[string]time.Duration{
+	"archive-push":  10 * time.Minute,
+	"archive-get":   10 * time.Minute,
+	"info":          5 * time.Minute,
+	"stanza-create": 5 * time.Minute,
+	"version":       time.Minute,
+}
+
+type CommandExecutor interface {
+	Kill() error
+	Start() error
+	Wait() error
+	SetEnv(env []string)
+	SetOutput(stdout io.Writer, stderr io.Writer)
+}
+
+type ExecCmd struct {
+	*exec.Cmd
+}
+
+// newExecCmd prepares the command to run in its own process group, so that
+// the processes it spawns are killed along with it.
+func newExecCmd(command string, args ...string) *ExecCmd {
+	cmd := exec.Command(command, args...)
+	cmd.WaitDelay = outputWaitDelay
+	setProcessGroup(cmd)
+	return &ExecCmd{cmd}
+}
+
+func (e *ExecCmd) Kill() error {
+	if e.Process == nil {
+		return fmt.Errorf("process does not exist")
+	}
+	return killProcessGroup(e.Cmd)
+}
+
+func (e *ExecCmd) SetEnv(env []string) {
+	e.Env = env
+}
+
+func (e *ExecCmd) SetOutput(stdout io.Writer, stderr io.Writer) {
+	e.Stdout = stdout
+	e.Stderr = stderr
+}
+
+type baseRunner struct {
+	command   string
+	cmdRunner func(args ...string) CommandExecutor
+	baseEnv   []string
+	// baseArgs are given to every command, before its own arguments
+	baseArgs []string
+	// rendered is the configuration given by baseArgs, if any
+	rendered *renderedConfig
+	timeouts map[string]time.Duration
+}
+
+func newBaseRunner(command string, env []string) baseRunner {
+	return baseRunner{
+		command: command,
+		cmdRunner: func(args ...string) CommandExecutor {
+			return newExecCmd(command, args...)
+		},
+		baseEnv:  env,
+		timeouts: maps.Clone(defaultTimeouts),
+	}
+}
+
+// SetTimeout overrides the timeout of a pgBackRest command, 0 disables it.
+func (p *baseRunner) SetTimeout(command string, timeout time.Duration) {
+	if p.timeouts == nil {
+		p.timeouts = make(map[string]time.Duration)
+	}
+	p.timeouts[command] = timeout
+}
+
+func (p *baseRunner) run(args []string, extraEnv []string) CommandExecutor {
+	cmd := p.cmdRunner(append(slices.Clone(p.baseArgs), args...)...)
+	cmd.SetEnv(append(os.Environ(), append(p.baseEnv, extraEnv...)...))
+	return cmd
+}
+
+// execute runs the command until it exits, its timeout is reached or the
+// context is cancelled; in the two last cases the whole process group is
+// killed. The log lines printed by the command are forwarded to the logger.
+// When stdout is not nil, the standard output is copied there instead.
+func (p *baseRunner) execute(
+	ctx context.Context,
+	args []string,
+	extraEnv []string,
+	stdout io.Writer,
+) error {
+	if len(args) > 0 && p.timeouts[args[0]] > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeouts[args[0]])
+		defer cancel()
+	}
+	logger := log.FromContext(ctx).WithValues("command", p.command, "args", args)
+	if p.rendered != nil {
+		release, err := p.rendered.acquire()
+		if err != nil {
+			logger.Error(err, "can't render configuration")
+			return err
+		}
+		defer release()
+	}
+
+	stdoutLog := newLogWriter(logger)
+	stderrLog := newLogWriter(logger)
+	if stdout == nil {
+		stdout = stdoutLog
+	}
+	cmd := p.run(args, extraEnv)
+	cmd.SetOutput(stdout, stderrLog)
+
+	if err := cmd.Start(); err != nil {
+		logger.Error(err, "can't start task")
+		return err
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		logger.Warning("killing task", "reason", ctx.Err())
+		if killErr := cmd.Kill(); killErr != nil {
+			logger.Error(killErr, "can't kill task")
+		}
+		<-done
+		return fmt.Errorf("%s %v interrupted: %w", p.command, args, ctx.Err())
+	}
+	stdoutLog.Flush()
+	stderrLog.Flush()
+
+	if err != nil {
+		cmdErr := newCommandError(args, err, stdoutLog.Errors()+stderrLog.Errors())
+		if errors.Is(cmdErr, ErrWALNotFound) {
+			logger.Info("WAL not found in the repositories")
+		} else {
+			logger.Error(cmdErr, "task failed", "exit code", cmdErr.ExitCode)
+		}
+		return cmdErr
+	}
+	return nil
+}
+
+// output runs the command like execute, but returns its standard output.
+func (p *baseRunner) output(ctx context.Context, args []string, extraEnv []string) ([]byte, error) {
+	var stdout bytes.Buffer
+	err := p.execute(ctx, args, extraEnv, &stdout)
+	return stdout.Bytes(), err
+}
+
+// runBackgroundTask runs the command in a goroutine, the returned channel
+// receives its result.
+func (p *baseRunner) runBackgroundTask(
+	ctx context.Context,
+	args []string,
+	extraEnv []string,
+) <-chan error {
+	result := make(chan error, 1)
+	go func() {
+		defer close(result)
+		result <- p.execute(ctx, args, extraEnv, nil)
+	}()
+	return result
+}
@@ -0,0 +1,129 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package pgbackrest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudnative-pg/machinery/pkg/log"
+)
+
+func newMockRunner(mockCmd *MockCommandExecutor) *PgBackrestRunner {
+	return &PgBackrestRunner{
+		baseRunner: baseRunner{
+			command:   "pgbackrest",
+			cmdRunner: func(args ...string) CommandExecutor { return mockCmd },
+		},
+	}
+}
+
+func TestRunBackgroundTask_Timeout(t *testing.T) {
+	mockCmd := &MockCommandExecutor{hang: make(chan struct{})}
+	pg := newMockRunner(mockCmd)
+	pg.SetTimeout("archive-push", 50*time.Millisecond)
+
+	err := <-pg.PushWal(context.Background(), "000000010000000000000001")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if !mockCmd.killed {
+		t.Errorf("command should be killed when its timeout is reached")
+	}
+}
+
+func TestRunBackgroundTask_Cancelled(t *testing.T) {
+	mockCmd := &MockCommandExecutor{hang: make(chan struct{})}
+	pg := newMockRunner(mockCmd)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := pg.Restore(ctx)
+	cancel()
+	if err := <-errCh; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context canceled, got %v", err)
+	}
+	if !mockCmd.killed {
+		t.Errorf("command should be killed when the context is cancelled")
+	}
+}
+
+func TestRunBackgroundTask_WarningIsNotFailure(t *testing.T) {
+	mockCmd := &MockCommandExecutor{
+		stdout: strings.NewReader(""),
+		stderr: strings.NewReader("P00   WARN: option 'repo1-retention-full' is not set\n"),
+	}
+	pg := newMockRunner(mockCmd)
+	if err := <-pg.PushWal(context.Background(), "000000010000000000000001"); err != nil {
+		t.Fatalf("a warning must not fail the command, got %v", err)
+	}
+}
+
+func TestRepositoriesConfiguredOutput(t *testing.T) {
+	mockCmd := &MockCommandExecutor{
+		stdout: strings.NewReader(`[{"repo":[{"status":{"code":0,"message":"ok"}}]}]`),
+		stderr: strings.NewReader("P00   INFO: info command end: completed successfully\n"),
+	}
+	pg := newMockRunner(mockCmd)
+	ok, err := pg.RepositoriesConfigured(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !ok {
+		t.Errorf("expected configured repositories")
+	}
+}
+
+func TestParseLogLine(t *testing.T) {
+	testCases := []struct {
+		line string
+		want logRecord
+		ok   bool
+	}{
+		{
+			"P00   INFO: archive-push command end: completed successfully",
+			logRecord{Process: "P00", Level: "INFO", Message: "archive-push command end: completed successfully"},
+			true,
+		},
+		{
+			"2025-03-06 10:00:00.000 P01   WARN: unable to find a valid repository",
+			logRecord{Process: "P01", Level: "WARN", Message: "unable to find a valid repository"},
+			true,
+		},
+		{
+			"P00  ERROR: [044]: WAL segment system-id does not match",
+			logRecord{Process: "P00", Level: "ERROR", Message: "[044]: WAL segment system-id does not match"},
+			true,
+		},
+		{"not a pgBackRest line", logRecord{}, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.line, func(t *testing.T) {
+			got, ok := parseLogLine(tc.line)
+			if ok != tc.ok || got != tc.want {
+				t.Errorf("want %v (%v), got %v (%v)", tc.want, tc.ok, got, ok)
+			}
+		})
+	}
+}
+
+func TestLogWriterKeepsErrors(t *testing.T) {
+	w := newLogWriter(log.GetLogger())
+	var in bytes.Buffer
+	in.WriteString("P00   INFO: archive-get command begin\nP00  ERROR: [103]: unable to find")
+	if _, err := w.Write(in.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if w.Errors() != "" {
+		t.Errorf("the unterminated line must not be handled before Flush")
+	}
+	w.Flush()
+	if w.Errors() != "P00  ERROR: [103]: unable to find\n" {
+		t.Errorf("unexpected errors %q", w.Errors())
+	}
+}
@@ -0,0 +1,159 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package pgbackrest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	pgbackrestapi "github.com/dalibo/cnpg-i-pgbackrest/api/v1"
+)
+
+// ErrUnsupportedOption is returned when the stanza uses options the running
+// pgBackRest version doesn't support.
+var ErrUnsupportedOption = errors.New("option not supported by pgBackRest")
+
+// Version is a pgBackRest release version, development versions (e.g.
+// 2.56.0dev) are considered as the release they precede.
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+// versionRegexp matches the output of the version command, e.g.
+// "pgBackRest 2.55.1"
+var versionRegexp = regexp.MustCompile(`(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// ParseVersion parses the output of the pgBackRest version command
+func ParseVersion(s string) (Version, error) {
+	m := versionRegexp.FindStringSubmatch(s)
+	if m == nil {
+		return Version{}, fmt.Errorf("can't parse pgBackRest version %q", strings.TrimSpace(s))
+	}
+	var v Version
+	v.Major, _ = strconv.Atoi(m[1])
+	v.Minor, _ = strconv.Atoi(m[2])
+	if m[3] != "" {
+		v.Patch, _ = strconv.Atoi(m[3])
+	}
+	return v, nil
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// AtLeast tells whether v is the same release as o, or a later one
+func (v Version) AtLeast(o Version) bool {
+	if v.Major != o.Major {
+		return v.Major > o.Major
+	}
+	if v.Minor != o.Minor {
+		return v.Minor > o.Minor
+	}
+	return v.Patch >= o.Patch
+}
+
+// Version returns the version of the pgBackRest binary
+func (p *PgBackrestRunner) Version(ctx context.Context) (Version, error) {
+	// the configuration isn't needed, and may not be readable yet
+	stdout, err := p.output(ctx, []string{"version"}, nil)
+	if err != nil {
+		return Version{}, fmt.Errorf("can't execute pgbackrest version command: %w", err)
+	}
+	return ParseVersion(string(stdout))
+}
+
+// optionRequirement is an option of the stanza configuration only supported
+// from a given pgBackRest release
+type optionRequirement struct {
+	option string
+	since  Version
+	used   func(*pgbackrestapi.StanzaConfiguration) bool
+}
+
+func anyS3Repository(f func(*pgbackrestapi.S3Repository) bool) func(*pgbackrestapi.StanzaConfiguration) bool {
+	return func(conf *pgbackrestapi.StanzaConfiguration) bool {
+		for i := range conf.S3Repositories {
+			if f(&conf.S3Repositories[i]) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func anyAzureRepository(f func(*pgbackrestapi.AzureRepository) bool) func(*pgbackrestapi.StanzaConfiguration) bool {
+	return func(conf *pgbackrestapi.StanzaConfiguration) bool {
+		for i := range conf.AzureRepositories {
+			if f(&conf.AzureRepositories[i]) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// optionRequirements lists the options of the Stanza spec requiring a
+// recent pgBackRest release, see the release notes of pgBackRest.
+var optionRequirements = []optionRequirement{
+	{
+		option: "repo-s3-key-type=web-id",
+		since:  Version{2, 41, 0},
+		used: anyS3Repository(func(r *pgbackrestapi.S3Repository) bool {
+			return r.KeyType == pgbackrestapi.S3KeyTypeWebID
+		}),
+	},
+	{
+		option: "repo-storage-tag",
+		since:  Version{2, 48, 0},
+		used: anyS3Repository(func(r *pgbackrestapi.S3Repository) bool {
+			return len(r.Tags) > 0
+		}),
+	},
+	{
+		option: "repo-s3-requester-pays",
+		since:  Version{2, 50, 0},
+		used: anyS3Repository(func(r *pgbackrestapi.S3Repository) bool {
+			return r.RequesterPays != nil && *r.RequesterPays
+		}),
+	},
+	{
+		option: "repo-azure-key-type=auto",
+		since:  Version{2, 51, 0},
+		used: anyAzureRepository(func(r *pgbackrestapi.AzureRepository) bool {
+			return r.KeyType == pgbackrestapi.AzureKeyTypeAuto ||
+				r.KeyType == pgbackrestapi.AzureKeyTypeWorkloadIdentity
+		}),
+	},
+	{
+		option: "repo-s3-sse-customer-key",
+		since:  Version{2, 53, 0},
+		used: anyS3Repository(func(r *pgbackrestapi.S3Repository) bool {
+			return r.ServerSideEncryption != nil && r.ServerSideEncryption.CustomerKeyReference != nil
+		}),
+	},
+}
+
+// CheckOptions returns an error wrapping ErrUnsupportedOption when the
+// stanza configuration uses options the pgBackRest version doesn't support.
+func CheckOptions(conf *pgbackrestapi.StanzaConfiguration, v Version) error {
+	var unsupported []string
+	for _, req := range optionRequirements {
+		if req.used(conf) && !v.AtLeast(req.since) {
+			unsupported = append(unsupported, fmt.Sprintf("%s (requires %d.%d)",
+				req.option, req.since.Major, req.since.Minor))
+		}
+	}
+	if len(unsupported) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w %s: %s", ErrUnsupportedOption, v, strings.Join(unsupported, ", "))
+}
@@ -0,0 +1,121 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package pgbackrest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	machineryapi "github.com/cloudnative-pg/machinery/pkg/api"
+	pgbackrestapi "github.com/dalibo/cnpg-i-pgbackrest/api/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestParseVersion(t *testing.T) {
+	testCases := []struct {
+		output  string
+		want    Version
+		wantErr bool
+	}{
+		{"pgBackRest 2.55.1\n", Version{2, 55, 1}, false},
+		{"pgBackRest 2.56.0dev\n", Version{2, 56, 0}, false},
+		{"pgBackRest 2.9", Version{2, 9, 0}, false},
+		{"command not found", Version{}, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.output, func(t *testing.T) {
+			got, err := ParseVersion(tc.output)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("want %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestVersionAtLeast(t *testing.T) {
+	testCases := []struct {
+		v, o Version
+		want bool
+	}{
+		{Version{2, 55, 1}, Version{2, 55, 0}, true},
+		{Version{2, 55, 0}, Version{2, 55, 0}, true},
+		{Version{2, 9, 0}, Version{2, 48, 0}, false},
+		{Version{3, 0, 0}, Version{2, 48, 0}, true},
+		{Version{2, 47, 9}, Version{2, 48, 0}, false},
+	}
+	for _, tc := range testCases {
+		if got := tc.v.AtLeast(tc.o); got != tc.want {
+			t.Errorf("%s at least %s: want %v, got %v", tc.v, tc.o, tc.want, got)
+		}
+	}
+}
+
+func TestCheckOptions(t *testing.T) {
+	conf := &pgbackrestapi.StanzaConfiguration{
+		S3Repositories: []pgbackrestapi.S3Repository{
+			{Bucket: "plain"},
+			{
+				Bucket:        "tagged",
+				Tags:          map[string]string{"team": "db"},
+				RequesterPays: ptr.To(true),
+				ServerSideEncryption: &pgbackrestapi.S3ServerSideEncryption{
+					CustomerKeyReference: &machineryapi.SecretKeySelector{Key: "key"},
+				},
+			},
+		},
+	}
+	testCases := []struct {
+		version     Version
+		unsupported []string
+	}{
+		{Version{2, 55, 1}, nil},
+		{Version{2, 51, 0}, []string{"repo-s3-sse-customer-key"}},
+		{Version{2, 45, 0}, []string{"repo-storage-tag", "repo-s3-requester-pays", "repo-s3-sse-customer-key"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.version.String(), func(t *testing.T) {
+			err := CheckOptions(conf, tc.version)
+			if len(tc.unsupported) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrUnsupportedOption) {
+				t.Fatalf("expected ErrUnsupportedOption, got %v", err)
+			}
+			for _, option := range tc.unsupported {
+				if !strings.Contains(err.Error(), option) {
+					t.Errorf("expected %s in %v", option, err)
+				}
+			}
+			if strings.Count(err.Error(), "requires") != len(tc.unsupported) {
+				t.Errorf("unexpected options in %v", err)
+			}
+		})
+	}
+	if err := CheckOptions(&pgbackrestapi.StanzaConfiguration{}, Version{2, 0, 0}); err != nil {
+		t.Errorf("no option to check, got %v", err)
+	}
+}
+
+func TestVersionCommand(t *testing.T) {
+	mockCmd := &MockCommandExecutor{
+		stdout: strings.NewReader("pgBackRest 2.54.2\n"),
+		stderr: strings.NewReader(""),
+	}
+	got, err := newMockRunner(mockCmd).Version(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if got != (Version{2, 54, 2}) {
+		t.Errorf("unexpected version %s", got)
+	}
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/cloudnative-pg/cnpg-i/pkg/identity"
 	"github.com/dalibo/cnpg-i-pgbackrest/internal/metadata"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/utils"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -16,6 +17,8 @@ import (
 type IdentityImplementation struct {
 	identity.UnimplementedIdentityServer
 	Client client.Client
+	// Health gives the readiness of the sidecar
+	Health *utils.HealthChecker
 }
 
 // GetPluginMetadata implements IdentityServer
@@ -51,12 +54,13 @@ func (i IdentityImplementation) GetPluginCapabilities(
 	}, nil
 }
 
-// Probe implements IdentityServer
+// Probe implements IdentityServer, the plugin is ready when the pgBackRest
+// binary runs
 func (i IdentityImplementation) Probe(
-	_ context.Context,
+	ctx context.Context,
 	_ *identity.ProbeRequest,
 ) (*identity.ProbeResponse, error) {
 	return &identity.ProbeResponse{
-		Ready: true,
+		Ready: i.Health == nil || i.Health.Ready(ctx),
 	}, nil
 }
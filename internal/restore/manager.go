@@ -10,6 +10,7 @@ import (
 
 	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
 	apipgbackrest "github.com/dalibo/cnpg-i-pgbackrest/api/v1"
+	extendedclient "github.com/dalibo/cnpg-i-pgbackrest/internal/instance/client"
 	"github.com/spf13/viper"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -53,8 +54,17 @@ func Start(ctx context.Context) error {
 		return err
 	}
 
+	watchClient, err := client.NewWithWatch(mgr.GetConfig(), client.Options{
+		Scheme: mgr.GetScheme(),
+		Mapper: mgr.GetRESTMapper(),
+	})
+	if err != nil {
+		setupLog.Error(err, "unable to create watch client")
+		return err
+	}
+
 	if err := mgr.Add(&CNPGI{
-		Client:       mgr.GetClient(),
+		Client:       extendedclient.NewWatchingClient(ctx, watchClient),
 		InstanceName: podName,
 		// TODO: improve
 		PGDataPath: viper.GetString("pgdata"),
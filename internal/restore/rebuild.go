@@ -0,0 +1,90 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package restore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/dalibo/cnpg-i-pgbackrest/internal/config"
+	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Rebuild resynchronizes the PGDATA of an instance (a replica or a former
+// primary that diverged) with a pgBackRest delta restore. This is the part
+// running on the rebuild init container, before PostgreSQL is started.
+func Rebuild(ctx context.Context) error {
+	contextLogger := log.FromContext(ctx).WithName("rebuild")
+	podName := viper.GetString("pod-name")
+	pgData := viper.GetString("pgdata")
+
+	cl, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+	if err != nil {
+		return err
+	}
+
+	var cluster cnpgv1.Cluster
+	if err := cl.Get(ctx, types.NamespacedName{
+		Namespace: viper.GetString("namespace"),
+		Name:      viper.GetString("cluster-name"),
+	}, &cluster); err != nil {
+		return err
+	}
+
+	if err := canRebuild(&cluster, podName, pgData); err != nil {
+		if errors.Is(err, errEmptyPGData) {
+			contextLogger.Info("PGDATA is empty, skipping delta restore", "pgdata", pgData)
+			return nil
+		}
+		return err
+	}
+
+	getRef := (*config.PluginConfiguration).GetStanzaRef
+	if cluster.IsReplica() {
+		getRef = (*config.PluginConfiguration).GetReplicaStanzaRef
+	}
+	stanza, err := config.GetStanzaFromCluster(ctx, &cluster, cl, getRef)
+	if err != nil {
+		return err
+	}
+	pgb, err := config.NewPgBackrest(ctx, stanza, cl)
+	if err != nil {
+		return err
+	}
+
+	contextLogger.Info("starting delta restore", "pod", podName, "stanza", stanza.Name)
+	recoveryOption := "PGBACKREST_RECOVERY_OPTION=restore_command=" + walRestoreCommand()
+	if err := <-pgb.DeltaRestore(ctx, recoveryOption); err != nil {
+		return fmt.Errorf("delta restore failed: %w", err)
+	}
+	contextLogger.Info("delta restore done", "pod", podName)
+	return nil
+}
+
+var errEmptyPGData = errors.New("PGDATA is empty")
+
+// canRebuild ensures a delta restore can safely be run on the
+// instance: it must not be the current primary and its PGDATA
+// must already contain a PostgreSQL data directory.
+func canRebuild(cluster *cnpgv1.Cluster, podName string, pgData string) error {
+	if cluster.Status.CurrentPrimary == podName || cluster.Status.TargetPrimary == podName {
+		return fmt.Errorf("refusing to rebuild %q, it is the primary instance", podName)
+	}
+	if _, err := os.Stat(path.Join(pgData, "PG_VERSION")); err != nil {
+		if os.IsNotExist(err) {
+			return errEmptyPGData
+		}
+		return err
+	}
+	return nil
+}
@@ -86,6 +86,16 @@ func recoveryTargetToRestoreOptions(
 	return res
 }
 
+// walRestoreCommand returns the restore_command relying on the CNPG
+// instance manager, which forwards WAL restore requests to the plugin.
+func walRestoreCommand() string {
+	return fmt.Sprintf(
+		"/controller/manager wal-restore --log-destination %s/%s.json %%f %%p",
+		postgres.LogPath,
+		postgres.LogFileName,
+	)
+}
+
 func (impl JobHookImpl) Restore(
 	ctx context.Context,
 	req *restore.RestoreRequest,
@@ -100,7 +110,7 @@ func (impl JobHookImpl) Restore(
 	if err != nil {
 		return nil, err
 	}
-	env, err := config.GetEnvVarConfig(ctx, stanza, impl.Client)
+	pgb, err := config.NewPgBackrest(ctx, stanza, impl.Client)
 	if err != nil {
 		return nil, err
 	}
@@ -109,11 +119,10 @@ func (impl JobHookImpl) Restore(
 		return nil, err
 	}
 	recovOption := recoveryTargetToRestoreOptions(cConfig.Cluster)
-	recovEnv, err := recovOption.ToEnv()
+	env, err := recovOption.ToEnv()
 	if err != nil {
 		return nil, err
 	}
-	env = append(env, recovEnv...)
 	// Unfortunately, we need to override the recovery_command (through the
 	// pgbackrest recovery option) instead of letting pgBackRest generate it.
 	//
@@ -125,14 +134,9 @@ func (impl JobHookImpl) Restore(
 	// Because the restore_command is no longer removed, if we keep the restore_command
 	// generated by pgBackRest, PostgreSQL on the main container won't start / restore
 	// since the pgBackRest binary is not available / runnable on the main container.
-	restoreCmd := fmt.Sprintf(
-		"/controller/manager wal-restore --log-destination %s/%s.json %%f %%p",
-		postgres.LogPath,
-		postgres.LogFileName,
-	)
+	restoreCmd := walRestoreCommand()
 	env = append(env, "PGBACKREST_RECOVERY_OPTION=restore_command="+restoreCmd)
-	pgb := pgbackrest.NewPgBackrest(env)
-	errCh := pgb.Restore(ctx)
+	errCh := pgb.Restore(ctx, env...)
 	if err := <-errCh; err != nil {
 		return nil, err
 	}
@@ -4,6 +4,9 @@
 package restore
 
 import (
+	"errors"
+	"os"
+	"path"
 	"testing"
 
 	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
@@ -122,3 +125,38 @@ func TestRecoveryTargetToRestoreOption(t *testing.T) {
 		})
 	}
 }
+
+func TestCanRebuild(t *testing.T) {
+	withPGData := t.TempDir()
+	if err := os.WriteFile(path.Join(withPGData, "PG_VERSION"), []byte("17\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	emptyPGData := t.TempDir()
+
+	cluster := newCluster(nil)
+	cluster.Status.CurrentPrimary = "cl-1"
+	cluster.Status.TargetPrimary = "cl-1"
+
+	testCases := []struct {
+		desc    string
+		podName string
+		pgData  string
+		wantErr error
+		fails   bool
+	}{
+		{"replica with existing PGDATA", "cl-2", withPGData, nil, false},
+		{"replica with empty PGDATA", "cl-2", emptyPGData, errEmptyPGData, true},
+		{"current primary is never rebuilt", "cl-1", withPGData, nil, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := canRebuild(cluster, tc.podName, tc.pgData)
+			if tc.fails != (err != nil) {
+				t.Fatalf("unexpected result, got error: %v", err)
+			}
+			if tc.wantErr != nil && !errors.Is(err, tc.wantErr) {
+				t.Errorf("error want %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
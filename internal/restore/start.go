@@ -29,6 +29,10 @@ type CNPGI struct {
 
 // Start starts the GRPC service
 func (c *CNPGI) Start(ctx context.Context) error {
+	health := &utils.HealthChecker{
+		Checks:    map[string]utils.HealthCheck{instance.HealthPgBackRest: instance.CheckPgBackRest},
+		Readiness: []string{instance.HealthPgBackRest},
+	}
 	enrich := func(server *grpc.Server) error {
 		wal.RegisterWALServer(server, &instance.WALSrvImplementation{
 			InstanceName: c.InstanceName,
@@ -42,7 +46,7 @@ func (c *CNPGI) Start(ctx context.Context) error {
 			PgWalFolderToSymlink: "/var/lib/postgresql/wal/pg_wal",
 		})
 
-		utils.AddHealthCheck(server)
+		utils.AddHealthCheck(server, health)
 
 		return nil
 	}
@@ -50,6 +54,7 @@ func (c *CNPGI) Start(ctx context.Context) error {
 	srv := http.Server{
 		IdentityImpl: IdentityImplementation{
 			Client: c.Client,
+			Health: health,
 		},
 		Enrichers:  []http.ServerEnricher{enrich},
 		PluginPath: c.PluginPath,
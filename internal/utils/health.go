@@ -24,26 +24,74 @@ import (
 
 	"github.com/cloudnative-pg/machinery/pkg/log"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
 )
 
-// AddHealthCheck adds a health check service to the gRPC server with the tag 'plugin-barman-cloud'
-func AddHealthCheck(server *grpc.Server) {
-	grpc_health_v1.RegisterHealthServer(server, &healthServer{}) // replaces default registration
+// HealthCheck checks a part of the state of the plugin, it returns an
+// error when it isn't healthy
+type HealthCheck func(ctx context.Context) error
+
+// HealthChecker runs the checks of the health service, by service name. The
+// overall health, asked with the empty service name, requires the services
+// listed in Readiness to be healthy.
+type HealthChecker struct {
+	Checks    map[string]HealthCheck
+	Readiness []string
+}
+
+// Status returns the status of a service, the check of the service is run
+func (h *HealthChecker) Status(
+	ctx context.Context,
+	service string,
+) (grpc_health_v1.HealthCheckResponse_ServingStatus, error) {
+	services := []string{service}
+	if service == "" {
+		services = h.Readiness
+	}
+	serving := grpc_health_v1.HealthCheckResponse_SERVING
+	for _, name := range services {
+		check, ok := h.Checks[name]
+		if !ok {
+			return grpc_health_v1.HealthCheckResponse_SERVICE_UNKNOWN,
+				status.Errorf(codes.NotFound, "unknown service %q", name)
+		}
+		if err := check(ctx); err != nil {
+			log.FromContext(ctx).Info("health check failed", "service", name, "error", err.Error())
+			serving = grpc_health_v1.HealthCheckResponse_NOT_SERVING
+		}
+	}
+	return serving, nil
+}
+
+// Ready tells whether the services of the overall health are healthy
+func (h *HealthChecker) Ready(ctx context.Context) bool {
+	s, err := h.Status(ctx, "")
+	return err == nil && s == grpc_health_v1.HealthCheckResponse_SERVING
+}
+
+// AddHealthCheck adds the health check service to the gRPC server, the
+// status of the services is given by the checker
+func AddHealthCheck(server *grpc.Server, checker *HealthChecker) {
+	grpc_health_v1.RegisterHealthServer(server, &healthServer{checker: checker}) // replaces default registration
 }
 
 type healthServer struct {
 	grpc_health_v1.UnimplementedHealthServer
+	checker *HealthChecker
 }
 
 // Check is the response handle for the healthcheck request
 func (h healthServer) Check(
 	ctx context.Context,
-	_ *grpc_health_v1.HealthCheckRequest,
+	req *grpc_health_v1.HealthCheckRequest,
 ) (*grpc_health_v1.HealthCheckResponse, error) {
 	contextLogger := log.FromContext(ctx)
-	contextLogger.Trace("serving health check response")
-	return &grpc_health_v1.HealthCheckResponse{
-		Status: grpc_health_v1.HealthCheckResponse_SERVING,
-	}, nil
+	contextLogger.Trace("serving health check response", "service", req.GetService())
+	s, err := h.checker.Status(ctx, req.GetService())
+	if err != nil {
+		return nil, err
+	}
+	return &grpc_health_v1.HealthCheckResponse{Status: s}, nil
 }
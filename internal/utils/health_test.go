@@ -0,0 +1,47 @@
+// SPDX-FileCopyrightText: 2026 Dalibo <contact@dalibo.com>
+//
+// SPDX-License-Identifier: Apache-2.0
+package utils
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestHealthCheckerStatus(t *testing.T) {
+	ok := func(context.Context) error { return nil }
+	failing := func(context.Context) error { return errors.New("failed") }
+	checker := &HealthChecker{
+		Checks:    map[string]HealthCheck{"binary": ok, "config": ok, "archive": failing},
+		Readiness: []string{"binary", "config"},
+	}
+	testCases := []struct {
+		service string
+		want    grpc_health_v1.HealthCheckResponse_ServingStatus
+		wantErr bool
+	}{
+		{"", grpc_health_v1.HealthCheckResponse_SERVING, false},
+		{"binary", grpc_health_v1.HealthCheckResponse_SERVING, false},
+		{"archive", grpc_health_v1.HealthCheckResponse_NOT_SERVING, false},
+		{"unknown", grpc_health_v1.HealthCheckResponse_SERVICE_UNKNOWN, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.service, func(t *testing.T) {
+			got, err := checker.Status(context.Background(), tc.service)
+			if (err != nil) != tc.wantErr || got != tc.want {
+				t.Errorf("want %v (error %v), got %v (%v)", tc.want, tc.wantErr, got, err)
+			}
+		})
+	}
+	if !checker.Ready(context.Background()) {
+		t.Error("the checker must be ready")
+	}
+
+	checker.Checks["config"] = failing
+	if checker.Ready(context.Background()) {
+		t.Error("the checker must not be ready when a readiness check fails")
+	}
+}